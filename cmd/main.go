@@ -1,71 +1,79 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"os"
-	"subscriptions_service_golang/docs"
-	"subscriptions_service_golang/internal/handlers"
-	"subscriptions_service_golang/internal/middleware"
-	"subscriptions_service_golang/internal/repositories"
-	"subscriptions_service_golang/internal/services"
-	"subscriptions_service_golang/pkg"
-	"subscriptions_service_golang/pkg/logger"
+	"strings"
 
-	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
-	swaggerFiles "github.com/swaggo/files"
-	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
+const usage = `usage: subscription-service [command] [flags] [args]
+
+commands:
+  serve           run the HTTP API (default when no command is given)
+  migrate         apply, roll back and inspect database migrations
+  seed            load demo subscriptions for a user
+  user create     add a user that can log in
+  apikey issue    issue an API key for a user
+  subs import     import subscriptions from a CSV or NDJSON file
+  subs export     export subscriptions as CSV, XLSX or NDJSON
+  recalc-totals   recalculate the total subscription cost of every user
+
+Every command accepts the configuration flags of serve, run
+"subscription-service <command> -h" to list them.
+`
+
+// commands maps the first argument to its implementation. Each returns
+// the process exit code: 0 on success, 1 when the work failed and 2 for
+// usage errors.
+var commands = map[string]func(args []string) int{
+	"serve":         runServe,
+	"migrate":       runMigrate,
+	"seed":          runSeed,
+	"user":          runUser,
+	"apikey":        runAPIKey,
+	"subs":          runSubs,
+	"recalc-totals": runRecalcTotals,
+}
+
 // @title Subscription API
 // @version 1.0
 // @description API for managing subscriptions
 // @host localhost:8080
 // @BasePath /
 func main() {
-	r := gin.Default()
 	if err := godotenv.Load(".env"); err != nil {
 		log.Println("No .env file found, using system env")
 	}
+	os.Exit(run(os.Args[1:]))
+}
 
-	logger.Init()
-	defer logger.Log.Sync()
-
-	dsn := os.Getenv("DB_DSN")
-	database := pkg.Init(dsn) // db init
-	repo := repositories.NewSubscriptionRepository(database)
-	service := services.NewSubscriptionService(repo)
-	handler := handlers.NewSubscriptionHandler(service)
-
-	docs.SwaggerInfo.Title = "Subscription API"
-	docs.SwaggerInfo.Description = "API for managing subscriptions"
-	docs.SwaggerInfo.Version = "1.0"
-	docs.SwaggerInfo.Host = "localhost:8080"
-	docs.SwaggerInfo.BasePath = "/"
-	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
-
-	authHandler := handlers.NewAuthHandler()
-	r.POST("/login", authHandler.Login)
-
-	auth := r.Group("/")
-	auth.Use(middleware.AuthMiddleware(true))
-	{
-		auth.POST("/subscriptions", handler.Create)
-		auth.PUT("/subscriptions/:id", handler.Update)
-		auth.DELETE("/subscriptions/:id", handler.Delete)
+func run(args []string) int {
+	// without a command the binary serves, as it did before the CLI existed
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return runServe(args)
 	}
-
-	// r.POST("/subscriptions", handler.Create)
-	// r.PUT("/subscriptions/:id", handler.Update)
-	// r.DELETE("/subscriptions/:id", handler.Delete)
-	optional := r.Group("/")
-	optional.Use(middleware.AuthMiddleware(false))
-	{
-
-		optional.GET("/subscriptions/:id", handler.GetByID)
-		optional.GET("/subscriptions", handler.List)
-		optional.GET("/subscriptions/total", handler.TotalPrice)
+	if args[0] == "help" {
+		fmt.Print(usage)
+		return 0
 	}
+	command, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+	return command(args[1:])
+}
 
-	r.Run(":8080")
+// fail reports err of command on stderr and returns the exit code for it
+func fail(command string, err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	fmt.Fprintf(os.Stderr, "%s: %v\n", command, err)
+	return 1
 }
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"subscriptions_service_golang/migrations"
+	"subscriptions_service_golang/pkg"
+	"subscriptions_service_golang/pkg/config"
+	"subscriptions_service_golang/pkg/migrate"
+)
+
+const migrateUsage = `usage: subscription-service migrate <command> [flags] [args]
+
+commands:
+  up               apply all pending migrations
+  down [N]         roll back the last N migrations (default 1)
+  status           list migrations and when they were applied
+  create NAME      add an empty NNN_NAME.up.sql / .down.sql pair to -dir
+  baseline VERSION mark migrations up to VERSION as applied without running them,
+                   for databases created before migrations were tracked
+`
+
+// runMigrate implements the migrate subcommand and returns the exit code
+func runMigrate(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, migrateUsage)
+		return 2
+	}
+	command, args := args[0], args[1:]
+
+	if command == "create" {
+		fs := flag.NewFlagSet("migrate create", flag.ContinueOnError)
+		dir := fs.String("dir", "migrations", "directory with the migration files")
+		if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+			fmt.Fprint(os.Stderr, migrateUsage)
+			return 2
+		}
+		up, down, err := migrate.Create(*dir, fs.Arg(0))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "migrate create:", err)
+			return 1
+		}
+		fmt.Println(up)
+		fmt.Println(down)
+		return 0
+	}
+
+	fs := flag.NewFlagSet("migrate "+command, flag.ContinueOnError)
+	cfg, err := config.Load(fs, args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "config error:", err)
+		return 2
+	}
+	if cfg.Database.Driver != "postgres" {
+		fmt.Fprintf(os.Stderr, "migrations are for postgres, the %s backend creates its schema itself\n", cfg.Database.Driver)
+		return 2
+	}
+	// migrations may run longer than any statement of the service
+	opts := cfg.Database.Options()
+	opts.StatementTimeout = 0
+	db, err := pkg.Open(context.Background(), cfg.Database.DSN, opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "db error:", err)
+		return 1
+	}
+	defer sqlDB.Close()
+	migrator, err := migrate.New(sqlDB, migrations.FS)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "migrations:", err)
+		return 1
+	}
+
+	ctx := context.Background()
+	switch command {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			fmt.Printf("applied %03d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "migrate up:", err)
+			return 1
+		}
+		if len(applied) == 0 {
+			fmt.Println("no pending migrations")
+		}
+	case "down":
+		steps := 1
+		if fs.NArg() > 0 {
+			if steps, err = strconv.Atoi(fs.Arg(0)); err != nil || steps < 1 {
+				fmt.Fprint(os.Stderr, migrateUsage)
+				return 2
+			}
+		}
+		rolledBack, err := migrator.Down(ctx, steps)
+		for _, m := range rolledBack {
+			fmt.Printf("rolled back %03d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "migrate down:", err)
+			return 1
+		}
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "migrate status:", err)
+			return 1
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%03d_%-32s %s\n", s.Version, s.Name, applied)
+		}
+	case "baseline":
+		version, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+		if err != nil {
+			fmt.Fprint(os.Stderr, migrateUsage)
+			return 2
+		}
+		if err := migrator.Baseline(ctx, version); err != nil {
+			fmt.Fprintln(os.Stderr, "migrate baseline:", err)
+			return 1
+		}
+	default:
+		fmt.Fprint(os.Stderr, migrateUsage)
+		return 2
+	}
+	return 0
+}
+
+// startupMigrations applies or checks migrations before the server starts,
+// according to the database.migrations setting
+func startupMigrations(ctx context.Context, migrator *migrate.Migrator, mode string) error {
+	switch mode {
+	case "up":
+		_, err := migrator.Up(ctx)
+		return err
+	case "check":
+		return migrator.CheckPending(ctx)
+	}
+	return nil
+}
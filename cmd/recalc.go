@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"subscriptions_service_golang/internal/models"
+	"subscriptions_service_golang/internal/services"
+	"subscriptions_service_golang/pkg/config"
+)
+
+// runRecalcTotals recalculates the total cost of every user, or of one
+// with -user-id, over a period using the same billing rules as
+// GET /subscriptions/total, and prints one "user_id total" line per user.
+// With -rebuild the monthly_spend rollup is rebuilt first.
+func runRecalcTotals(args []string) int {
+	fs := flag.NewFlagSet("recalc-totals", flag.ContinueOnError)
+	userID := fs.String("user-id", "", "only this user")
+	from := fs.String("from", "", "first day of the period (YYYY-MM-DD), unbounded when empty")
+	to := fs.String("to", "", "last day of the period (YYYY-MM-DD), the current month when empty")
+	rebuild := fs.Bool("rebuild", false, "rebuild the monthly_spend rollup from the subscriptions first")
+	cfg, err := config.Load(fs, args)
+	if err != nil {
+		return fail("recalc-totals", err)
+	}
+	fromTime, err := parseDateFlag("from", *from)
+	if err != nil {
+		return fail("recalc-totals", err)
+	}
+	toTime, err := parseDateFlag("to", *to)
+	if err != nil {
+		return fail("recalc-totals", err)
+	}
+
+	ctx := context.Background()
+	st, err := openStore(ctx, cfg)
+	if err != nil {
+		return fail("recalc-totals", err)
+	}
+	defer st.Close()
+
+	if *rebuild {
+		count, err := services.NewMonthlySpendRollup(st.subscriptions).Rebuild(ctx)
+		if err != nil {
+			return fail("recalc-totals", err)
+		}
+		fmt.Fprintf(os.Stderr, "rebuilt monthly_spend for %d subscriptions\n", count)
+	}
+
+	users := map[string]bool{}
+	err = st.subscriptions.Iterate(ctx, models.SubscriptionFilter{UserID: *userID}, func(sub models.Subscription) error {
+		users[sub.UserID] = true
+		return nil
+	})
+	if err != nil {
+		return fail("recalc-totals", err)
+	}
+	ids := make([]string, 0, len(users))
+	for id := range users {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		total, err := st.service.TotalPrice(ctx, id, "", fromTime, toTime)
+		if err != nil {
+			return fail("recalc-totals", err)
+		}
+		fmt.Printf("%s %d\n", id, total)
+	}
+	return 0
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"subscriptions_service_golang/internal/models"
+	"subscriptions_service_golang/pkg/config"
+)
+
+// demoUser owns the demo subscriptions unless seed is given -user-id
+const demoUser = "60601fee-2bf1-4721-ae6f-7636e79a0cba"
+
+// demoSubscriptions replaced 002_seed_data.sql; ids are assigned by the database
+var demoSubscriptions = []struct {
+	service string
+	price   int
+	start   string
+}{
+	{"Yandex Plus", 4500, "2025-07-01"},
+	{"Netflix", 5600, "2025-07-01"},
+	{"Spotify", 3000, "2025-08-01"},
+	{"YouTube Premium", 3500, "2025-09-01"},
+	{"Apple Music", 4000, "2025-10-01"},
+	{"Google One", 2500, "2025-11-01"},
+	{"Amazon Prime", 5000, "2025-12-01"},
+}
+
+// runSeed creates the demo subscriptions for a user who has none yet, so
+// running it twice does not duplicate them
+func runSeed(args []string) int {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	userID := fs.String("user-id", demoUser, "owner of the demo subscriptions")
+	ctx := context.Background()
+	cfg, err := config.Load(fs, args)
+	if err != nil {
+		return fail("seed", err)
+	}
+	st, err := openStore(ctx, cfg)
+	if err != nil {
+		return fail("seed", err)
+	}
+	defer st.Close()
+
+	existing, err := st.service.List(ctx, models.SubscriptionFilter{UserID: *userID})
+	if err != nil {
+		return fail("seed", err)
+	}
+	if len(existing) > 0 {
+		fmt.Printf("user %s already has %d subscriptions, nothing to do\n", *userID, len(existing))
+		return 0
+	}
+
+	service := st.cachedService(nil)
+	for _, demo := range demoSubscriptions {
+		start, _ := time.Parse("2006-01-02", demo.start)
+		sub, err := service.Create(ctx, models.Subscription{
+			ServiceName: demo.service,
+			Price:       demo.price,
+			UserID:      *userID,
+			StartDate:   start,
+		}, false)
+		if err != nil {
+			return fail("seed", err)
+		}
+		fmt.Printf("created %d %s\n", sub.ID, sub.ServiceName)
+	}
+	return 0
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"subscriptions_service_golang/docs"
+	"subscriptions_service_golang/internal/events"
+	"subscriptions_service_golang/internal/handlers"
+	"subscriptions_service_golang/internal/health"
+	"subscriptions_service_golang/internal/jobs"
+	"subscriptions_service_golang/internal/middleware"
+	"subscriptions_service_golang/internal/models"
+	"subscriptions_service_golang/internal/services"
+	"subscriptions_service_golang/pkg/config"
+	"subscriptions_service_golang/pkg/logger"
+	"subscriptions_service_golang/pkg/server"
+	"subscriptions_service_golang/pkg/tracing"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	swaggerFiles "github.com/swaggo/files"
+	ginSwagger "github.com/swaggo/gin-swagger"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel"
+	"go.uber.org/zap"
+)
+
+// runServe starts the HTTP API with the background jobs and returns the
+// exit code once it has shut down
+func runServe(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	checkOnly := fs.Bool("healthcheck", false, "probe the running server's readiness endpoint and exit")
+	printConfig := fs.Bool("print-config", false, "print the effective configuration with secrets masked and exit")
+	cfg, err := config.Load(fs, args)
+	if err != nil {
+		log.Fatalf("config error:\n%v", err)
+	}
+	serverCfg := cfg.HTTP.Server()
+	switch {
+	case *checkOnly:
+		return healthcheck(serverCfg)
+	case *printConfig:
+		fmt.Print(cfg.Redacted())
+		return 0
+	}
+
+	if err := logger.Init(cfg.Log.Level, cfg.Log.Format); err != nil {
+		log.Fatalf("logger error: %v", err)
+	}
+	defer logger.Log.Sync()
+
+	r := gin.New()
+	// without trusted proxies X-Forwarded-For is ignored, otherwise any client
+	// could pick the address rate limits and login lockouts key on
+	if err := r.SetTrustedProxies(cfg.HTTP.TrustedProxies); err != nil {
+		log.Fatalf("http error: %v", err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := tracing.Init(ctx, cfg.Tracing.Exporter)
+	if err != nil {
+		log.Fatalf("tracing error: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	st, err := openStore(ctx, cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	queryTimeout := cfg.Database.QueryTimeout
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	baseService := st.service
+	service := services.NewMetricsService(services.NewTracingService(st.cachedService(registry), otel.GetTracerProvider()), registry)
+	registry.MustRegister(services.NewStatsCollector(baseService, queryTimeout))
+	handler := handlers.NewSubscriptionHandler(service)
+
+	bus := events.NewBus()
+	bus.Subscribe(events.SubscriptionExpired, func(e events.Event) {
+		logger.Log.Info("Subscription expired", zap.Uint("subscription_id", e.SubscriptionID), zap.String("user_id", e.UserID))
+	})
+
+	expiryInterval := cfg.Jobs.ExpiryInterval
+	sqlDB := st.sqlDB
+	if sqlDB != nil {
+		registry.MustRegister(collectors.NewDBStatsCollector(sqlDB, "subscriptions"))
+	}
+
+	// background workers outlive the signal until the HTTP server has drained
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	// only Postgres is shared between replicas, the other backends run a single one
+	var expiryLeader jobs.Leader = jobs.AlwaysLeader{}
+	releaseLeader := func() {}
+	if cfg.Database.Driver == "postgres" {
+		advisory := jobs.NewAdvisoryLeader(sqlDB, jobs.ExpiryLockKey)
+		expiryLeader, releaseLeader = advisory, advisory.Release
+	}
+	expiryJob := jobs.NewExpiryJob(service, expiryLeader, bus, expiryInterval)
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		expiryJob.Run(jobsCtx)
+	}()
+
+	// shares the expiry lock: both jobs only need one replica to run them
+	rollupJob := jobs.NewMonthlySpendJob(services.NewMonthlySpendRollup(st.subscriptions), expiryLeader, time.Hour)
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		rollupJob.Run(jobsCtx)
+	}()
+
+	idempotencyRepo := st.idempotency
+	idempotencyTTL := 24 * time.Hour
+	cleanupJob := jobs.NewIdempotencyCleanupJob(idempotencyRepo, idempotencyTTL, time.Hour)
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		cleanupJob.Run(jobsCtx)
+	}()
+
+	loginCleanupJob := jobs.NewLoginCleanupJob(st.loginAttempts, expiryLeader, cfg.Auth.FailureWindow, cfg.Auth.AttemptRetention, time.Hour)
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		loginCleanupJob.Run(jobsCtx)
+	}()
+
+	checker := health.NewChecker(2 * time.Second)
+	if sqlDB != nil {
+		checker.Add("database", health.Ping(sqlDB))
+	}
+	if st.migrator != nil {
+		checker.Add("migrations", st.migrator.CheckPending)
+	}
+	checker.Add("expiry_job", health.Worker(expiryJob, 2*expiryInterval+time.Minute))
+	checker.Add("monthly_spend_job", health.Worker(rollupJob, 2*time.Hour))
+	checker.Add("idempotency_cleanup_job", health.Worker(cleanupJob, 2*time.Hour))
+	checker.Add("login_cleanup_job", health.Worker(loginCleanupJob, 2*time.Hour))
+	healthHandler := handlers.NewHealthHandler(checker)
+	// a request cannot outlive the write timeout, an older reservation was
+	// left behind by an instance that crashed
+	idempotencyLease := time.Minute
+	if cfg.HTTP.WriteTimeout > idempotencyLease {
+		idempotencyLease = cfg.HTTP.WriteTimeout
+	}
+	idempotent := middleware.Idempotency(idempotencyRepo, idempotencyTTL, idempotencyLease)
+
+	docs.SwaggerInfo.Title = "Subscription API"
+	docs.SwaggerInfo.Description = "API for managing subscriptions"
+	docs.SwaggerInfo.Version = "1.0"
+	docs.SwaggerInfo.Host = cfg.Swagger.Host
+	docs.SwaggerInfo.BasePath = "/"
+	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+	r.GET("/healthz", healthHandler.Healthz)
+	r.GET("/readyz", healthHandler.Readyz)
+	// a failed stats query drops its gauges, not the whole scrape
+	r.GET("/metrics", gin.WrapH(promhttp.HandlerFor(registry, promhttp.HandlerOpts{ErrorHandling: promhttp.ContinueOnError})))
+	r.Use(middleware.Metrics(registry))
+	r.Use(otelgin.Middleware(tracing.ServiceName))
+	r.Use(middleware.RequestID(), middleware.AccessLog())
+	r.Use(middleware.ErrorHandler(), middleware.Recovery())
+
+	rateLimit := st.rateLimit(cfg.RateLimit)
+	authHandler := handlers.NewUserAuthHandler(st.auth)
+	r.POST("/login", rateLimit, authHandler.Login)
+
+	// after a write the caller reads from the primary until replicas catch up
+	readYourWrites := middleware.ReadYourWrites(middleware.NewWriteTracker(cfg.Database.ReadYourWrites))
+
+	auth := r.Group("/")
+	auth.Use(middleware.TokenAuth(st.auth, cfg.Auth.StaticCredentials, true), rateLimit, readYourWrites)
+	{
+		auth.POST("/subscriptions", idempotent, handler.Create)
+		auth.POST("/subscriptions/import", idempotent, handler.Import)
+		auth.POST("/subscriptions/bulk", idempotent, handler.Bulk)
+		auth.PUT("/subscriptions/:id", handler.Update)
+		auth.DELETE("/subscriptions/:id", handler.Delete)
+		auth.POST("/subscriptions/:id/pause", handler.Pause)
+		auth.POST("/subscriptions/:id/resume", handler.Resume)
+		auth.POST("/subscriptions/:id/cancel", handler.Cancel)
+	}
+
+	// r.POST("/subscriptions", handler.Create)
+	// r.PUT("/subscriptions/:id", handler.Update)
+	// r.DELETE("/subscriptions/:id", handler.Delete)
+	optional := r.Group("/")
+	optional.Use(middleware.TokenAuth(st.auth, cfg.Auth.StaticCredentials, false), rateLimit, readYourWrites)
+	{
+
+		optional.GET("/subscriptions/:id", handler.GetByID)
+		optional.GET("/subscriptions", handler.List)
+		optional.GET("/subscriptions/total", handler.TotalPrice)
+		optional.GET("/subscriptions/export", handler.Export)
+		optional.GET("/users/:id/duplicates", handler.UserDuplicates)
+	}
+
+	adminHandler := handlers.NewAdminHandler(st.auth)
+	admin := r.Group("/admin")
+	admin.Use(middleware.TokenAuth(st.auth, cfg.Auth.StaticCredentials, true), middleware.RequireRole(models.RoleAdmin), rateLimit)
+	{
+		admin.GET("/lockouts", adminHandler.ListLockouts)
+		admin.DELETE("/lockouts", adminHandler.ClearLockout)
+		admin.GET("/login-attempts", adminHandler.ListLoginAttempts)
+	}
+
+	srv := server.New(serverCfg, r)
+	logger.Log.Info("Server started", zap.String("addr", serverCfg.Addr), zap.Bool("tls", serverCfg.TLS()))
+	serveErr := server.Run(ctx, srv, serverCfg)
+
+	logger.Log.Info("Shutting down")
+	stopJobs()
+	workers.Wait()
+	releaseLeader()
+	if err := st.Close(); err != nil {
+		logger.Log.Error("Failed to close database", zap.Error(err))
+	}
+	if serveErr != nil {
+		logger.Log.Error("Server error", zap.Error(serveErr))
+		return 1
+	}
+	return 0
+}
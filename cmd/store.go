@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"subscriptions_service_golang/internal/cache"
+	"subscriptions_service_golang/internal/middleware"
+	"subscriptions_service_golang/internal/ratelimit"
+	"subscriptions_service_golang/internal/repositories"
+	"subscriptions_service_golang/internal/services"
+	"subscriptions_service_golang/migrations"
+	"subscriptions_service_golang/pkg"
+	"subscriptions_service_golang/pkg/config"
+	"subscriptions_service_golang/pkg/migrate"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// store is the database with the repositories and services built on it.
+// Every command opens it the same way, so the CLI goes through the same
+// validation and business rules as the HTTP API. db and sqlDB are nil for
+// the memory backend, migrator is set for Postgres only.
+type store struct {
+	db            *gorm.DB
+	sqlDB         *sql.DB
+	migrator      *migrate.Migrator
+	subscriptions repositories.SubscriptionRepository
+	idempotency   repositories.IdempotencyRepository
+	users         repositories.UserRepository
+	apiKeys       repositories.APIKeyRepository
+	loginAttempts repositories.LoginAttemptRepository
+	service       services.SubscriptionService
+	auth          services.AuthService
+	cache         cache.Cache
+	cacheTTL      time.Duration
+	redis         *redis.Client
+}
+
+// openStore connects to the database selected by database.driver. For
+// Postgres it applies or checks migrations according to
+// database.migrations; SQLite gets its schema from the models and the
+// memory backend needs none. Postgres queries go through a circuit
+// breaker unless database.breaker_failures is 0.
+func openStore(ctx context.Context, cfg config.Config) (*store, error) {
+	timeout := cfg.Database.QueryTimeout
+	s := &store{}
+	switch cfg.Database.Driver {
+	case "memory":
+		s.subscriptions = repositories.NewMemorySubscriptionRepository()
+		s.idempotency = repositories.NewMemoryIdempotencyRepository()
+		s.users = repositories.NewMemoryUserRepository()
+		s.apiKeys = repositories.NewMemoryAPIKeyRepository()
+		s.loginAttempts = repositories.NewMemoryLoginAttemptRepository()
+	case "sqlite":
+		s.db = pkg.InitSQLite(cfg.Database.DSN)
+	default:
+		db, err := pkg.Open(ctx, cfg.Database.DSN, cfg.Database.Options())
+		if err != nil {
+			return nil, err
+		}
+		s.db = db
+	}
+
+	if s.db != nil {
+		sqlDB, err := s.db.DB()
+		if err != nil {
+			return nil, fmt.Errorf("db error: %w", err)
+		}
+		s.sqlDB = sqlDB
+		if cfg.Database.Driver == "postgres" && cfg.Database.BreakerFailures > 0 {
+			breaker := repositories.NewCircuitBreaker(cfg.Database.BreakerFailures, cfg.Database.BreakerCooldown)
+			if err := s.db.Use(breaker); err != nil {
+				s.Close()
+				return nil, fmt.Errorf("db error: %w", err)
+			}
+		}
+		s.subscriptions = repositories.NewSubscriptionRepository(s.db, timeout)
+		s.idempotency = repositories.NewIdempotencyRepository(s.db)
+		s.users = repositories.NewUserRepository(s.db, timeout)
+		s.apiKeys = repositories.NewAPIKeyRepository(s.db, timeout)
+		s.loginAttempts = repositories.NewLoginAttemptRepository(s.db, timeout)
+	}
+	if cfg.Database.Driver == "postgres" {
+		migrator, err := migrate.New(s.sqlDB, migrations.FS)
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("migrations error: %w", err)
+		}
+		if err := startupMigrations(ctx, migrator, cfg.Database.Migrations); err != nil {
+			s.Close()
+			return nil, fmt.Errorf("migrations error: %w (run \"migrate up\" or set DB_MIGRATIONS=up)", err)
+		}
+		s.migrator = migrator
+	}
+
+	// one client serves the cache and the rate limiter
+	if cfg.Cache.Backend == "redis" || cfg.RateLimit.Backend == "redis" {
+		opts, err := redis.ParseURL(cfg.Redis.URL)
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("redis error: %w", err)
+		}
+		s.redis = redis.NewClient(opts)
+	}
+	switch cfg.Cache.Backend {
+	case "memory":
+		s.cache = cache.NewLRU(cfg.Cache.Size)
+	case "redis":
+		s.cache = cache.NewRedis(s.redis, "subscriptions:cache:")
+	}
+	s.cacheTTL = cfg.Cache.TTL
+
+	s.service = services.NewSubscriptionService(s.subscriptions)
+	policy := services.LoginPolicy{
+		MaxFailures:   cfg.Auth.MaxFailures,
+		MaxIPFailures: cfg.Auth.MaxIPFailures,
+		Delay:         cfg.Auth.FailureDelay,
+		Lockout:       cfg.Auth.Lockout,
+		Window:        cfg.Auth.FailureWindow,
+	}
+	s.auth = services.NewAuthService(s.users, s.apiKeys, s.loginAttempts, policy, cfg.Auth.SessionTTL, cfg.Auth.StaticCredentials)
+	return s, nil
+}
+
+// cachedService wraps the service with the cache of totals and stats when
+// one is configured. Commands changing subscriptions use it too, so that
+// they invalidate what a shared Redis cache holds. reg may be nil.
+func (s *store) cachedService(reg prometheus.Registerer) services.SubscriptionService {
+	if s.cache == nil {
+		return s.service
+	}
+	return services.NewCachingService(s.service, s.cache, s.cacheTTL, reg)
+}
+
+// rateLimit returns the rate limiting middleware, a no-op for the none
+// backend. Buckets live in Redis when it is shared by the replicas.
+func (s *store) rateLimit(cfg config.RateLimitConfig) gin.HandlerFunc {
+	// the rules were checked when the configuration was loaded
+	rules, _ := cfg.Rules()
+	switch cfg.Backend {
+	case "memory":
+		return middleware.RateLimit(ratelimit.NewMemory(), rules)
+	case "redis":
+		return middleware.RateLimit(ratelimit.NewRedis(s.redis, "subscriptions:ratelimit:"), rules)
+	}
+	return func(c *gin.Context) { c.Next() }
+}
+
+func (s *store) Close() error {
+	if s.redis != nil {
+		s.redis.Close()
+	}
+	if s.sqlDB == nil {
+		return nil
+	}
+	return s.sqlDB.Close()
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"subscriptions_service_golang/internal/models"
+	"subscriptions_service_golang/internal/services"
+	"subscriptions_service_golang/pkg/config"
+)
+
+const subsUsage = `usage: subscription-service subs <command> [flags] [args]
+
+commands:
+  import [-format csv|ndjson] [-dry-run] [-allow-duplicate] FILE
+        import subscriptions, FILE "-" reads stdin; the format is taken
+        from the file extension when -format is omitted
+  export [-format csv|xlsx|ndjson] [-o FILE] [-user-id ID] [-service-name NAME]
+         [-from YYYY-MM-DD] [-to YYYY-MM-DD] [-active]
+        export subscriptions to FILE or stdout
+`
+
+// runSubs implements the subs subcommand and returns the exit code
+func runSubs(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, subsUsage)
+		return 2
+	}
+	switch args[0] {
+	case "import":
+		return runSubsImport(args[1:])
+	case "export":
+		return runSubsExport(args[1:])
+	}
+	fmt.Fprint(os.Stderr, subsUsage)
+	return 2
+}
+
+func runSubsImport(args []string) int {
+	fs := flag.NewFlagSet("subs import", flag.ContinueOnError)
+	format := fs.String("format", "", "csv or ndjson")
+	var opts services.ImportOptions
+	fs.BoolVar(&opts.DryRun, "dry-run", false, "validate rows without saving them")
+	fs.BoolVar(&opts.AllowDuplicate, "allow-duplicate", false, "import rows overlapping existing subscriptions instead of skipping them")
+	cfg, err := config.Load(fs, args)
+	if err != nil {
+		return fail("subs import", err)
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprint(os.Stderr, subsUsage)
+		return 2
+	}
+
+	path := fs.Arg(0)
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	}
+	var in io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return fail("subs import", err)
+		}
+		defer f.Close()
+		in = f
+	}
+	var rows []services.ImportRow
+	switch *format {
+	case "csv":
+		rows, err = services.ParseImportCSV(in)
+	case "ndjson", "jsonl":
+		rows, err = services.ParseImportNDJSON(in)
+	default:
+		return fail("subs import", fmt.Errorf("format must be csv or ndjson, got %q", *format))
+	}
+	if err != nil {
+		return fail("subs import", err)
+	}
+
+	ctx := context.Background()
+	st, err := openStore(ctx, cfg)
+	if err != nil {
+		return fail("subs import", err)
+	}
+	defer st.Close()
+	report, err := st.cachedService(nil).Import(ctx, rows, opts)
+	if err != nil {
+		return fail("subs import", err)
+	}
+	for _, row := range report.Rows {
+		if row.Status != services.ImportCreated {
+			fmt.Fprintf(os.Stderr, "line %d: %s: %s\n", row.Line, row.Status, row.Reason)
+		}
+	}
+	fmt.Printf("created %d, skipped %d, failed %d", report.Created, report.Skipped, report.Failed)
+	if report.DryRun {
+		fmt.Print(" (dry run, nothing saved)")
+	}
+	fmt.Println()
+	if report.Failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+func runSubsExport(args []string) int {
+	fs := flag.NewFlagSet("subs export", flag.ContinueOnError)
+	format := fs.String("format", "csv", "csv, xlsx or ndjson")
+	output := fs.String("o", "-", "output file, - for stdout")
+	var filter models.SubscriptionFilter
+	fs.StringVar(&filter.UserID, "user-id", "", "only subscriptions of this user")
+	fs.StringVar(&filter.ServiceName, "service-name", "", "only subscriptions to this service")
+	fs.BoolVar(&filter.Active, "active", false, "only trial and active subscriptions that have not ended")
+	from := fs.String("from", "", "only subscriptions active on or after this date (YYYY-MM-DD)")
+	to := fs.String("to", "", "only subscriptions active on or before this date (YYYY-MM-DD)")
+	cfg, err := config.Load(fs, args)
+	if err != nil {
+		return fail("subs export", err)
+	}
+	if _, ok := services.ExportContentTypes[*format]; !ok {
+		return fail("subs export", fmt.Errorf("format must be csv, xlsx or ndjson, got %q", *format))
+	}
+	if filter.From, err = parseDateFlag("from", *from); err != nil {
+		return fail("subs export", err)
+	}
+	if filter.To, err = parseDateFlag("to", *to); err != nil {
+		return fail("subs export", err)
+	}
+
+	ctx := context.Background()
+	st, err := openStore(ctx, cfg)
+	if err != nil {
+		return fail("subs export", err)
+	}
+	defer st.Close()
+
+	var out io.Writer = os.Stdout
+	if *output != "-" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return fail("subs export", err)
+		}
+		defer f.Close()
+		out = f
+	}
+	if err := st.service.Export(ctx, filter, *format, out); err != nil {
+		return fail("subs export", err)
+	}
+	return 0
+}
+
+// parseDateFlag parses an optional YYYY-MM-DD flag value
+func parseDateFlag(name, value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, fmt.Errorf("-%s %q is not YYYY-MM-DD", name, value)
+	}
+	return &t, nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"subscriptions_service_golang/internal/models"
+	"subscriptions_service_golang/pkg/config"
+)
+
+const userUsage = `usage: subscription-service user create -username NAME [-role user|admin] [-password PASSWORD]
+
+Without -password the password is read from the first line of stdin, which
+keeps it out of the shell history and the process list.
+`
+
+const apiKeyUsage = `usage: subscription-service apikey issue -username NAME -name KEY_NAME [-ttl DURATION]
+
+The key is printed once and only its hash is stored. -ttl 0 issues a key
+that never expires.
+`
+
+// runUser implements the user subcommand and returns the exit code
+func runUser(args []string) int {
+	if len(args) == 0 || args[0] != "create" {
+		fmt.Fprint(os.Stderr, userUsage)
+		return 2
+	}
+	fs := flag.NewFlagSet("user create", flag.ContinueOnError)
+	username := fs.String("username", "", "login name")
+	password := fs.String("password", "", "password, read from stdin when empty")
+	role := fs.String("role", string(models.RoleUser), "user or admin")
+	ctx := context.Background()
+	cfg, err := config.Load(fs, args[1:])
+	if err != nil {
+		return fail("user create", err)
+	}
+	if *username == "" {
+		fmt.Fprint(os.Stderr, userUsage)
+		return 2
+	}
+	st, err := openStore(ctx, cfg)
+	if err != nil {
+		return fail("user create", err)
+	}
+	defer st.Close()
+
+	if *password == "" {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return fail("user create", fmt.Errorf("reading password from stdin: %w", err))
+		}
+		*password = strings.TrimRight(line, "\r\n")
+	}
+
+	user, err := st.auth.CreateUser(ctx, *username, *password, models.UserRole(*role))
+	if err != nil {
+		return fail("user create", err)
+	}
+	fmt.Printf("created user %s %s (%s)\n", user.ID, user.Username, user.Role)
+	return 0
+}
+
+// runAPIKey implements the apikey subcommand and returns the exit code
+func runAPIKey(args []string) int {
+	if len(args) == 0 || args[0] != "issue" {
+		fmt.Fprint(os.Stderr, apiKeyUsage)
+		return 2
+	}
+	fs := flag.NewFlagSet("apikey issue", flag.ContinueOnError)
+	username := fs.String("username", "", "owner of the key")
+	name := fs.String("name", "", "what the key is for, shown when listing keys")
+	ttl := fs.Duration("ttl", 0, "key lifetime, 0 never expires")
+	ctx := context.Background()
+	cfg, err := config.Load(fs, args[1:])
+	if err != nil {
+		return fail("apikey issue", err)
+	}
+	if *username == "" || *name == "" {
+		fmt.Fprint(os.Stderr, apiKeyUsage)
+		return 2
+	}
+	st, err := openStore(ctx, cfg)
+	if err != nil {
+		return fail("apikey issue", err)
+	}
+	defer st.Close()
+
+	token, key, err := st.auth.IssueAPIKey(ctx, *username, *name, *ttl)
+	if err != nil {
+		return fail("apikey issue", err)
+	}
+	expires := "never"
+	if key.ExpiresAt != nil {
+		expires = key.ExpiresAt.Format(time.RFC3339)
+	}
+	// the token goes to stdout alone so it can be captured by a script
+	fmt.Fprintf(os.Stderr, "issued key %d %q for %s, expires %s; it is not shown again\n", key.ID, key.Name, *username, expires)
+	fmt.Println(token)
+	return 0
+}
@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testCache checks the behaviour every Cache shares; advance moves the
+// cache's clock forward
+func testCache(t *testing.T, c Cache, advance func(time.Duration)) {
+	ctx := context.Background()
+
+	_, ok, err := c.Get(ctx, "missing")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, c.Set(ctx, "total", []byte("400"), time.Minute))
+	value, ok, err := c.Get(ctx, "total")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "400", string(value))
+
+	require.NoError(t, c.Set(ctx, "total", []byte("500"), time.Minute))
+	value, _, _ = c.Get(ctx, "total")
+	assert.Equal(t, "500", string(value), "set replaces the value")
+
+	advance(time.Minute)
+	_, ok, err = c.Get(ctx, "total")
+	require.NoError(t, err)
+	assert.False(t, ok, "entries expire after their ttl")
+}
+
+func TestLRU(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewLRU(2)
+	c.now = func() time.Time { return now }
+	testCache(t, c, func(d time.Duration) { now = now.Add(d) })
+
+	ctx := context.Background()
+	c.Set(ctx, "a", []byte("1"), time.Minute)
+	c.Set(ctx, "b", []byte("2"), time.Minute)
+	c.Get(ctx, "a")
+	c.Set(ctx, "c", []byte("3"), time.Minute)
+	_, ok, _ := c.Get(ctx, "b")
+	assert.False(t, ok, "the least recently used entry is evicted")
+	_, ok, _ = c.Get(ctx, "a")
+	assert.True(t, ok)
+	assert.Equal(t, 2, c.Len())
+}
+
+func TestRedis(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	c := NewRedis(client, "subscriptions:")
+	testCache(t, c, server.FastForward)
+
+	c.Set(context.Background(), "stats", []byte("{}"), time.Minute)
+	assert.True(t, server.Exists("subscriptions:stats"), "keys are prefixed")
+
+	server.Close()
+	_, _, err := c.Get(context.Background(), "stats")
+	assert.Error(t, err, "errors of the server are returned")
+}
@@ -0,0 +1,52 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Type identifies the kind of event
+type Type string
+
+const (
+	SubscriptionExpired Type = "subscription.expired"
+)
+
+// Event describes something that happened to a subscription
+type Event struct {
+	Type           Type      `json:"type"`
+	SubscriptionID uint      `json:"subscription_id"`
+	UserID         string    `json:"user_id"`
+	OccurredAt     time.Time `json:"occurred_at"`
+}
+
+// Handler is called for every published event it is subscribed to
+type Handler func(Event)
+
+// Bus is an in-process publish/subscribe dispatcher.
+// Handlers run synchronously in the publisher's goroutine.
+type Bus struct {
+	mu       sync.RWMutex
+	handlers map[Type][]Handler
+}
+
+func NewBus() *Bus {
+	return &Bus{handlers: make(map[Type][]Handler)}
+}
+
+// Subscribe registers h for events of type t
+func (b *Bus) Subscribe(t Type, h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[t] = append(b.handlers[t], h)
+}
+
+// Publish delivers e to every handler subscribed to its type
+func (b *Bus) Publish(e Event) {
+	b.mu.RLock()
+	handlers := b.handlers[e.Type]
+	b.mu.RUnlock()
+	for _, h := range handlers {
+		h(e)
+	}
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"subscriptions_service_golang/internal/models"
+	"subscriptions_service_golang/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminHandler serves the endpoints reserved for admins
+type AdminHandler struct {
+	auth services.AuthService
+}
+
+func NewAdminHandler(auth services.AuthService) *AdminHandler {
+	return &AdminHandler{auth: auth}
+}
+
+// ListLockouts godoc
+// @Summary List locked logins
+// @Description Usernames ("user:<name>") and client addresses ("ip:<addr>") that cannot log in right now, the longest locked first
+// @Tags admin
+// @Produce json
+// @Success 200 {array} models.LoginLockout
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /admin/lockouts [get]
+func (h *AdminHandler) ListLockouts(c *gin.Context) {
+	lockouts, err := h.auth.Lockouts(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if lockouts == nil {
+		lockouts = []models.LoginLockout{}
+	}
+	c.JSON(http.StatusOK, lockouts)
+}
+
+// ClearLockout godoc
+// @Summary Clear a login lockout
+// @Description Lifts the lock of a username, an address or both and forgets their failed logins
+// @Tags admin
+// @Param username query string false "Username"
+// @Param ip query string false "Client address"
+// @Success 204
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /admin/lockouts [delete]
+func (h *AdminHandler) ClearLockout(c *gin.Context) {
+	if err := h.auth.ClearLockout(c.Request.Context(), c.Query("username"), c.Query("ip")); err != nil {
+		c.Error(err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListLoginAttempts godoc
+// @Summary Login audit log
+// @Description Login attempts, the newest first: success, failure or locked (rejected without checking the password)
+// @Tags admin
+// @Produce json
+// @Param username query string false "Username, case-insensitive"
+// @Param ip query string false "Client address"
+// @Param limit query int false "Maximum entries, at most 500" default(100)
+// @Success 200 {array} models.LoginAttempt
+// @Failure 400 {object} models.Problem
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /admin/login-attempts [get]
+func (h *AdminHandler) ListLoginAttempts(c *gin.Context) {
+	filter := models.LoginAttemptFilter{Username: c.Query("username"), IP: c.Query("ip"), Limit: 100}
+	if s := c.Query("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit <= 0 {
+			c.Error(fmt.Errorf("%w: limit must be a positive number", services.ErrValidation))
+			return
+		}
+		filter.Limit = limit
+	}
+	attempts, err := h.auth.LoginAttempts(c.Request.Context(), filter)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if attempts == nil {
+		attempts = []models.LoginAttempt{}
+	}
+	c.JSON(http.StatusOK, attempts)
+}
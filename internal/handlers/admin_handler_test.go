@@ -0,0 +1,93 @@
+package handlers
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "strconv"
+    "testing"
+    "time"
+
+    "subscriptions_service_golang/internal/middleware"
+    "subscriptions_service_golang/internal/models"
+    "subscriptions_service_golang/internal/repositories"
+    "subscriptions_service_golang/internal/services"
+
+    "github.com/gin-gonic/gin"
+    "github.com/stretchr/testify/assert"
+    "github.com/stretchr/testify/require"
+)
+
+func TestLoginLockoutAdmin(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    auth := services.NewAuthService(
+        repositories.NewMemoryUserRepository(),
+        repositories.NewMemoryAPIKeyRepository(),
+        repositories.NewMemoryLoginAttemptRepository(),
+        services.LoginPolicy{MaxFailures: 2, Lockout: time.Minute, Window: time.Hour},
+        time.Hour,
+        false,
+    )
+    _, err := auth.CreateUser(context.Background(), "alice", "correct-horse", models.RoleUser)
+    require.NoError(t, err)
+
+    router := gin.New()
+    router.Use(middleware.ErrorHandler())
+    router.POST("/login", NewUserAuthHandler(auth).Login)
+    admin := NewAdminHandler(auth)
+    router.GET("/admin/lockouts", admin.ListLockouts)
+    router.DELETE("/admin/lockouts", admin.ClearLockout)
+    router.GET("/admin/login-attempts", admin.ListLoginAttempts)
+
+    login := func(password string) *httptest.ResponseRecorder {
+        body, _ := json.Marshal(LoginRequest{Username: "alice", Password: password})
+        req := httptest.NewRequest(http.MethodPost, "/login", bytes.NewReader(body))
+        req.Header.Set("Content-Type", "application/json")
+        req.RemoteAddr = "10.0.0.1:1234"
+        w := httptest.NewRecorder()
+        router.ServeHTTP(w, req)
+        return w
+    }
+    request := func(method, path string) *httptest.ResponseRecorder {
+        w := httptest.NewRecorder()
+        router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+        return w
+    }
+
+    for range 2 {
+        assert.Equal(t, http.StatusUnauthorized, login("wrong").Code)
+    }
+    w := login("correct-horse")
+    assert.Equal(t, http.StatusTooManyRequests, w.Code, "the right password does not open a locked username")
+    assert.Equal(t, middleware.ProblemContentType, w.Header().Get("Content-Type"))
+    retry, err := strconv.Atoi(w.Header().Get("Retry-After"))
+    require.NoError(t, err)
+    assert.InDelta(t, 60, retry, 1)
+
+    w = request(http.MethodGet, "/admin/lockouts")
+    require.Equal(t, http.StatusOK, w.Code)
+    var lockouts []models.LoginLockout
+    require.NoError(t, json.Unmarshal(w.Body.Bytes(), &lockouts))
+    require.Len(t, lockouts, 1)
+    assert.Equal(t, "user:alice", lockouts[0].Key)
+
+    w = request(http.MethodGet, "/admin/login-attempts?username=ALICE&limit=2")
+    require.Equal(t, http.StatusOK, w.Code)
+    var attempts []models.LoginAttempt
+    require.NoError(t, json.Unmarshal(w.Body.Bytes(), &attempts))
+    require.Len(t, attempts, 2)
+    assert.Equal(t, models.LoginLocked, attempts[0].Result)
+    assert.Equal(t, "10.0.0.1", attempts[0].IP)
+    assert.Equal(t, http.StatusBadRequest, request(http.MethodGet, "/admin/login-attempts?limit=-1").Code)
+
+    assert.Equal(t, http.StatusBadRequest, request(http.MethodDelete, "/admin/lockouts").Code)
+    assert.Equal(t, http.StatusNotFound, request(http.MethodDelete, "/admin/lockouts?username=bob").Code)
+    assert.Equal(t, http.StatusNoContent, request(http.MethodDelete, "/admin/lockouts?username=alice").Code)
+    assert.Equal(t, http.StatusOK, login("correct-horse").Code)
+
+    w = request(http.MethodGet, "/admin/lockouts")
+    assert.Equal(t, http.StatusOK, w.Code)
+    assert.JSONEq(t, "[]", w.Body.String())
+}
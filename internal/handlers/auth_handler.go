@@ -1,25 +1,44 @@
 package handlers
 
 import (
+    "errors"
+    "math"
     "net/http"
+    "strconv"
+    "time"
+
+    "subscriptions_service_golang/internal/services"
 
     "github.com/gin-gonic/gin"
 )
 
-type AuthHandler struct{}
+type AuthHandler struct {
+    auth        services.AuthService
+    allowStatic bool
+}
 
+// NewAuthHandler accepts only the static admin/password credentials
 func NewAuthHandler() *AuthHandler {
-    return &AuthHandler{}
+    return &AuthHandler{allowStatic: true}
+}
+
+// NewUserAuthHandler checks credentials with auth, which also decides
+// whether the static admin/password login is accepted, so that it goes
+// through the same lockouts
+func NewUserAuthHandler(auth services.AuthService) *AuthHandler {
+    return &AuthHandler{auth: auth}
 }
 
 // Login godoc
 // @Summary Get auth token
+// @Description Users created with "subscription-service user create" get a token that expires after auth.session_ttl
 // @Tags auth
 // @Accept json
 // @Produce json
 // @Param credentials body LoginRequest true "Login credentials"
 // @Success 200 {object} TokenResponse
 // @Failure 401 {object} ErrorResponse
+// @Failure 429 {object} models.Problem "Too many failed logins of the username or from the address, see Retry-After"
 // @Router /login [post]
 func (h *AuthHandler) Login(c *gin.Context) {
     var req LoginRequest
@@ -29,11 +48,28 @@ func (h *AuthHandler) Login(c *gin.Context) {
     }
 
     // Static login/password check
-    if req.Username == "admin" && req.Password == "password" {
+    if h.auth == nil && h.allowStatic && req.Username == "admin" && req.Password == "password" {
         c.JSON(http.StatusOK, TokenResponse{Token: "test-token"})
         return
     }
 
+    if h.auth != nil {
+        token, err := h.auth.Login(c.Request.Context(), req.Username, req.Password, c.ClientIP())
+        if err == nil {
+            c.JSON(http.StatusOK, TokenResponse{Token: token})
+            return
+        }
+        var locked *services.LoginLockedError
+        if errors.As(err, &locked) {
+            retry := int(math.Ceil(time.Until(locked.Until).Seconds()))
+            c.Header("Retry-After", strconv.Itoa(max(retry, 1)))
+        }
+        if !errors.Is(err, services.ErrUnauthorized) {
+            c.Error(err)
+            return
+        }
+    }
+
     c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
 }
 
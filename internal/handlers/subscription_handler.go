@@ -1,8 +1,12 @@
 package handlers
 
 import (
+	"fmt"
+	"io"
 	"net/http"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"subscriptions_service_golang/internal/models"
@@ -27,22 +31,26 @@ func NewSubscriptionHandler(service services.SubscriptionService) *SubscriptionH
 // @Accept json
 // @Produce json
 // @Param subscription body models.Subscription true "Subscription object"
+// @Param Idempotency-Key header string false "Retries with the same key replay the first response for 24h"
+// @Param allow_duplicate query bool false "Accept a subscription overlapping an existing one and flag it as duplicate"
 // @Success 201 {object} models.Subscription
-// @Failure 400 {object} map[string]string
-// @Failure 500 {object} map[string]string
+// @Failure 400 {object} models.Problem
+// @Failure 409 {object} models.Problem
+// @Failure 500 {object} models.Problem
 // @Router /subscriptions [post]
 func (h *SubscriptionHandler) Create(c *gin.Context) {
 
 	var req models.Subscription
 	if err := c.ShouldBindJSON(&req); err != nil {
-		logger.Log.Error("Failed to bind JSON", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		logger.FromContext(c.Request.Context()).Error("Failed to bind JSON", zap.Error(err))
+		c.Error(fmt.Errorf("%w: %v", services.ErrValidation, err))
 		return
 	}
-	sub, err := h.service.Create(req)
+	allowDuplicate, _ := strconv.ParseBool(c.Query("allow_duplicate"))
+	sub, err := h.service.Create(c.Request.Context(), req, allowDuplicate)
 	if err != nil {
-		logger.Log.Error("Failed to create subscription", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		logger.FromContext(c.Request.Context()).Error("Failed to create subscription", zap.Error(err))
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusCreated, sub)
@@ -54,21 +62,22 @@ func (h *SubscriptionHandler) Create(c *gin.Context) {
 // @Produce json
 // @Param id path int true "Subscription ID"
 // @Success 200 {object} models.Subscription
-// @Failure 400 {object} models.ErrorResponse
-// @Failure 404 {object} models.ErrorResponse
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
 // @Router /subscriptions/{id} [get]
 func (h *SubscriptionHandler) GetByID(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		logger.Log.Error("Failed to parse id", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		logger.FromContext(c.Request.Context()).Error("Failed to parse id", zap.Error(err))
+		c.Error(fmt.Errorf("%w: invalid id", services.ErrValidation))
 		return
 	}
-	sub, err := h.service.GetByID(uint(id))
+	sub, err := h.service.GetByID(c.Request.Context(), uint(id))
 	if err != nil {
-		logger.Log.Error("Failed to get subscription", zap.Error(err))
-		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		logger.FromContext(c.Request.Context()).Error("Failed to get subscription", zap.Error(err))
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, sub)
@@ -82,34 +91,105 @@ func (h *SubscriptionHandler) GetByID(c *gin.Context) {
 // @Param service_name query string false "Filter by service name"
 // @Param from query string false "Filter from date (YYYY-MM-DD)"
 // @Param to query string false "Filter to date (YYYY-MM-DD)"
+// @Param active query bool false "Only trial and active subscriptions that have not ended"
 // @Success 200 {array} models.Subscription
-// @Failure 500 {object} models.ErrorResponse
+// @Failure 500 {object} models.Problem
 // @Router /subscriptions [get]
 func (h *SubscriptionHandler) List(c *gin.Context) {
-	userID := c.Query("user_id")
-	serviceName := c.Query("service_name")
-	logger.Log.Info("Failed to bind JSON")
-	var fromTime, toTime *time.Time
+	subs, err := h.service.List(c.Request.Context(), listFilter(c))
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Error("Failed to list subscriptions", zap.Error(err))
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, subs)
+}
+
+// listFilter reads the List query parameters; invalid dates are ignored
+func listFilter(c *gin.Context) models.SubscriptionFilter {
+	filter := models.SubscriptionFilter{
+		UserID:      c.Query("user_id"),
+		ServiceName: c.Query("service_name"),
+	}
+	filter.Active, _ = strconv.ParseBool(c.Query("active"))
 	if from := c.Query("from"); from != "" {
 		t, err := time.Parse("2006-01-02", from)
 		if err == nil {
-			fromTime = &t
+			filter.From = &t
 		}
 	}
 	if to := c.Query("to"); to != "" {
 		t, err := time.Parse("2006-01-02", to)
 		if err == nil {
-			toTime = &t
+			filter.To = &t
 		}
 	}
+	return filter
+}
+
+// ExportSubscriptions godoc
+// @Summary Export subscriptions to CSV, XLSX or NDJSON
+// @Description Accepts the same filters as the list endpoint and streams the result as a file
+// @Tags subscriptions
+// @Produce text/csv
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Produce application/x-ndjson
+// @Param format query string true "csv, xlsx or ndjson"
+// @Param user_id query string false "Filter by user ID"
+// @Param service_name query string false "Filter by service name"
+// @Param from query string false "Filter from date (YYYY-MM-DD)"
+// @Param to query string false "Filter to date (YYYY-MM-DD)"
+// @Param active query bool false "Only trial and active subscriptions that have not ended"
+// @Success 200 {file} file
+// @Failure 400 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /subscriptions/export [get]
+func (h *SubscriptionHandler) Export(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	contentType, ok := services.ExportContentTypes[format]
+	if !ok {
+		c.Error(fmt.Errorf("%w: format must be csv, xlsx or ndjson", services.ErrValidation))
+		return
+	}
 
-	subs, err := h.service.List(userID, serviceName, fromTime, toTime)
+	w := &exportWriter{c: c, contentType: contentType, filename: "subscriptions." + format}
+	err := h.service.Export(c.Request.Context(), listFilter(c), format, w)
+	if err != nil && !w.started {
+		logger.FromContext(c.Request.Context()).Error("Failed to export subscriptions", zap.Error(err))
+		c.Error(err)
+		return
+	}
 	if err != nil {
-		logger.Log.Error("Failed to list subscriptions", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		// the file is already partly sent: drop the connection so the client
+		// sees an error instead of a truncated file that looks complete
+		logger.FromContext(c.Request.Context()).Error("Export failed while streaming", zap.Error(err))
+		panic(http.ErrAbortHandler)
+	}
+	w.start()
+}
+
+// exportWriter sends the headers of the attachment with the first bytes of
+// the file, so that errors until then still get a problem response
+type exportWriter struct {
+	c           *gin.Context
+	contentType string
+	filename    string
+	started     bool
+}
+
+func (w *exportWriter) start() {
+	if w.started {
 		return
 	}
-	c.JSON(http.StatusOK, subs)
+	w.started = true
+	w.c.Header("Content-Type", w.contentType)
+	w.c.Header("Content-Disposition", `attachment; filename="`+w.filename+`"`)
+	w.c.Status(http.StatusOK)
+}
+
+func (w *exportWriter) Write(p []byte) (int, error) {
+	w.start()
+	return w.c.Writer.Write(p)
 }
 
 // UpdateSubscription godoc
@@ -120,30 +200,31 @@ func (h *SubscriptionHandler) List(c *gin.Context) {
 // @Param id path int true "Subscription ID"
 // @Param subscription body models.Subscription true "Updated subscription object"
 // @Success 200 {object} models.Subscription
-// @Failure 400 {object} models.ErrorResponse
-// @Failure 500 {object} models.ErrorResponse
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
 // @Router /subscriptions/{id} [put]
 func (h *SubscriptionHandler) Update(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		logger.Log.Error("Failed to parse id", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		logger.FromContext(c.Request.Context()).Error("Failed to parse id", zap.Error(err))
+		c.Error(fmt.Errorf("%w: invalid id", services.ErrValidation))
 		return
 	}
 
 	var req models.Subscription
 	if err := c.ShouldBindJSON(&req); err != nil {
-		logger.Log.Error("Failed to bind JSON", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		logger.FromContext(c.Request.Context()).Error("Failed to bind JSON", zap.Error(err))
+		c.Error(fmt.Errorf("%w: %v", services.ErrValidation, err))
 		return
 	}
 	req.ID = uint(id)
 
-	sub, err := h.service.Update(req)
+	sub, err := h.service.Update(c.Request.Context(), req)
 	if err != nil {
-		logger.Log.Error("Failed to update subscription", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		logger.FromContext(c.Request.Context()).Error("Failed to update subscription", zap.Error(err))
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, sub)
@@ -155,20 +236,21 @@ func (h *SubscriptionHandler) Update(c *gin.Context) {
 // @Produce json
 // @Param id path int true "Subscription ID"
 // @Success 200 {object} map[string]string
-// @Failure 400 {object} models.ErrorResponse
-// @Failure 500 {object} models.ErrorResponse
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 500 {object} models.Problem
 // @Router /subscriptions/{id} [delete]
 func (h *SubscriptionHandler) Delete(c *gin.Context) {
 	idStr := c.Param("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		logger.Log.Error("Failed to parse id", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		logger.FromContext(c.Request.Context()).Error("Failed to parse id", zap.Error(err))
+		c.Error(fmt.Errorf("%w: invalid id", services.ErrValidation))
 		return
 	}
-	if err := h.service.Delete(uint(id)); err != nil {
-		logger.Log.Error("Failed to delete subscription", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.service.Delete(c.Request.Context(), uint(id)); err != nil {
+		logger.FromContext(c.Request.Context()).Error("Failed to delete subscription", zap.Error(err))
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
@@ -183,7 +265,7 @@ func (h *SubscriptionHandler) Delete(c *gin.Context) {
 // @Param from query string false "Filter from date (YYYY-MM-DD)"
 // @Param to query string false "Filter to date (YYYY-MM-DD)"
 // @Success 200 {object} map[string]int
-// @Failure 500 {object} models.ErrorResponse
+// @Failure 500 {object} models.Problem
 // @Router /subscriptions/total [get]
 func (h *SubscriptionHandler) TotalPrice(c *gin.Context) {
 	userID := c.Query("user_id")
@@ -203,11 +285,248 @@ func (h *SubscriptionHandler) TotalPrice(c *gin.Context) {
 		}
 	}
 
-	total, err := h.service.TotalPrice(userID, serviceName, fromTime, toTime)
+	total, err := h.service.TotalPrice(c.Request.Context(), userID, serviceName, fromTime, toTime)
 	if err != nil {
-		logger.Log.Error("Failed to calculate total price", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		logger.FromContext(c.Request.Context()).Error("Failed to calculate total price", zap.Error(err))
+		c.Error(err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"total_price": total})
 }
+
+// maxImportSize limits the size of an uploaded import file
+const maxImportSize = 10 << 20
+
+// ImportSubscriptions godoc
+// @Summary Import subscriptions from CSV or NDJSON
+// @Description Accepts a multipart "file" field or a raw request body. CSV needs a header row
+// @Description with service_name, price, user_id, start_date and optional end_date, trial_ends_at columns.
+// @Tags subscriptions
+// @Accept text/csv
+// @Accept application/x-ndjson
+// @Accept multipart/form-data
+// @Produce json
+// @Param format query string false "csv or ndjson, detected from Content-Type or file name when omitted"
+// @Param dry_run query bool false "Validate rows without saving them"
+// @Param allow_duplicate query bool false "Import rows overlapping existing subscriptions instead of skipping them"
+// @Param file formData file false "Import file"
+// @Param Idempotency-Key header string false "Retries with the same key replay the first response for 24h"
+// @Success 200 {object} services.ImportReport
+// @Failure 400 {object} models.Problem
+// @Failure 409 {object} models.Problem
+// @Failure 500 {object} models.Problem
+// @Router /subscriptions/import [post]
+func (h *SubscriptionHandler) Import(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+
+	var body io.Reader = c.Request.Body
+	format := c.Query("format")
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		file, header, err := c.Request.FormFile("file")
+		if err != nil {
+			logger.FromContext(c.Request.Context()).Error("Failed to read import file", zap.Error(err))
+			c.Error(fmt.Errorf("%w: file is required", services.ErrValidation))
+			return
+		}
+		defer file.Close()
+		body = file
+		if format == "" {
+			format = strings.TrimPrefix(strings.ToLower(filepath.Ext(header.Filename)), ".")
+		}
+	}
+	if format == "" {
+		switch c.ContentType() {
+		case "text/csv":
+			format = "csv"
+		case "application/x-ndjson", "application/jsonl":
+			format = "ndjson"
+		}
+	}
+
+	var rows []services.ImportRow
+	var err error
+	switch format {
+	case "csv":
+		rows, err = services.ParseImportCSV(body)
+	case "ndjson", "jsonl":
+		rows, err = services.ParseImportNDJSON(body)
+	default:
+		c.Error(fmt.Errorf("%w: format must be csv or ndjson", services.ErrValidation))
+		return
+	}
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Error("Failed to parse import file", zap.Error(err))
+		c.Error(fmt.Errorf("%w: %v", services.ErrValidation, err))
+		return
+	}
+
+	var opts services.ImportOptions
+	opts.DryRun, _ = strconv.ParseBool(c.Query("dry_run"))
+	opts.AllowDuplicate, _ = strconv.ParseBool(c.Query("allow_duplicate"))
+	report, err := h.service.Import(c.Request.Context(), rows, opts)
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Error("Failed to import subscriptions", zap.Error(err))
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// BulkSubscriptions godoc
+// @Summary Run several subscription changes in one transaction
+// @Description Either "operations" (create, update, cancel, delete) or a filter-based "action"
+// @Description (set_end_date, cancel, delete for all subscriptions of a user and/or service).
+// @Description In atomic mode any failure rolls back everything, in best_effort mode failed items are skipped.
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param request body services.BulkRequest true "Bulk request"
+// @Param Idempotency-Key header string false "Retries with the same key replay the first response for 24h"
+// @Success 200 {object} services.BulkReport
+// @Failure 400 {object} models.Problem
+// @Failure 409 {object} models.Problem
+// @Failure 422 {object} services.BulkReport
+// @Failure 500 {object} models.Problem
+// @Router /subscriptions/bulk [post]
+func (h *SubscriptionHandler) Bulk(c *gin.Context) {
+	var req services.BulkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.FromContext(c.Request.Context()).Error("Failed to bind JSON", zap.Error(err))
+		c.Error(fmt.Errorf("%w: %v", services.ErrValidation, err))
+		return
+	}
+	report, err := h.service.Bulk(c.Request.Context(), req)
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Error("Failed to run bulk request", zap.Error(err))
+		c.Error(err)
+		return
+	}
+	if !report.Committed {
+		c.JSON(http.StatusUnprocessableEntity, report)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// UserDuplicates godoc
+// @Summary List overlapping subscriptions of a user
+// @Description Groups the user's subscriptions to the same service whose periods overlap
+// @Tags subscriptions
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 200 {array} services.DuplicateGroup
+// @Failure 500 {object} models.Problem
+// @Router /users/{id}/duplicates [get]
+func (h *SubscriptionHandler) UserDuplicates(c *gin.Context) {
+	groups, err := h.service.FindDuplicates(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Error("Failed to find duplicate subscriptions", zap.Error(err))
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, groups)
+}
+
+// CancelRequest represents cancel payload
+type CancelRequest struct {
+	EffectiveDate string `json:"effective_date" example:"2026-03-01"`
+}
+
+// PauseSubscription godoc
+// @Summary Pause subscription by ID
+// @Tags subscriptions
+// @Produce json
+// @Param id path int true "Subscription ID"
+// @Success 200 {object} models.Subscription
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 409 {object} models.Problem
+// @Router /subscriptions/{id}/pause [post]
+func (h *SubscriptionHandler) Pause(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Error("Failed to parse id", zap.Error(err))
+		c.Error(fmt.Errorf("%w: invalid id", services.ErrValidation))
+		return
+	}
+	sub, err := h.service.Pause(c.Request.Context(), uint(id))
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Error("Failed to pause subscription", zap.Error(err))
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, sub)
+}
+
+// ResumeSubscription godoc
+// @Summary Resume paused subscription by ID
+// @Tags subscriptions
+// @Produce json
+// @Param id path int true "Subscription ID"
+// @Success 200 {object} models.Subscription
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 409 {object} models.Problem
+// @Router /subscriptions/{id}/resume [post]
+func (h *SubscriptionHandler) Resume(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Error("Failed to parse id", zap.Error(err))
+		c.Error(fmt.Errorf("%w: invalid id", services.ErrValidation))
+		return
+	}
+	sub, err := h.service.Resume(c.Request.Context(), uint(id))
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Error("Failed to resume subscription", zap.Error(err))
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, sub)
+}
+
+// CancelSubscription godoc
+// @Summary Cancel subscription by ID
+// @Description Cancels the subscription from effective_date (YYYY-MM-DD, defaults to today)
+// @Tags subscriptions
+// @Accept json
+// @Produce json
+// @Param id path int true "Subscription ID"
+// @Param cancel body CancelRequest false "Cancel options"
+// @Success 200 {object} models.Subscription
+// @Failure 400 {object} models.Problem
+// @Failure 404 {object} models.Problem
+// @Failure 409 {object} models.Problem
+// @Router /subscriptions/{id}/cancel [post]
+func (h *SubscriptionHandler) Cancel(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Error("Failed to parse id", zap.Error(err))
+		c.Error(fmt.Errorf("%w: invalid id", services.ErrValidation))
+		return
+	}
+
+	var req CancelRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			logger.FromContext(c.Request.Context()).Error("Failed to bind JSON", zap.Error(err))
+			c.Error(fmt.Errorf("%w: %v", services.ErrValidation, err))
+			return
+		}
+	}
+	effective := time.Now().UTC().Truncate(24 * time.Hour)
+	if req.EffectiveDate != "" {
+		effective, err = time.Parse("2006-01-02", req.EffectiveDate)
+		if err != nil {
+			c.Error(fmt.Errorf("%w: invalid effective_date", services.ErrValidation))
+			return
+		}
+	}
+
+	sub, err := h.service.Cancel(c.Request.Context(), uint(id), effective)
+	if err != nil {
+		logger.FromContext(c.Request.Context()).Error("Failed to cancel subscription", zap.Error(err))
+		c.Error(err)
+		return
+	}
+	c.JSON(http.StatusOK, sub)
+}
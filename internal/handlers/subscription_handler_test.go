@@ -2,13 +2,19 @@ package handlers
 
 import (
     "bytes"
+    "context"
     "encoding/json"
+    "errors"
+    "fmt"
+    "io"
     "net/http"
     "net/http/httptest"
     "testing"
     "time"
 	"subscriptions_service_golang/pkg/logger"
+    "subscriptions_service_golang/internal/middleware"
     "subscriptions_service_golang/internal/models"
+    "subscriptions_service_golang/internal/services"
 
     "github.com/gin-gonic/gin"
     "github.com/stretchr/testify/assert"
@@ -16,48 +22,133 @@ import (
 
 type FakeSubscriptionService struct{}
 
-func (s *FakeSubscriptionService) Create(sub models.Subscription) (*models.Subscription, error) {
+func (s *FakeSubscriptionService) Create(ctx context.Context, sub models.Subscription, allowDuplicate bool) (*models.Subscription, error) {
+    if sub.ServiceName == "Duplicate" {
+        if !allowDuplicate {
+            return nil, services.ErrDuplicate
+        }
+        sub.Duplicate = true
+    }
     sub.ID = 1
     return &sub, nil
 }
-func (s *FakeSubscriptionService) GetByID(id uint) (*models.Subscription, error) {
+func (s *FakeSubscriptionService) GetByID(ctx context.Context, id uint) (*models.Subscription, error) {
+    switch id {
+    case 404:
+        return nil, fmt.Errorf("%w: subscription %d", services.ErrNotFound, id)
+    case 500:
+        return nil, errors.New("connection refused")
+    case 503:
+        return nil, fmt.Errorf("%w: circuit breaker is open", services.ErrUnavailable)
+    case 504:
+        return nil, ctx.Err()
+    }
     return &models.Subscription{ID: id, ServiceName: "Netflix", Price: 10000}, nil
 }
-func (s *FakeSubscriptionService) List(userID, serviceName string, from, to *time.Time) ([]models.Subscription, error) {
+func (s *FakeSubscriptionService) List(ctx context.Context, filter models.SubscriptionFilter) ([]models.Subscription, error) {
+    if filter.Active {
+        return []models.Subscription{{ID: 1, ServiceName: "Netflix", Price: 10000, Status: models.StatusActive}}, nil
+    }
     return []models.Subscription{
         {ID: 1, ServiceName: "Netflix", Price: 10000},
         {ID: 2, ServiceName: "Spotify", Price: 5000},
     }, nil
 }
-func (s *FakeSubscriptionService) Update(sub models.Subscription) (*models.Subscription, error) {
+func (s *FakeSubscriptionService) Update(ctx context.Context, sub models.Subscription) (*models.Subscription, error) {
     sub.ServiceName = "Updated"
     return &sub, nil
 }
-func (s *FakeSubscriptionService) Delete(id uint) error {
+func (s *FakeSubscriptionService) Delete(ctx context.Context, id uint) error {
     return nil
 }
-func (s *FakeSubscriptionService) TotalPrice(userID, serviceName string, from, to *time.Time) (int, error) {
+func (s *FakeSubscriptionService) TotalPrice(ctx context.Context, userID, serviceName string, from, to *time.Time) (int, error) {
     return 15000, nil
 }
+func (s *FakeSubscriptionService) Pause(ctx context.Context, id uint) (*models.Subscription, error) {
+    if id == 2 {
+        return nil, services.ErrInvalidTransition
+    }
+    return &models.Subscription{ID: id, Status: models.StatusPaused}, nil
+}
+func (s *FakeSubscriptionService) Resume(ctx context.Context, id uint) (*models.Subscription, error) {
+    return &models.Subscription{ID: id, Status: models.StatusActive}, nil
+}
+func (s *FakeSubscriptionService) Cancel(ctx context.Context, id uint, effectiveDate time.Time) (*models.Subscription, error) {
+    return &models.Subscription{ID: id, Status: models.StatusCancelled, EndDate: &effectiveDate}, nil
+}
+func (s *FakeSubscriptionService) ExpireDue(ctx context.Context, now time.Time) ([]models.Subscription, error) {
+    return nil, nil
+}
+func (s *FakeSubscriptionService) Export(ctx context.Context, filter models.SubscriptionFilter, format string, w io.Writer) error {
+    switch filter.UserID {
+    case "unavailable":
+        return fmt.Errorf("%w: circuit breaker is open", services.ErrUnavailable)
+    case "cut":
+        fmt.Fprintln(w, `{"id":1}`)
+        return errors.New("connection reset")
+    }
+    _, err := fmt.Fprintf(w, "%s:%s", format, filter.UserID)
+    return err
+}
+func (s *FakeSubscriptionService) Bulk(ctx context.Context, req services.BulkRequest) (*services.BulkReport, error) {
+    if req.Mode == "" {
+        return nil, services.ErrValidation
+    }
+    report := &services.BulkReport{Mode: req.Mode, Committed: req.Mode == services.BulkBestEffort}
+    for i, op := range req.Operations {
+        report.Results = append(report.Results, services.BulkResult{Index: i, Op: op.Op, Status: services.BulkOK})
+    }
+    return report, nil
+}
+func (s *FakeSubscriptionService) FindDuplicates(ctx context.Context, userID string) ([]services.DuplicateGroup, error) {
+    return []services.DuplicateGroup{{
+        ServiceName:   "Netflix",
+        Subscriptions: []models.Subscription{{ID: 1, UserID: userID}, {ID: 2, UserID: userID}},
+    }}, nil
+}
+func (s *FakeSubscriptionService) Stats(ctx context.Context) (models.SubscriptionStats, error) {
+    return models.SubscriptionStats{Active: 2, MonthlySpend: 15000}, nil
+}
+func (s *FakeSubscriptionService) Import(ctx context.Context, rows []services.ImportRow, opts services.ImportOptions) (*services.ImportReport, error) {
+    report := &services.ImportReport{DryRun: opts.DryRun}
+    for _, row := range rows {
+        if row.Err != nil {
+            report.Failed++
+            report.Rows = append(report.Rows, services.ImportResult{Line: row.Line, Status: services.ImportFailed, Reason: row.Err.Error()})
+            continue
+        }
+        report.Created++
+        report.Rows = append(report.Rows, services.ImportResult{Line: row.Line, Status: services.ImportCreated})
+    }
+    return report, nil
+}
 
 
 
 func setupRouter() *gin.Engine {
     gin.SetMode(gin.TestMode)
     r := gin.Default()
+    r.Use(middleware.ErrorHandler())
 
     // logger init
-    logger.Init()
+    logger.Init("info", "json")
 
     service := &FakeSubscriptionService{}
     handler := NewSubscriptionHandler(service)
 
     r.POST("/subscriptions", handler.Create)
+    r.POST("/subscriptions/import", handler.Import)
+    r.POST("/subscriptions/bulk", handler.Bulk)
+    r.GET("/users/:id/duplicates", handler.UserDuplicates)
     r.GET("/subscriptions/:id", handler.GetByID)
     r.GET("/subscriptions", handler.List)
     r.PUT("/subscriptions/:id", handler.Update)
     r.DELETE("/subscriptions/:id", handler.Delete)
     r.GET("/subscriptions/total", handler.TotalPrice)
+    r.GET("/subscriptions/export", handler.Export)
+    r.POST("/subscriptions/:id/pause", handler.Pause)
+    r.POST("/subscriptions/:id/resume", handler.Resume)
+    r.POST("/subscriptions/:id/cancel", handler.Cancel)
 
     return r
 }
@@ -81,6 +172,44 @@ func TestCreateSubscription(t *testing.T) {
     assert.Equal(t, "Netflix", resp.ServiceName)
 }
 
+func TestCreateDuplicateSubscription(t *testing.T) {
+    r := setupRouter()
+
+    body := models.Subscription{ServiceName: "Duplicate", Price: 10000}
+    jsonBody, _ := json.Marshal(body)
+
+    req, _ := http.NewRequest("POST", "/subscriptions", bytes.NewBuffer(jsonBody))
+    req.Header.Set("Content-Type", "application/json")
+    w := httptest.NewRecorder()
+    r.ServeHTTP(w, req)
+
+    assert.Equal(t, http.StatusConflict, w.Code)
+
+    req, _ = http.NewRequest("POST", "/subscriptions?allow_duplicate=true", bytes.NewBuffer(jsonBody))
+    req.Header.Set("Content-Type", "application/json")
+    w = httptest.NewRecorder()
+    r.ServeHTTP(w, req)
+
+    assert.Equal(t, http.StatusCreated, w.Code)
+    var resp models.Subscription
+    json.Unmarshal(w.Body.Bytes(), &resp)
+    assert.True(t, resp.Duplicate)
+}
+
+func TestUserDuplicates(t *testing.T) {
+    r := setupRouter()
+
+    req, _ := http.NewRequest("GET", "/users/u1/duplicates", nil)
+    w := httptest.NewRecorder()
+    r.ServeHTTP(w, req)
+
+    assert.Equal(t, http.StatusOK, w.Code)
+    var resp []services.DuplicateGroup
+    json.Unmarshal(w.Body.Bytes(), &resp)
+    assert.Len(t, resp, 1)
+    assert.Len(t, resp[0].Subscriptions, 2)
+}
+
 func TestGetByID(t *testing.T) {
     r := setupRouter()
 
@@ -94,6 +223,37 @@ func TestGetByID(t *testing.T) {
     assert.Equal(t, uint(1), resp.ID)
 }
 
+func TestGetByIDErrors(t *testing.T) {
+    r := setupRouter()
+
+    tests := []struct {
+        path   string
+        status int
+        detail string
+    }{
+        {"/subscriptions/abc", http.StatusBadRequest, "validation failed: invalid id"},
+        {"/subscriptions/404", http.StatusNotFound, "not found: subscription 404"},
+        {"/subscriptions/500", http.StatusInternalServerError, ""},
+        {"/subscriptions/503", http.StatusServiceUnavailable, ""},
+        {"/subscriptions/504", http.StatusGatewayTimeout, ""},
+    }
+    for _, tt := range tests {
+        ctx, cancel := context.WithDeadline(context.Background(), time.Now())
+        req, _ := http.NewRequestWithContext(ctx, "GET", tt.path, nil)
+        w := httptest.NewRecorder()
+        r.ServeHTTP(w, req)
+
+        assert.Equal(t, tt.status, w.Code)
+        assert.Equal(t, middleware.ProblemContentType, w.Header().Get("Content-Type"))
+        var resp models.Problem
+        json.Unmarshal(w.Body.Bytes(), &resp)
+        assert.Equal(t, tt.status, resp.Status)
+        assert.Equal(t, tt.detail, resp.Detail)
+        assert.Equal(t, tt.path, resp.Instance)
+        cancel()
+    }
+}
+
 func TestListSubscriptions(t *testing.T) {
     r := setupRouter()
 
@@ -105,6 +265,14 @@ func TestListSubscriptions(t *testing.T) {
     var resp []models.Subscription
     json.Unmarshal(w.Body.Bytes(), &resp)
     assert.Len(t, resp, 2)
+
+    req, _ = http.NewRequest("GET", "/subscriptions?active=true", nil)
+    w = httptest.NewRecorder()
+    r.ServeHTTP(w, req)
+
+    assert.Equal(t, http.StatusOK, w.Code)
+    json.Unmarshal(w.Body.Bytes(), &resp)
+    assert.Len(t, resp, 1)
 }
 
 func TestUpdateSubscription(t *testing.T) {
@@ -146,3 +314,161 @@ func TestTotalPrice(t *testing.T) {
     json.Unmarshal(w.Body.Bytes(), &resp)
     assert.Equal(t, 15000, resp["total_price"])
 }
+
+func TestPauseSubscription(t *testing.T) {
+    r := setupRouter()
+
+    req, _ := http.NewRequest("POST", "/subscriptions/1/pause", nil)
+    w := httptest.NewRecorder()
+    r.ServeHTTP(w, req)
+
+    assert.Equal(t, http.StatusOK, w.Code)
+    var resp models.Subscription
+    json.Unmarshal(w.Body.Bytes(), &resp)
+    assert.Equal(t, models.StatusPaused, resp.Status)
+
+    req, _ = http.NewRequest("POST", "/subscriptions/2/pause", nil)
+    w = httptest.NewRecorder()
+    r.ServeHTTP(w, req)
+
+    assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestResumeSubscription(t *testing.T) {
+    r := setupRouter()
+
+    req, _ := http.NewRequest("POST", "/subscriptions/1/resume", nil)
+    w := httptest.NewRecorder()
+    r.ServeHTTP(w, req)
+
+    assert.Equal(t, http.StatusOK, w.Code)
+    var resp models.Subscription
+    json.Unmarshal(w.Body.Bytes(), &resp)
+    assert.Equal(t, models.StatusActive, resp.Status)
+}
+
+func TestCancelSubscription(t *testing.T) {
+    r := setupRouter()
+
+    jsonBody, _ := json.Marshal(CancelRequest{EffectiveDate: "2026-03-01"})
+    req, _ := http.NewRequest("POST", "/subscriptions/1/cancel", bytes.NewBuffer(jsonBody))
+    req.Header.Set("Content-Type", "application/json")
+    w := httptest.NewRecorder()
+    r.ServeHTTP(w, req)
+
+    assert.Equal(t, http.StatusOK, w.Code)
+    var resp models.Subscription
+    json.Unmarshal(w.Body.Bytes(), &resp)
+    assert.Equal(t, models.StatusCancelled, resp.Status)
+    assert.Equal(t, "2026-03-01", resp.EndDate.Format("2006-01-02"))
+
+    jsonBody, _ = json.Marshal(CancelRequest{EffectiveDate: "01.03.2026"})
+    req, _ = http.NewRequest("POST", "/subscriptions/1/cancel", bytes.NewBuffer(jsonBody))
+    req.Header.Set("Content-Type", "application/json")
+    w = httptest.NewRecorder()
+    r.ServeHTTP(w, req)
+
+    assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestImportSubscriptions(t *testing.T) {
+    r := setupRouter()
+
+    csvBody := "service_name,price,user_id,start_date\n" +
+        "Netflix,500,60601fee-2bf1-4721-ae6f-7636e79a0cba,2025-07-01\n" +
+        "Spotify,abc,60601fee-2bf1-4721-ae6f-7636e79a0cba,2025-07-01\n"
+    req, _ := http.NewRequest("POST", "/subscriptions/import?dry_run=true", bytes.NewBufferString(csvBody))
+    req.Header.Set("Content-Type", "text/csv")
+    w := httptest.NewRecorder()
+    r.ServeHTTP(w, req)
+
+    assert.Equal(t, http.StatusOK, w.Code)
+    var resp services.ImportReport
+    json.Unmarshal(w.Body.Bytes(), &resp)
+    assert.True(t, resp.DryRun)
+    assert.Equal(t, 1, resp.Created)
+    assert.Equal(t, 1, resp.Failed)
+    assert.Equal(t, 3, resp.Rows[1].Line)
+
+    req, _ = http.NewRequest("POST", "/subscriptions/import", bytes.NewBufferString(csvBody))
+    req.Header.Set("Content-Type", "application/xml")
+    w = httptest.NewRecorder()
+    r.ServeHTTP(w, req)
+
+    assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestExportSubscriptions(t *testing.T) {
+    r := setupRouter()
+
+    req, _ := http.NewRequest("GET", "/subscriptions/export?format=xlsx&user_id=u1", nil)
+    w := httptest.NewRecorder()
+    r.ServeHTTP(w, req)
+
+    assert.Equal(t, http.StatusOK, w.Code)
+    assert.Equal(t, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", w.Header().Get("Content-Type"))
+    assert.Contains(t, w.Header().Get("Content-Disposition"), "subscriptions.xlsx")
+    assert.Equal(t, "xlsx:u1", w.Body.String())
+
+    req, _ = http.NewRequest("GET", "/subscriptions/export?format=pdf", nil)
+    w = httptest.NewRecorder()
+    r.ServeHTTP(w, req)
+
+    assert.Equal(t, http.StatusBadRequest, w.Code)
+
+    // the query fails before the first row: a problem, not an empty file
+    req, _ = http.NewRequest("GET", "/subscriptions/export?format=ndjson&user_id=unavailable", nil)
+    w = httptest.NewRecorder()
+    r.ServeHTTP(w, req)
+
+    assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+    assert.Equal(t, middleware.ProblemContentType, w.Header().Get("Content-Type"))
+    assert.Empty(t, w.Header().Get("Content-Disposition"))
+
+    // a failure after rows were sent drops the connection; gin's own recovery
+    // would swallow the abort, the service uses middleware.Recovery
+    r = gin.New()
+    r.Use(middleware.ErrorHandler(), middleware.Recovery())
+    r.GET("/subscriptions/export", NewSubscriptionHandler(&FakeSubscriptionService{}).Export)
+    req, _ = http.NewRequest("GET", "/subscriptions/export?format=ndjson&user_id=cut", nil)
+    w = httptest.NewRecorder()
+    assert.PanicsWithValue(t, http.ErrAbortHandler, func() { r.ServeHTTP(w, req) })
+    assert.Equal(t, "{\"id\":1}\n", w.Body.String())
+}
+
+func TestBulkSubscriptions(t *testing.T) {
+    r := setupRouter()
+
+    body := services.BulkRequest{
+        Mode:       services.BulkBestEffort,
+        Operations: []services.BulkOperation{{Op: services.BulkDelete, ID: 1}},
+    }
+    jsonBody, _ := json.Marshal(body)
+    req, _ := http.NewRequest("POST", "/subscriptions/bulk", bytes.NewBuffer(jsonBody))
+    req.Header.Set("Content-Type", "application/json")
+    w := httptest.NewRecorder()
+    r.ServeHTTP(w, req)
+
+    assert.Equal(t, http.StatusOK, w.Code)
+    var resp services.BulkReport
+    json.Unmarshal(w.Body.Bytes(), &resp)
+    assert.Len(t, resp.Results, 1)
+
+    body.Mode = services.BulkAtomic
+    jsonBody, _ = json.Marshal(body)
+    req, _ = http.NewRequest("POST", "/subscriptions/bulk", bytes.NewBuffer(jsonBody))
+    req.Header.Set("Content-Type", "application/json")
+    w = httptest.NewRecorder()
+    r.ServeHTTP(w, req)
+
+    assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+    body.Mode = ""
+    jsonBody, _ = json.Marshal(body)
+    req, _ = http.NewRequest("POST", "/subscriptions/bulk", bytes.NewBuffer(jsonBody))
+    req.Header.Set("Content-Type", "application/json")
+    w = httptest.NewRecorder()
+    r.ServeHTTP(w, req)
+
+    assert.Equal(t, http.StatusBadRequest, w.Code)
+}
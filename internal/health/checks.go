@@ -0,0 +1,36 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Ping checks that the database answers
+func Ping(db *sql.DB) Check {
+	return func(ctx context.Context) error {
+		return db.PingContext(ctx)
+	}
+}
+
+// Heartbeat is implemented by background workers that report when their
+// loop last ran
+type Heartbeat interface {
+	LastBeat() time.Time
+}
+
+// Worker checks that w has run within maxAge
+func Worker(w Heartbeat, maxAge time.Duration) Check {
+	return func(ctx context.Context) error {
+		last := w.LastBeat()
+		if last.IsZero() {
+			return errors.New("not started")
+		}
+		if age := time.Since(last); age > maxAge {
+			return fmt.Errorf("last run %s ago", age.Round(time.Second))
+		}
+		return nil
+	}
+}
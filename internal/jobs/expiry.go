@@ -0,0 +1,70 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"subscriptions_service_golang/internal/events"
+	"subscriptions_service_golang/internal/services"
+	"subscriptions_service_golang/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// ExpiryLockKey is the advisory lock key shared by all replicas running the expiry job
+const ExpiryLockKey int64 = 727001
+
+// ExpiryJob periodically moves subscriptions past their end date to expired
+type ExpiryJob struct {
+	service  services.SubscriptionService
+	leader   Leader
+	bus      *events.Bus
+	interval time.Duration
+	now      func() time.Time
+
+	heartbeat
+}
+
+func NewExpiryJob(service services.SubscriptionService, leader Leader, bus *events.Bus, interval time.Duration) *ExpiryJob {
+	return &ExpiryJob{service: service, leader: leader, bus: bus, interval: interval, now: time.Now}
+}
+
+// Run executes the job every interval until ctx is cancelled
+func (j *ExpiryJob) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		j.beat()
+		j.RunOnce(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce expires due subscriptions if this replica is the leader
+func (j *ExpiryJob) RunOnce(ctx context.Context) {
+	if !j.leader.IsLeader(ctx) {
+		return
+	}
+	now := j.now()
+	expired, err := j.service.ExpireDue(ctx, now)
+	if err != nil {
+		logger.FromContext(ctx).Error("Failed to expire subscriptions", zap.Error(err))
+		return
+	}
+	for _, sub := range expired {
+		j.bus.Publish(events.Event{
+			Type:           events.SubscriptionExpired,
+			SubscriptionID: sub.ID,
+			UserID:         sub.UserID,
+			OccurredAt:     now,
+		})
+	}
+	if len(expired) > 0 {
+		logger.FromContext(ctx).Info("Expired subscriptions", zap.Int("count", len(expired)))
+	}
+}
@@ -0,0 +1,60 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"subscriptions_service_golang/internal/events"
+	"subscriptions_service_golang/internal/models"
+	"subscriptions_service_golang/internal/services"
+	"subscriptions_service_golang/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeExpiryService struct {
+	services.SubscriptionService
+	calledWith time.Time
+}
+
+func (s *fakeExpiryService) ExpireDue(ctx context.Context, now time.Time) ([]models.Subscription, error) {
+	s.calledWith = now
+	return []models.Subscription{
+		{ID: 1, UserID: "u1", Status: models.StatusExpired},
+		{ID: 2, UserID: "u2", Status: models.StatusExpired},
+	}, nil
+}
+
+type notLeader struct{}
+
+func (notLeader) IsLeader(context.Context) bool { return false }
+
+func TestExpiryJobRunOnce(t *testing.T) {
+	logger.Init("info", "json")
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("leader publishes events", func(t *testing.T) {
+		service := &fakeExpiryService{}
+		bus := events.NewBus()
+		var got []events.Event
+		bus.Subscribe(events.SubscriptionExpired, func(e events.Event) { got = append(got, e) })
+
+		job := NewExpiryJob(service, AlwaysLeader{}, bus, time.Minute)
+		job.now = func() time.Time { return now }
+		job.RunOnce(context.Background())
+
+		assert.Equal(t, now, service.calledWith)
+		assert.Len(t, got, 2)
+		assert.Equal(t, uint(2), got[1].SubscriptionID)
+		assert.Equal(t, "u2", got[1].UserID)
+	})
+
+	t.Run("follower does nothing", func(t *testing.T) {
+		service := &fakeExpiryService{}
+		job := NewExpiryJob(service, notLeader{}, events.NewBus(), time.Minute)
+		job.RunOnce(context.Background())
+
+		assert.True(t, service.calledWith.IsZero())
+	})
+}
@@ -0,0 +1,24 @@
+package jobs
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// heartbeat remembers when a job loop last ran, for readiness checks
+type heartbeat struct {
+	last atomic.Int64
+}
+
+func (h *heartbeat) beat() {
+	h.last.Store(time.Now().UnixNano())
+}
+
+// LastBeat returns when the job loop last ran, or zero if it has not started
+func (h *heartbeat) LastBeat() time.Time {
+	n := h.last.Load()
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
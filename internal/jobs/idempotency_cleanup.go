@@ -0,0 +1,46 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"subscriptions_service_golang/internal/repositories"
+	"subscriptions_service_golang/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// IdempotencyCleanupJob periodically deletes idempotency keys older than ttl.
+// Deleting is safe to run on every replica, so no leader is needed.
+type IdempotencyCleanupJob struct {
+	repo     repositories.IdempotencyRepository
+	ttl      time.Duration
+	interval time.Duration
+
+	heartbeat
+}
+
+func NewIdempotencyCleanupJob(repo repositories.IdempotencyRepository, ttl, interval time.Duration) *IdempotencyCleanupJob {
+	return &IdempotencyCleanupJob{repo: repo, ttl: ttl, interval: interval}
+}
+
+// Run executes the job every interval until ctx is cancelled
+func (j *IdempotencyCleanupJob) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		j.beat()
+		deleted, err := j.repo.DeleteExpired(ctx, time.Now().Add(-j.ttl))
+		if err != nil {
+			logger.FromContext(ctx).Error("Failed to delete expired idempotency keys", zap.Error(err))
+		} else if deleted > 0 {
+			logger.FromContext(ctx).Info("Deleted expired idempotency keys", zap.Int64("count", deleted))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
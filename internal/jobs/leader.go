@@ -0,0 +1,69 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// Leader decides whether this replica should run a periodic job
+type Leader interface {
+	IsLeader(ctx context.Context) bool
+}
+
+// AlwaysLeader is used when only one replica runs, e.g. in tests
+type AlwaysLeader struct{}
+
+func (AlwaysLeader) IsLeader(context.Context) bool { return true }
+
+// AdvisoryLeader elects a leader with a session-level Postgres advisory lock.
+// The replica that acquires the lock keeps its connection open and stays
+// leader until the connection is lost.
+type AdvisoryLeader struct {
+	db  *sql.DB
+	key int64
+
+	mu   sync.Mutex
+	conn *sql.Conn
+}
+
+func NewAdvisoryLeader(db *sql.DB, key int64) *AdvisoryLeader {
+	return &AdvisoryLeader{db: db, key: key}
+}
+
+func (l *AdvisoryLeader) IsLeader(ctx context.Context) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn != nil {
+		if err := l.conn.PingContext(ctx); err == nil {
+			return true
+		}
+		l.conn.Close()
+		l.conn = nil
+	}
+
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return false
+	}
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", l.key).Scan(&acquired); err != nil || !acquired {
+		conn.Close()
+		return false
+	}
+	l.conn = conn
+	return true
+}
+
+// Release gives up leadership so another replica can take over
+func (l *AdvisoryLeader) Release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conn == nil {
+		return
+	}
+	l.conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", l.key)
+	l.conn.Close()
+	l.conn = nil
+}
@@ -0,0 +1,69 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"subscriptions_service_golang/internal/repositories"
+	"subscriptions_service_golang/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// LoginCleanupJob periodically deletes the lockouts whose failures are
+// older than window and are not locked, and the login audit log entries
+// older than retention. Zero retention keeps the audit log forever. Without
+// it, logins with made-up usernames would grow both tables without bound.
+type LoginCleanupJob struct {
+	repo      repositories.LoginAttemptRepository
+	leader    Leader
+	window    time.Duration
+	retention time.Duration
+	interval  time.Duration
+	now       func() time.Time
+
+	heartbeat
+}
+
+func NewLoginCleanupJob(repo repositories.LoginAttemptRepository, leader Leader, window, retention, interval time.Duration) *LoginCleanupJob {
+	return &LoginCleanupJob{repo: repo, leader: leader, window: window, retention: retention, interval: interval, now: time.Now}
+}
+
+// Run executes the job every interval until ctx is cancelled
+func (j *LoginCleanupJob) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		j.beat()
+		j.RunOnce(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce deletes what has expired if this replica is the leader
+func (j *LoginCleanupJob) RunOnce(ctx context.Context) {
+	if !j.leader.IsLeader(ctx) {
+		return
+	}
+	now := j.now()
+	deleted, err := j.repo.DeleteStaleLockouts(ctx, now.Add(-j.window), now)
+	if err != nil {
+		logger.FromContext(ctx).Error("Failed to delete stale login lockouts", zap.Error(err))
+	} else if deleted > 0 {
+		logger.FromContext(ctx).Info("Deleted stale login lockouts", zap.Int64("count", deleted))
+	}
+	if j.retention <= 0 {
+		return
+	}
+	deleted, err = j.repo.DeleteAttempts(ctx, now.Add(-j.retention))
+	if err != nil {
+		logger.FromContext(ctx).Error("Failed to delete old login attempts", zap.Error(err))
+	} else if deleted > 0 {
+		logger.FromContext(ctx).Info("Deleted old login attempts", zap.Int64("count", deleted))
+	}
+}
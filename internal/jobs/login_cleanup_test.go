@@ -0,0 +1,59 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"subscriptions_service_golang/internal/models"
+	"subscriptions_service_golang/internal/repositories"
+	"subscriptions_service_golang/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoginCleanupJobRunOnce(t *testing.T) {
+	logger.Init("info", "json")
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	repo := repositories.NewMemoryLoginAttemptRepository()
+	for _, key := range []string{"user:old", "user:new"} {
+		at := now.Add(-2 * time.Hour)
+		if key == "user:new" {
+			at = now.Add(-time.Minute)
+		}
+		require.NoError(t, repo.UpdateLockout(ctx, key, func(l *models.LoginLockout) error {
+			l.Failures, l.LastFailureAt = 1, at
+			return nil
+		}))
+		require.NoError(t, repo.Record(ctx, &models.LoginAttempt{Username: key, IP: "10.0.0.1", Result: models.LoginFailed, CreatedAt: at}))
+	}
+
+	t.Run("followers leave everything", func(t *testing.T) {
+		job := NewLoginCleanupJob(repo, notLeader{}, time.Hour, time.Hour, time.Hour)
+		job.now = func() time.Time { return now }
+		job.RunOnce(ctx)
+		attempts, _ := repo.List(ctx, models.LoginAttemptFilter{})
+		assert.Len(t, attempts, 2)
+	})
+
+	t.Run("zero retention keeps the audit log", func(t *testing.T) {
+		job := NewLoginCleanupJob(repo, AlwaysLeader{}, time.Hour, 0, time.Hour)
+		job.now = func() time.Time { return now }
+		job.RunOnce(ctx)
+		assert.ErrorIs(t, repo.ClearLockout(ctx, "user:old"), repositories.ErrNotFound, "failures outside the window are forgotten")
+		attempts, _ := repo.List(ctx, models.LoginAttemptFilter{})
+		assert.Len(t, attempts, 2)
+	})
+
+	t.Run("old attempts are deleted", func(t *testing.T) {
+		job := NewLoginCleanupJob(repo, AlwaysLeader{}, time.Hour, time.Hour, time.Hour)
+		job.now = func() time.Time { return now }
+		job.RunOnce(ctx)
+		attempts, _ := repo.List(ctx, models.LoginAttemptFilter{})
+		require.Len(t, attempts, 1)
+		assert.Equal(t, "user:new", attempts[0].Username)
+		assert.NoError(t, repo.ClearLockout(ctx, "user:new"))
+	})
+}
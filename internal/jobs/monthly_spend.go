@@ -0,0 +1,69 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"subscriptions_service_golang/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// MonthlySpendRollup is the part of services.MonthlySpendRollup the job needs
+type MonthlySpendRollup interface {
+	Due(ctx context.Context) (bool, error)
+	Rebuild(ctx context.Context) (int, error)
+}
+
+// MonthlySpendJob rebuilds the monthly_spend rollup when it has not been
+// built yet and at the start of every month, when the months kept ahead
+// have to be extended
+type MonthlySpendJob struct {
+	rollup   MonthlySpendRollup
+	leader   Leader
+	interval time.Duration
+
+	heartbeat
+}
+
+func NewMonthlySpendJob(rollup MonthlySpendRollup, leader Leader, interval time.Duration) *MonthlySpendJob {
+	return &MonthlySpendJob{rollup: rollup, leader: leader, interval: interval}
+}
+
+// Run executes the job every interval until ctx is cancelled
+func (j *MonthlySpendJob) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		j.beat()
+		j.RunOnce(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce rebuilds the rollup if it is due and this replica is the leader
+func (j *MonthlySpendJob) RunOnce(ctx context.Context) {
+	if !j.leader.IsLeader(ctx) {
+		return
+	}
+	due, err := j.rollup.Due(ctx)
+	if err != nil {
+		logger.FromContext(ctx).Error("Failed to check monthly spend rollup", zap.Error(err))
+		return
+	}
+	if !due {
+		return
+	}
+	start := time.Now()
+	count, err := j.rollup.Rebuild(ctx)
+	if err != nil {
+		logger.FromContext(ctx).Error("Failed to rebuild monthly spend rollup", zap.Int("subscriptions", count), zap.Error(err))
+		return
+	}
+	logger.FromContext(ctx).Info("Rebuilt monthly spend rollup", zap.Int("subscriptions", count), zap.Duration("took", time.Since(start)))
+}
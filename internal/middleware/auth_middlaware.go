@@ -1,14 +1,42 @@
 package middleware
 
 import (
+    "context"
+    "errors"
+    "fmt"
     "net/http"
     "strings"
 
+    "subscriptions_service_golang/internal/models"
+    "subscriptions_service_golang/internal/services"
+
     "github.com/gin-gonic/gin"
 )
 
+// staticTokenUser owns the static test token issued by /login
+const staticTokenUser = "admin"
+
+// staticToken is the development token accepted when static credentials are enabled
+const staticToken = "test-token"
+
+// Authenticator resolves a bearer token to its owner, services.AuthService
+// implements it
+type Authenticator interface {
+    Authenticate(ctx context.Context, token string) (*models.User, error)
+}
+
+// UserRoleKey is the gin context key holding the role of the authenticated
+// user; the static test token acts as an admin
+const UserRoleKey = "user_role"
+
 // AuthMiddleware checks for Bearer token in Authorization header
 func AuthMiddleware(required bool) gin.HandlerFunc {
+    return TokenAuth(nil, true, required)
+}
+
+// TokenAuth checks the Bearer token against auth, which may be nil when
+// only the static test token is accepted. allowStatic enables test-token.
+func TokenAuth(auth Authenticator, allowStatic, required bool) gin.HandlerFunc {
     return func(c *gin.Context) {
         authHeader := c.GetHeader("Authorization")
 
@@ -21,8 +49,25 @@ func AuthMiddleware(required bool) gin.HandlerFunc {
             }
 
             token := strings.TrimPrefix(authHeader, "Bearer ")
-            // Test token
-            if token != "test-token" {
+            switch {
+            case allowStatic && token == staticToken:
+                SetUser(c, staticTokenUser)
+                c.Set(UserRoleKey, string(models.RoleAdmin))
+            case auth != nil:
+                user, err := auth.Authenticate(c.Request.Context(), token)
+                if errors.Is(err, services.ErrUnauthorized) {
+                    c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+                    c.Abort()
+                    return
+                }
+                if err != nil {
+                    c.Error(err)
+                    c.Abort()
+                    return
+                }
+                SetUser(c, user.ID)
+                c.Set(UserRoleKey, string(user.Role))
+            default:
                 c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
                 c.Abort()
                 return
@@ -33,3 +78,16 @@ func AuthMiddleware(required bool) gin.HandlerFunc {
         c.Next()
     }
 }
+
+// RequireRole rejects with 403 the requests of users without role. It must
+// run after TokenAuth with a required token.
+func RequireRole(role models.UserRole) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        if c.GetString(UserRoleKey) != string(role) {
+            c.Error(fmt.Errorf("%w: %s role required", services.ErrForbidden, role))
+            c.Abort()
+            return
+        }
+        c.Next()
+    }
+}
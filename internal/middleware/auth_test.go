@@ -0,0 +1,90 @@
+package middleware
+
+import (
+    "context"
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+
+    "subscriptions_service_golang/internal/models"
+    "subscriptions_service_golang/internal/services"
+
+    "github.com/gin-gonic/gin"
+    "github.com/stretchr/testify/assert"
+)
+
+type fakeAuthenticator map[string]error
+
+func (f fakeAuthenticator) Authenticate(ctx context.Context, token string) (*models.User, error) {
+    if err, ok := f[token]; ok {
+        return nil, err
+    }
+    role := models.RoleUser
+    if token == "sk_admin" {
+        role = models.RoleAdmin
+    }
+    return &models.User{ID: "user-" + token, Role: role}, nil
+}
+
+func TestTokenAuth(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    auth := fakeAuthenticator{
+        "revoked": services.ErrUnauthorized,
+        "broken":  errors.New("connection refused"),
+    }
+    cases := []struct {
+        name        string
+        allowStatic bool
+        token       string
+        status      int
+        user        string
+    }{
+        {"api key", false, "sk_1", http.StatusOK, "user-sk_1"},
+        {"static token", true, "test-token", http.StatusOK, "admin"},
+        {"static token disabled", false, "test-token", http.StatusOK, "user-test-token"},
+        {"invalid token", true, "revoked", http.StatusUnauthorized, ""},
+        {"store error", true, "broken", http.StatusInternalServerError, ""},
+        {"missing token", true, "", http.StatusUnauthorized, ""},
+    }
+    for _, tc := range cases {
+        t.Run(tc.name, func(t *testing.T) {
+            r := gin.New()
+            r.Use(ErrorHandler())
+            var user string
+            r.GET("/", TokenAuth(auth, tc.allowStatic, true), func(c *gin.Context) {
+                user = c.GetString(UserIDKey)
+                c.Status(http.StatusOK)
+            })
+            req := httptest.NewRequest(http.MethodGet, "/", nil)
+            if tc.token != "" {
+                req.Header.Set("Authorization", "Bearer "+tc.token)
+            }
+            w := httptest.NewRecorder()
+            r.ServeHTTP(w, req)
+
+            assert.Equal(t, tc.status, w.Code)
+            assert.Equal(t, tc.user, user)
+        })
+    }
+}
+
+func TestRequireRole(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    r := gin.New()
+    r.Use(ErrorHandler())
+    r.GET("/admin", TokenAuth(fakeAuthenticator{}, true, true), RequireRole(models.RoleAdmin), func(c *gin.Context) {
+        c.Status(http.StatusOK)
+    })
+    for token, status := range map[string]int{
+        "sk_admin":   http.StatusOK,
+        "test-token": http.StatusOK,
+        "sk_user":    http.StatusForbidden,
+    } {
+        req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+        req.Header.Set("Authorization", "Bearer "+token)
+        w := httptest.NewRecorder()
+        r.ServeHTTP(w, req)
+        assert.Equal(t, status, w.Code, token)
+    }
+}
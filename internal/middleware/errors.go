@@ -0,0 +1,88 @@
+package middleware
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "net/http"
+
+    "subscriptions_service_golang/internal/models"
+    "subscriptions_service_golang/internal/services"
+
+    "github.com/gin-gonic/gin"
+)
+
+// ProblemContentType is the media type of RFC 7807 error bodies
+const ProblemContentType = "application/problem+json"
+
+// StatusClientClosedRequest is the non-standard status (nginx) used when
+// the client went away before the request was finished
+const StatusClientClosedRequest = 499
+
+// ErrorHandler turns the last error added with c.Error into an
+// application/problem+json response. Handlers only need to call c.Error
+// and return; the status code is chosen from the error type here.
+func ErrorHandler() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        c.Next()
+        renderError(c)
+    }
+}
+
+// renderError writes the problem response of the last error in c.Errors
+// unless a response was already written. Middleware that looks at the
+// response after c.Next, such as Idempotency, calls it before reading the
+// status, because ErrorHandler runs only after it has returned.
+func renderError(c *gin.Context) {
+    if len(c.Errors) == 0 || c.Writer.Written() {
+        return
+    }
+    err := c.Errors.Last().Err
+    status := StatusFor(err)
+    problem := models.Problem{
+        Type:     "about:blank",
+        Title:    statusText(status),
+        Status:   status,
+        Detail:   err.Error(),
+        Instance: c.Request.URL.Path,
+    }
+    if status >= http.StatusInternalServerError {
+        // database and other internal errors are not shown to clients
+        problem.Detail = ""
+    }
+    body, _ := json.Marshal(problem)
+    c.Data(status, ProblemContentType, body)
+}
+
+// StatusFor maps a service error to an HTTP status code
+func StatusFor(err error) int {
+    switch {
+    case errors.Is(err, services.ErrValidation):
+        return http.StatusBadRequest
+    case errors.Is(err, services.ErrUnauthorized):
+        return http.StatusUnauthorized
+    case errors.Is(err, services.ErrForbidden):
+        return http.StatusForbidden
+    case errors.Is(err, services.ErrNotFound):
+        return http.StatusNotFound
+    case errors.Is(err, services.ErrConflict):
+        return http.StatusConflict
+    case errors.Is(err, services.ErrRateLimited):
+        return http.StatusTooManyRequests
+    case errors.Is(err, services.ErrUnavailable):
+        return http.StatusServiceUnavailable
+    case errors.Is(err, context.DeadlineExceeded):
+        return http.StatusGatewayTimeout
+    case errors.Is(err, context.Canceled):
+        return StatusClientClosedRequest
+    default:
+        return http.StatusInternalServerError
+    }
+}
+
+func statusText(status int) string {
+    if status == StatusClientClosedRequest {
+        return "Client Closed Request"
+    }
+    return http.StatusText(status)
+}
@@ -0,0 +1,153 @@
+package middleware
+
+import (
+    "bytes"
+    "context"
+    "crypto/sha256"
+    "encoding/hex"
+    "fmt"
+    "io"
+    "net/http"
+    "time"
+
+    "subscriptions_service_golang/internal/models"
+    "subscriptions_service_golang/internal/repositories"
+    "subscriptions_service_golang/internal/services"
+    "subscriptions_service_golang/pkg/logger"
+
+    "github.com/gin-gonic/gin"
+    "go.uber.org/zap"
+)
+
+// IdempotencyKeyHeader is the request header carrying the client's key
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// maxIdempotencyKeyLength matches the idempotency_keys.key column
+const maxIdempotencyKeyLength = 255
+
+// Idempotency replays the stored response when a request is retried with
+// the same Idempotency-Key header. Reusing a key with a different request
+// body, or while the first request is still running, is answered with 409.
+// Responses with a 5xx status, and requests whose handler panicked, are not
+// stored so the client can retry. A key that has been in progress for
+// longer than lease belongs to a request that can no longer be running,
+// for example on an instance that crashed, and is taken over. Keys are
+// scoped to the caller and the route, see scopedIdempotencyKey, so clients
+// picking the same key do not see each other's responses. It must run
+// after the auth middleware.
+func Idempotency(repo repositories.IdempotencyRepository, ttl, lease time.Duration) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        key := c.GetHeader(IdempotencyKeyHeader)
+        if key == "" {
+            c.Next()
+            return
+        }
+        if len(key) > maxIdempotencyKeyLength {
+            c.Error(fmt.Errorf("%w: Idempotency-Key is too long", services.ErrValidation))
+            c.Abort()
+            return
+        }
+        key = scopedIdempotencyKey(callerKey(c), c.Request.Method+" "+c.FullPath(), key)
+
+        body, err := io.ReadAll(c.Request.Body)
+        if err != nil {
+            c.Error(fmt.Errorf("%w: failed to read request body", services.ErrValidation))
+            c.Abort()
+            return
+        }
+        c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+        hash := sha256.New()
+        io.WriteString(hash, c.Request.Method+" "+c.Request.URL.RequestURI()+"\n")
+        hash.Write(body)
+        record := &models.IdempotencyKey{
+            Key:         key,
+            RequestHash: hex.EncodeToString(hash.Sum(nil)),
+            CreatedAt:   time.Now(),
+        }
+
+        ctx := c.Request.Context()
+        stored, created, err := repo.Reserve(ctx, record)
+        if err == nil && !created && (time.Since(stored.CreatedAt) > ttl || !stored.Completed && time.Since(stored.CreatedAt) > lease) {
+            // the old key has expired or was abandoned, it can be used again
+            if err = repo.Release(ctx, key); err == nil {
+                stored, created, err = repo.Reserve(ctx, record)
+            }
+        }
+        if err != nil {
+            logger.FromContext(ctx).Error("Failed to reserve idempotency key", zap.Error(err))
+            c.Error(fmt.Errorf("failed to check Idempotency-Key: %w", err))
+            c.Abort()
+            return
+        }
+
+        if !created {
+            switch {
+            case stored.RequestHash != record.RequestHash:
+                c.Error(fmt.Errorf("%w: Idempotency-Key was already used with a different request", services.ErrConflict))
+                c.Abort()
+            case !stored.Completed:
+                c.Error(fmt.Errorf("%w: a request with this Idempotency-Key is still in progress", services.ErrConflict))
+                c.Abort()
+            default:
+                c.Header("Idempotent-Replayed", "true")
+                c.Data(stored.StatusCode, stored.ContentType, stored.Response)
+                c.Abort()
+            }
+            return
+        }
+
+        // the outcome must be stored even if the client has already gone away,
+        // otherwise the key stays "in progress" until the lease runs out
+        ctx = context.WithoutCancel(ctx)
+        completed := false
+        defer func() {
+            // runs on a panic as well, which keeps unwinding to Recovery
+            if completed {
+                return
+            }
+            if err := repo.Release(ctx, key); err != nil {
+                logger.FromContext(ctx).Error("Failed to release idempotency key", zap.Error(err))
+            }
+        }()
+
+        recorder := &responseRecorder{ResponseWriter: c.Writer}
+        c.Writer = recorder
+        c.Next()
+        renderError(c)
+
+        if recorder.Status() >= http.StatusInternalServerError {
+            return
+        }
+        completed = true
+        record.StatusCode = recorder.Status()
+        record.ContentType = recorder.Header().Get("Content-Type")
+        record.Response = recorder.body.Bytes()
+        if err := repo.Complete(ctx, record); err != nil {
+            logger.FromContext(ctx).Error("Failed to store idempotent response", zap.Error(err))
+        }
+    }
+}
+
+// scopedIdempotencyKey is the key stored for the client's key of a caller
+// on a route. It is hashed to fit the key column whatever the client's key.
+func scopedIdempotencyKey(caller, route, key string) string {
+    sum := sha256.Sum256([]byte(caller + "\n" + route + "\n" + key))
+    return hex.EncodeToString(sum[:])
+}
+
+// responseRecorder keeps a copy of everything written to the response
+type responseRecorder struct {
+    gin.ResponseWriter
+    body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+    w.body.Write(b)
+    return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+    w.body.WriteString(s)
+    return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,208 @@
+package middleware
+
+import (
+    "bytes"
+    "context"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+
+    "subscriptions_service_golang/internal/models"
+    "subscriptions_service_golang/internal/services"
+    "subscriptions_service_golang/pkg/logger"
+
+    "github.com/gin-gonic/gin"
+    "github.com/stretchr/testify/assert"
+)
+
+type memoryIdempotencyRepo struct {
+    mu   sync.Mutex
+    keys map[string]models.IdempotencyKey
+}
+
+func (r *memoryIdempotencyRepo) Reserve(ctx context.Context, key *models.IdempotencyKey) (*models.IdempotencyKey, bool, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    if existing, ok := r.keys[key.Key]; ok {
+        return &existing, false, nil
+    }
+    r.keys[key.Key] = *key
+    return key, true, nil
+}
+
+func (r *memoryIdempotencyRepo) Complete(ctx context.Context, key *models.IdempotencyKey) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    key.Completed = true
+    r.keys[key.Key] = *key
+    return nil
+}
+
+func (r *memoryIdempotencyRepo) Release(ctx context.Context, key string) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    delete(r.keys, key)
+    return nil
+}
+
+func (r *memoryIdempotencyRepo) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+    return 0, nil
+}
+
+func TestIdempotency(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    logger.Init("info", "json")
+
+    repo := &memoryIdempotencyRepo{keys: make(map[string]models.IdempotencyKey)}
+    calls := 0
+    router := gin.New()
+    router.Use(ErrorHandler(), func(c *gin.Context) {
+        if user := c.GetHeader("X-User"); user != "" {
+            SetUser(c, user)
+        }
+    })
+    router.POST("/subscriptions", Idempotency(repo, time.Hour, time.Minute), func(c *gin.Context) {
+        calls++
+        switch c.GetHeader("X-Fail") {
+        case "":
+        case "unavailable":
+            c.Error(fmt.Errorf("%w: circuit breaker is open", services.ErrUnavailable))
+            return
+        case "panic":
+            panic("boom")
+        case "invalid":
+            c.Error(fmt.Errorf("%w: price must be positive", services.ErrValidation))
+            return
+        default:
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "boom"})
+            return
+        }
+        c.JSON(http.StatusCreated, gin.H{"id": calls})
+    })
+
+    router.POST("/subscriptions/import", Idempotency(repo, time.Hour, time.Minute), func(c *gin.Context) {
+        calls++
+        c.JSON(http.StatusOK, gin.H{"imported": calls})
+    })
+    // what the middleware stores for alice's key on POST /subscriptions
+    stored := func(key string) string {
+        return scopedIdempotencyKey("user:alice", "POST /subscriptions", key)
+    }
+
+    sendFailing := func(user, path, key, body, fail string) *httptest.ResponseRecorder {
+        req, _ := http.NewRequest("POST", path, bytes.NewBufferString(body))
+        req.Header.Set(IdempotencyKeyHeader, key)
+        req.Header.Set("X-User", user)
+        req.Header.Set("X-Fail", fail)
+        w := httptest.NewRecorder()
+        router.ServeHTTP(w, req)
+        return w
+    }
+    sendAs := func(user, path, key, body string, fail bool) *httptest.ResponseRecorder {
+        if fail {
+            return sendFailing(user, path, key, body, "1")
+        }
+        return sendFailing(user, path, key, body, "")
+    }
+    send := func(key, body string, fail bool) *httptest.ResponseRecorder {
+        return sendAs("alice", "/subscriptions", key, body, fail)
+    }
+
+    t.Run("retry replays the first response", func(t *testing.T) {
+        first := send("k1", `{"price":1}`, false)
+        second := send("k1", `{"price":1}`, false)
+
+        assert.Equal(t, http.StatusCreated, first.Code)
+        assert.Equal(t, http.StatusCreated, second.Code)
+        assert.Equal(t, first.Body.String(), second.Body.String())
+        assert.Equal(t, "true", second.Header().Get("Idempotent-Replayed"))
+        assert.Equal(t, 1, calls)
+    })
+
+    t.Run("different body conflicts", func(t *testing.T) {
+        w := send("k1", `{"price":2}`, false)
+        assert.Equal(t, http.StatusConflict, w.Code)
+        assert.Equal(t, ProblemContentType, w.Header().Get("Content-Type"))
+    })
+
+    t.Run("keys are scoped to the caller and the route", func(t *testing.T) {
+        calls = 0
+        bob := sendAs("bob", "/subscriptions", "k1", `{"price":1}`, false)
+        assert.Equal(t, http.StatusCreated, bob.Code)
+        assert.Empty(t, bob.Header().Get("Idempotent-Replayed"), "bob does not get alice's response")
+        assert.Equal(t, http.StatusOK, sendAs("alice", "/subscriptions/import", "k1", `{"price":1}`, false).Code)
+        assert.Equal(t, 2, calls)
+    })
+
+    t.Run("in progress conflicts", func(t *testing.T) {
+        repo.Reserve(context.Background(), &models.IdempotencyKey{Key: stored("k2"), RequestHash: "x", CreatedAt: time.Now()})
+        w := send("k2", `{}`, false)
+        assert.Equal(t, http.StatusConflict, w.Code)
+    })
+
+    t.Run("abandoned key is taken over", func(t *testing.T) {
+        calls = 0
+        repo.keys[stored("k7")] = models.IdempotencyKey{Key: stored("k7"), RequestHash: "x", CreatedAt: time.Now().Add(-2 * time.Minute)}
+        assert.Equal(t, http.StatusCreated, send("k7", `{}`, false).Code)
+        assert.Equal(t, 1, calls)
+    })
+
+    t.Run("panic releases the key", func(t *testing.T) {
+        calls = 0
+        assert.PanicsWithValue(t, "boom", func() { sendFailing("alice", "/subscriptions", "k8", `{}`, "panic") })
+        assert.NotContains(t, repo.keys, stored("k8"))
+        assert.Equal(t, http.StatusCreated, send("k8", `{}`, false).Code)
+        assert.Equal(t, 2, calls)
+    })
+
+    t.Run("too long key", func(t *testing.T) {
+        w := send(strings.Repeat("k", maxIdempotencyKeyLength+1), `{}`, false)
+        assert.Equal(t, http.StatusBadRequest, w.Code)
+        assert.Equal(t, ProblemContentType, w.Header().Get("Content-Type"))
+    })
+
+    t.Run("server errors are not stored", func(t *testing.T) {
+        calls = 0
+        assert.Equal(t, http.StatusInternalServerError, send("k3", `{}`, true).Code)
+        assert.Equal(t, http.StatusCreated, send("k3", `{}`, false).Code)
+        assert.Equal(t, 2, calls)
+    })
+
+    t.Run("errors raised with c.Error are stored as problems", func(t *testing.T) {
+        calls = 0
+        w := sendFailing("alice", "/subscriptions", "k5", `{}`, "unavailable")
+        assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+        assert.Equal(t, ProblemContentType, w.Header().Get("Content-Type"))
+        assert.Equal(t, http.StatusCreated, send("k5", `{}`, false).Code, "a 5xx is not replayed")
+        assert.Equal(t, 2, calls)
+
+        calls = 0
+        first := sendFailing("alice", "/subscriptions", "k6", `{}`, "invalid")
+        second := sendFailing("alice", "/subscriptions", "k6", `{}`, "invalid")
+        assert.Equal(t, http.StatusBadRequest, first.Code)
+        assert.Equal(t, http.StatusBadRequest, second.Code)
+        assert.Equal(t, "true", second.Header().Get("Idempotent-Replayed"))
+        assert.Equal(t, ProblemContentType, second.Header().Get("Content-Type"))
+        assert.Equal(t, first.Body.String(), second.Body.String())
+        assert.Contains(t, second.Body.String(), "price must be positive")
+        assert.Equal(t, 1, calls)
+    })
+
+    t.Run("expired key is reused", func(t *testing.T) {
+        calls = 0
+        repo.keys[stored("k4")] = models.IdempotencyKey{Key: stored("k4"), RequestHash: "old", Completed: true, CreatedAt: time.Now().Add(-2 * time.Hour)}
+        assert.Equal(t, http.StatusCreated, send("k4", `{}`, false).Code)
+        assert.Equal(t, 1, calls)
+    })
+
+    t.Run("no key", func(t *testing.T) {
+        calls = 0
+        send("", `{}`, false)
+        send("", `{}`, false)
+        assert.Equal(t, 2, calls)
+    })
+}
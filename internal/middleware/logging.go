@@ -0,0 +1,130 @@
+package middleware
+
+import (
+    "fmt"
+    "net/http"
+    "time"
+
+    "subscriptions_service_golang/pkg/logger"
+
+    "github.com/gin-gonic/gin"
+    "github.com/google/uuid"
+    "go.opentelemetry.io/otel/trace"
+    "go.uber.org/zap"
+)
+
+const (
+    // RequestIDHeader carries the request ID in both directions
+    RequestIDHeader = "X-Request-ID"
+    // RequestIDKey is the gin context key holding the request ID
+    RequestIDKey = "request_id"
+    // UserIDKey is the gin context key holding the authenticated user
+    UserIDKey = "user_id"
+
+    maxRequestIDLength = 128
+)
+
+// RequestID takes the request ID from the X-Request-ID header, or
+// generates one when it is missing or malformed, and echoes it back.
+func RequestID() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        id := c.GetHeader(RequestIDHeader)
+        if !validRequestID(id) {
+            id = uuid.NewString()
+        }
+        c.Set(RequestIDKey, id)
+        c.Header(RequestIDHeader, id)
+        c.Next()
+    }
+}
+
+// validRequestID accepts short IDs made of characters that are safe to
+// put into logs and response headers
+func validRequestID(id string) bool {
+    if id == "" || len(id) > maxRequestIDLength {
+        return false
+    }
+    for _, r := range id {
+        switch {
+        case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+        case r == '-', r == '_', r == '.', r == ':':
+        default:
+            return false
+        }
+    }
+    return true
+}
+
+// AccessLog attaches a request-scoped logger to the request context and
+// writes one log line per request once it has been handled. The logger
+// carries the request ID, route and, when tracing is on, the trace ID, so
+// everything logged through logger.FromContext can be correlated.
+func AccessLog() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        start := time.Now()
+        ctx := c.Request.Context()
+
+        fields := []zap.Field{
+            zap.String("request_id", c.GetString(RequestIDKey)),
+            zap.String("method", c.Request.Method),
+            zap.String("route", c.FullPath()),
+        }
+        if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+            fields = append(fields,
+                zap.String("trace_id", span.TraceID().String()),
+                zap.String("span_id", span.SpanID().String()),
+            )
+        }
+        c.Request = c.Request.WithContext(logger.WithContext(ctx, logger.Log.With(fields...)))
+
+        c.Next()
+
+        status := c.Writer.Status()
+        fields = []zap.Field{
+            zap.String("path", c.Request.URL.Path),
+            zap.Int("status", status),
+            zap.Duration("latency", time.Since(start)),
+            zap.Int("size", c.Writer.Size()),
+            zap.String("client_ip", c.ClientIP()),
+        }
+        if len(c.Errors) > 0 {
+            fields = append(fields, zap.String("error", c.Errors.Last().Error()))
+        }
+        log := logger.FromContext(c.Request.Context())
+        switch {
+        case status >= http.StatusInternalServerError:
+            log.Error("Request handled", fields...)
+        case status >= http.StatusBadRequest:
+            log.Warn("Request handled", fields...)
+        default:
+            log.Info("Request handled", fields...)
+        }
+    }
+}
+
+// SetUser records the authenticated user on the gin context and adds it
+// to the request-scoped logger
+func SetUser(c *gin.Context, userID string) {
+    c.Set(UserIDKey, userID)
+    ctx := c.Request.Context()
+    l := logger.FromContext(ctx).With(zap.String("user_id", userID))
+    c.Request = c.Request.WithContext(logger.WithContext(ctx, l))
+}
+
+// Recovery turns a panic in a handler into a 500 problem response and logs
+// it with the request fields. It must be registered after ErrorHandler.
+func Recovery() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        defer func() {
+            if rec := recover(); rec != nil {
+                if rec == http.ErrAbortHandler {
+                    panic(rec)
+                }
+                logger.FromContext(c.Request.Context()).Error("Panic recovered", zap.Any("panic", rec), zap.Stack("stack"))
+                c.Error(fmt.Errorf("panic: %v", rec))
+                c.Abort()
+            }
+        }()
+        c.Next()
+    }
+}
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "testing"
+
+    "subscriptions_service_golang/pkg/logger"
+
+    "github.com/gin-gonic/gin"
+    "github.com/stretchr/testify/assert"
+    "go.uber.org/zap"
+    "go.uber.org/zap/zapcore"
+    "go.uber.org/zap/zaptest/observer"
+)
+
+func TestRequestLogging(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    core, logs := observer.New(zapcore.InfoLevel)
+    logger.Log = zap.New(core)
+    defer func() { logger.Log = zap.NewNop() }()
+
+    r := gin.New()
+    r.Use(RequestID(), AccessLog(), ErrorHandler(), Recovery())
+    r.GET("/subscriptions/:id", AuthMiddleware(true), func(c *gin.Context) {
+        logger.FromContext(c.Request.Context()).Info("Handler called")
+        c.Status(http.StatusOK)
+    })
+    r.GET("/panic", func(c *gin.Context) { panic("boom") })
+
+    t.Run("request id is propagated", func(t *testing.T) {
+        logs.TakeAll()
+        req := httptest.NewRequest(http.MethodGet, "/subscriptions/1", nil)
+        req.Header.Set(RequestIDHeader, "abc-123")
+        req.Header.Set("Authorization", "Bearer test-token")
+        w := httptest.NewRecorder()
+        r.ServeHTTP(w, req)
+
+        assert.Equal(t, http.StatusOK, w.Code)
+        assert.Equal(t, "abc-123", w.Header().Get(RequestIDHeader))
+        entries := logs.TakeAll()
+        assert.Len(t, entries, 2)
+        for _, e := range entries {
+            fields := e.ContextMap()
+            assert.Equal(t, "abc-123", fields["request_id"])
+            assert.Equal(t, "admin", fields["user_id"])
+            assert.Equal(t, "/subscriptions/:id", fields["route"])
+        }
+        assert.EqualValues(t, http.StatusOK, entries[1].ContextMap()["status"])
+    })
+
+    t.Run("invalid request id is replaced", func(t *testing.T) {
+        logs.TakeAll()
+        req := httptest.NewRequest(http.MethodGet, "/subscriptions/1", nil)
+        req.Header.Set(RequestIDHeader, "bad id\n")
+        w := httptest.NewRecorder()
+        r.ServeHTTP(w, req)
+
+        id := w.Header().Get(RequestIDHeader)
+        assert.Len(t, id, 36)
+        entries := logs.TakeAll()
+        assert.Len(t, entries, 1)
+        assert.Equal(t, zapcore.WarnLevel, entries[0].Level)
+        assert.Equal(t, id, entries[0].ContextMap()["request_id"])
+    })
+
+    t.Run("panic is recovered", func(t *testing.T) {
+        logs.TakeAll()
+        w := httptest.NewRecorder()
+        r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+        assert.Equal(t, http.StatusInternalServerError, w.Code)
+        assert.Equal(t, ProblemContentType, w.Header().Get("Content-Type"))
+        assert.Equal(t, 1, logs.FilterMessage("Panic recovered").Len())
+        assert.Equal(t, 1, logs.FilterMessage("Request handled").FilterField(zap.Int("status", http.StatusInternalServerError)).Len())
+    })
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+    "strconv"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics records the number and latency of HTTP requests labelled by
+// method, route template and status code. Requests that match no route are
+// counted under the "unmatched" route so random paths can't blow up the
+// label cardinality.
+func Metrics(reg prometheus.Registerer) gin.HandlerFunc {
+    requests := prometheus.NewCounterVec(prometheus.CounterOpts{
+        Name: "http_requests_total",
+        Help: "Number of HTTP requests by method, route and status.",
+    }, []string{"method", "route", "status"})
+    duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+        Name:    "http_request_duration_seconds",
+        Help:    "HTTP request latency by method, route and status.",
+        Buckets: prometheus.DefBuckets,
+    }, []string{"method", "route", "status"})
+    reg.MustRegister(requests, duration)
+
+    return func(c *gin.Context) {
+        start := time.Now()
+        c.Next()
+
+        route := c.FullPath()
+        if route == "" {
+            route = "unmatched"
+        }
+        status := strconv.Itoa(c.Writer.Status())
+        requests.WithLabelValues(c.Request.Method, route, status).Inc()
+        duration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+    }
+}
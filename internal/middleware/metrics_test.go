@@ -0,0 +1,42 @@
+package middleware
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "testing"
+
+    "github.com/gin-gonic/gin"
+    "github.com/prometheus/client_golang/prometheus"
+    "github.com/prometheus/client_golang/prometheus/testutil"
+    "github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    reg := prometheus.NewRegistry()
+    r := gin.New()
+    r.Use(Metrics(reg))
+    r.GET("/subscriptions/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+    for _, path := range []string{"/subscriptions/1", "/subscriptions/2", "/nope"} {
+        r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+    }
+
+    families, err := reg.Gather()
+    assert.NoError(t, err)
+    counts := map[string]float64{}
+    for _, f := range families {
+        if f.GetName() != "http_requests_total" {
+            continue
+        }
+        for _, m := range f.GetMetric() {
+            labels := map[string]string{}
+            for _, l := range m.GetLabel() {
+                labels[l.GetName()] = l.GetValue()
+            }
+            counts[labels["route"]+" "+labels["status"]] = m.GetCounter().GetValue()
+        }
+    }
+    assert.Equal(t, map[string]float64{"/subscriptions/:id 200": 2, "unmatched 404": 1}, counts)
+    assert.Equal(t, 2, testutil.CollectAndCount(reg, "http_request_duration_seconds"))
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+    "fmt"
+    "math"
+    "strconv"
+    "time"
+
+    "subscriptions_service_golang/internal/ratelimit"
+    "subscriptions_service_golang/internal/services"
+    "subscriptions_service_golang/pkg/logger"
+
+    "github.com/gin-gonic/gin"
+    "go.uber.org/zap"
+)
+
+// RateLimit takes a token from the caller's bucket of the route and
+// rejects the request with 429 when it is empty. Every route has its own
+// buckets, limited by rules. The RateLimit-* headers of the IETF draft
+// describe the bucket, Retry-After is added to rejections. When the store
+// fails the request is let through. It must run after the auth middleware,
+// see callerKey.
+func RateLimit(store ratelimit.Store, rules ratelimit.Rules) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        route := c.Request.Method + " " + c.FullPath()
+        limit := rules.For(route)
+        if limit.Unlimited() {
+            c.Next()
+            return
+        }
+
+        d, err := store.Take(c.Request.Context(), route+" "+callerKey(c), limit)
+        if err != nil {
+            logger.FromContext(c.Request.Context()).Warn("Rate limiter is unavailable", zap.Error(err))
+            c.Next()
+            return
+        }
+        c.Header("RateLimit-Limit", strconv.Itoa(limit.Requests))
+        c.Header("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+        c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(d.Reset)))
+        c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit.Requests, ceilSeconds(limit.Period)))
+        if !d.Allowed {
+            retry := max(ceilSeconds(d.RetryAfter), 1)
+            c.Header("Retry-After", strconv.Itoa(retry))
+            c.Error(fmt.Errorf("%w: retry in %d seconds", services.ErrRateLimited, retry))
+            c.Abort()
+            return
+        }
+        c.Next()
+    }
+}
+
+// callerKey identifies the caller for rate limits and idempotency keys: by
+// user when the request was authenticated, whichever of the user's API keys
+// it used, since every login hands out a new one; otherwise by client IP.
+func callerKey(c *gin.Context) string {
+    if user := c.GetString(UserIDKey); user != "" {
+        return "user:" + user
+    }
+    return "ip:" + c.ClientIP()
+}
+
+func ceilSeconds(d time.Duration) int {
+    return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,114 @@
+package middleware
+
+import (
+    "context"
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+
+    "subscriptions_service_golang/internal/ratelimit"
+
+    "github.com/gin-gonic/gin"
+    "github.com/stretchr/testify/assert"
+)
+
+// brokenStore fails like an unreachable Redis
+type brokenStore struct{}
+
+func (brokenStore) Take(context.Context, string, ratelimit.Limit) (ratelimit.Decision, error) {
+    return ratelimit.Decision{}, errors.New("connection refused")
+}
+
+func TestRateLimit(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    rules := ratelimit.Rules{
+        Default: ratelimit.Limit{Requests: 2, Period: time.Minute},
+        Routes: map[string]ratelimit.Limit{
+            "POST /login":            {Requests: 1, Period: time.Minute},
+            "GET /subscriptions/:id": {},
+        },
+    }
+    newRouter := func(store ratelimit.Store) *gin.Engine {
+        r := gin.New()
+        r.Use(ErrorHandler(), func(c *gin.Context) {
+            if user := c.GetHeader("X-User"); user != "" {
+                SetUser(c, user)
+            }
+        }, RateLimit(store, rules))
+        ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+        r.POST("/login", ok)
+        r.GET("/subscriptions", ok)
+        r.GET("/subscriptions/:id", ok)
+        return r
+    }
+    r := newRouter(ratelimit.NewMemory())
+    request := func(method, path, user, token, ip string) *httptest.ResponseRecorder {
+        req := httptest.NewRequest(method, path, nil)
+        req.Header.Set("X-User", user)
+        if token != "" {
+            req.Header.Set("Authorization", "Bearer "+token)
+        }
+        req.RemoteAddr = ip + ":1234"
+        w := httptest.NewRecorder()
+        r.ServeHTTP(w, req)
+        return w
+    }
+
+    w := request(http.MethodPost, "/login", "", "", "10.0.0.1")
+    assert.Equal(t, http.StatusOK, w.Code)
+    assert.Equal(t, "1", w.Header().Get("RateLimit-Limit"))
+    assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+    assert.Equal(t, "60", w.Header().Get("RateLimit-Reset"))
+    assert.Equal(t, "1;w=60", w.Header().Get("RateLimit-Policy"))
+
+    w = request(http.MethodPost, "/login", "", "", "10.0.0.1")
+    assert.Equal(t, http.StatusTooManyRequests, w.Code)
+    assert.Equal(t, "60", w.Header().Get("Retry-After"))
+    assert.Equal(t, ProblemContentType, w.Header().Get("Content-Type"))
+    assert.Equal(t, http.StatusOK, request(http.MethodPost, "/login", "", "", "10.0.0.2").Code, "anonymous callers are told apart by address")
+
+    assert.Equal(t, http.StatusOK, request(http.MethodGet, "/subscriptions", "", "", "10.0.0.1").Code, "routes have their own buckets")
+
+    // alice spends her budget with one key; a new key from another login does not refill it
+    for range 2 {
+        assert.Equal(t, http.StatusOK, request(http.MethodGet, "/subscriptions", "alice", "sk_first", "10.0.0.3").Code)
+    }
+    assert.Equal(t, http.StatusTooManyRequests, request(http.MethodGet, "/subscriptions", "alice", "sk_first", "10.0.0.4").Code)
+    assert.Equal(t, http.StatusTooManyRequests, request(http.MethodGet, "/subscriptions", "alice", "sk_second", "10.0.0.3").Code)
+    assert.Equal(t, http.StatusOK, request(http.MethodGet, "/subscriptions", "bob", "sk_third", "10.0.0.3").Code)
+    assert.Equal(t, http.StatusOK, request(http.MethodGet, "/subscriptions", "", "sk_first", "10.0.0.5").Code, "unchecked tokens count by address")
+
+    for range 5 {
+        w = request(http.MethodGet, "/subscriptions/1", "", "", "10.0.0.1")
+        assert.Equal(t, http.StatusOK, w.Code, "a zero limit does not limit")
+    }
+    assert.Empty(t, w.Header().Get("RateLimit-Limit"))
+
+    // X-Forwarded-For counts only when the request comes from a trusted proxy
+    for _, tt := range []struct {
+        proxies []string
+        want    int
+    }{
+        {nil, http.StatusTooManyRequests},
+        {[]string{"10.0.0.0/8"}, http.StatusOK},
+    } {
+        r = newRouter(ratelimit.NewMemory())
+        assert.NoError(t, r.SetTrustedProxies(tt.proxies))
+        var w *httptest.ResponseRecorder
+        for _, forwardedFor := range []string{"203.0.113.1", "203.0.113.2"} {
+            req := httptest.NewRequest(http.MethodPost, "/login", nil)
+            req.Header.Set("X-Forwarded-For", forwardedFor)
+            req.RemoteAddr = "10.0.0.1:1234"
+            w = httptest.NewRecorder()
+            r.ServeHTTP(w, req)
+        }
+        assert.Equal(t, tt.want, w.Code, "trusted proxies %v", tt.proxies)
+    }
+
+    r = newRouter(brokenStore{})
+    for range 3 {
+        assert.Equal(t, http.StatusOK, request(http.MethodPost, "/login", "", "", "10.0.0.1").Code, "a broken store lets requests through")
+    }
+}
@@ -0,0 +1,92 @@
+package middleware
+
+import (
+    "net/http"
+    "sync"
+    "time"
+
+    "subscriptions_service_golang/internal/repositories"
+
+    "github.com/gin-gonic/gin"
+)
+
+// WriteTracker remembers when each caller last changed data, so that its
+// reads can be kept on the primary until replicas have caught up
+type WriteTracker struct {
+    window time.Duration
+    now    func() time.Time
+
+    mu        sync.Mutex
+    writes    map[string]time.Time
+    lastSweep time.Time
+}
+
+// NewWriteTracker pins callers to the primary for window after a write;
+// zero disables pinning
+func NewWriteTracker(window time.Duration) *WriteTracker {
+    return &WriteTracker{window: window, now: time.Now, writes: make(map[string]time.Time)}
+}
+
+func (t *WriteTracker) record(keys ...string) {
+    if t.window <= 0 {
+        return
+    }
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    now := t.now()
+    for _, key := range keys {
+        t.writes[key] = now
+    }
+    // forget callers whose window has passed
+    if now.Sub(t.lastSweep) > t.window {
+        for key, at := range t.writes {
+            if now.Sub(at) >= t.window {
+                delete(t.writes, key)
+            }
+        }
+        t.lastSweep = now
+    }
+}
+
+func (t *WriteTracker) recent(keys ...string) bool {
+    if t.window <= 0 {
+        return false
+    }
+    t.mu.Lock()
+    defer t.mu.Unlock()
+    now := t.now()
+    for _, key := range keys {
+        if at, ok := t.writes[key]; ok && now.Sub(at) < t.window {
+            return true
+        }
+    }
+    return false
+}
+
+// ReadYourWrites keeps a request on the primary database when it may
+// depend on data that has not reached the replicas: every mutating
+// request, because services read before they write, and every request of
+// a caller that wrote within the tracker's window. Callers are recognised
+// by user ID and by client IP, so a write made with a token is also seen
+// by anonymous reads from the same address. It must run after the auth
+// middleware.
+func ReadYourWrites(tracker *WriteTracker) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        keys := []string{"ip:" + c.ClientIP()}
+        if user := c.GetString(UserIDKey); user != "" {
+            keys = append(keys, "user:"+user)
+        }
+
+        mutating := c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead && c.Request.Method != http.MethodOptions
+        if mutating || tracker.recent(keys...) {
+            c.Request = c.Request.WithContext(repositories.WithPrimary(c.Request.Context()))
+        }
+        c.Next()
+
+        // errors raised with c.Error are only written by ErrorHandler, after
+        // this returns, so the status still reads 200 for them
+        if mutating && len(c.Errors) == 0 && c.Writer.Status() < http.StatusBadRequest {
+            tracker.record(keys...)
+        }
+    }
+}
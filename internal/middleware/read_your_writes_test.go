@@ -0,0 +1,74 @@
+package middleware
+
+import (
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+
+    "subscriptions_service_golang/internal/repositories"
+    "subscriptions_service_golang/internal/services"
+
+    "github.com/gin-gonic/gin"
+    "github.com/stretchr/testify/assert"
+)
+
+func TestReadYourWrites(t *testing.T) {
+    gin.SetMode(gin.TestMode)
+    now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+    tracker := NewWriteTracker(5 * time.Second)
+    tracker.now = func() time.Time { return now }
+
+    var onPrimary bool
+    handler := func(c *gin.Context) {
+        onPrimary = repositories.UsesPrimary(c.Request.Context())
+        c.Status(http.StatusOK)
+    }
+    r := gin.New()
+    r.Use(func(c *gin.Context) {
+        if user := c.GetHeader("X-User"); user != "" {
+            SetUser(c, user)
+        }
+    }, ReadYourWrites(tracker))
+    r.GET("/subscriptions", handler)
+    r.POST("/subscriptions", handler)
+    r.PUT("/subscriptions/1", func(c *gin.Context) { c.Status(http.StatusBadRequest) })
+    r.DELETE("/subscriptions/1", func(c *gin.Context) {
+        c.Error(fmt.Errorf("%w: subscription 1", services.ErrNotFound))
+    })
+
+    request := func(method, user, ip string) {
+        req := httptest.NewRequest(method, "/subscriptions", nil)
+        if method == http.MethodPut || method == http.MethodDelete {
+            req = httptest.NewRequest(method, "/subscriptions/1", nil)
+        }
+        req.Header.Set("X-User", user)
+        req.RemoteAddr = ip + ":1234"
+        r.ServeHTTP(httptest.NewRecorder(), req)
+    }
+
+    request(http.MethodGet, "alice", "10.0.0.1")
+    assert.False(t, onPrimary, "reads go to replicas by default")
+
+    request(http.MethodPut, "bob", "10.0.0.2")
+    request(http.MethodGet, "bob", "10.0.0.2")
+    assert.False(t, onPrimary, "failed writes do not pin")
+    request(http.MethodDelete, "bob", "10.0.0.2")
+    request(http.MethodGet, "bob", "10.0.0.2")
+    assert.False(t, onPrimary, "neither do writes failed with c.Error")
+
+    request(http.MethodPost, "alice", "10.0.0.1")
+    assert.True(t, onPrimary, "writes always use the primary")
+
+    request(http.MethodGet, "alice", "10.0.0.9")
+    assert.True(t, onPrimary, "the writer is pinned by user")
+    request(http.MethodGet, "", "10.0.0.1")
+    assert.True(t, onPrimary, "and by address")
+    request(http.MethodGet, "bob", "10.0.0.2")
+    assert.False(t, onPrimary, "other callers are not")
+
+    now = now.Add(5 * time.Second)
+    request(http.MethodGet, "alice", "10.0.0.1")
+    assert.False(t, onPrimary, "the pin expires")
+}
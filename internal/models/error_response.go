@@ -4,3 +4,12 @@ package models
 type ErrorResponse struct {
     Error string `json:"error" example:"invalid request"`
 }
+
+// Problem represents an RFC 7807 problem details error body
+type Problem struct {
+    Type     string `json:"type" example:"about:blank"`
+    Title    string `json:"title" example:"Not Found"`
+    Status   int    `json:"status" example:"404"`
+    Detail   string `json:"detail,omitempty" example:"subscription 42 not found"`
+    Instance string `json:"instance,omitempty" example:"/subscriptions/42"`
+}
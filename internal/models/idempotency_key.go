@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// IdempotencyKey stores the response of a request made with an
+// Idempotency-Key header so that retries can be answered without
+// executing the request again. Completed is false while the first
+// request is still being processed.
+type IdempotencyKey struct {
+    Key         string    `gorm:"primaryKey;type:varchar(255)"`
+    RequestHash string    `gorm:"type:char(64);not null"`
+    Completed   bool      `gorm:"not null;default:false"`
+    StatusCode  int
+    ContentType string    `gorm:"type:varchar(255)"`
+    Response    []byte
+    CreatedAt   time.Time `gorm:"index"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// LoginResult is the outcome of a login attempt
+type LoginResult string
+
+const (
+    LoginSucceeded LoginResult = "success"
+    LoginFailed    LoginResult = "failure"
+    // LoginLocked attempts were rejected without checking the password
+    LoginLocked LoginResult = "locked"
+)
+
+// LoginAttempt is one entry of the login audit log
+type LoginAttempt struct {
+    ID        uint        `json:"id" example:"1"`
+    Username  string      `json:"username" gorm:"type:varchar(255);not null" example:"alice"`
+    IP        string      `json:"ip" gorm:"type:varchar(64);not null" example:"203.0.113.7"`
+    Result    LoginResult `json:"result" gorm:"type:varchar(16);not null" example:"failure"`
+    CreatedAt time.Time   `json:"created_at" example:"2026-01-28T15:04:05Z"`
+}
+
+// LoginAttemptFilter narrows down the audit log; Limit caps the newest
+// entries returned
+type LoginAttemptFilter struct {
+    Username string
+    IP       string
+    Limit    int
+}
+
+// LoginLockout counts the recent failed logins of a username or a client
+// address. Key is "user:" plus the lower-cased username or "ip:" plus the
+// address. Logins are refused until LockedUntil.
+type LoginLockout struct {
+    Key           string     `json:"key" gorm:"type:varchar(320);primaryKey" example:"user:alice"`
+    Failures      int        `json:"failures" gorm:"not null" example:"5"`
+    LastFailureAt time.Time  `json:"last_failure_at" gorm:"not null;index" example:"2026-01-28T15:04:05Z"`
+    LockedUntil   *time.Time `json:"locked_until,omitempty" example:"2026-01-28T15:19:05Z"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// MonthlySpend is what one subscription is billed for one month, kept so
+// that totals over long periods do not have to replay the billing rules
+// for every row. Month is the first day of the month in UTC.
+type MonthlySpend struct {
+    SubscriptionID uint      `gorm:"primaryKey;autoIncrement:false"`
+    Month          time.Time `gorm:"primaryKey;index:idx_monthly_spend_user_month,priority:2"`
+    UserID         string    `gorm:"type:uuid;not null;index:idx_monthly_spend_user_month,priority:1"`
+    ServiceName    string    `gorm:"type:varchar(255);not null"`
+    Amount         int       `gorm:"not null"`
+}
+
+func (MonthlySpend) TableName() string {
+    return "monthly_spend"
+}
+
+// MonthlySpendState records the last month for which the monthly_spend
+// rows of every subscription are complete. There is at most one row.
+type MonthlySpendState struct {
+    ID      int       `gorm:"primaryKey;autoIncrement:false"`
+    Through time.Time `gorm:"not null"`
+}
+
+func (MonthlySpendState) TableName() string {
+    return "monthly_spend_state"
+}
@@ -2,15 +2,37 @@ package models
 
 import "time"
 
+// SubscriptionStatus represents the lifecycle state of a subscription
+type SubscriptionStatus string
+
+const (
+    StatusTrial     SubscriptionStatus = "trial"
+    StatusActive    SubscriptionStatus = "active"
+    StatusPaused    SubscriptionStatus = "paused"
+    StatusCancelled SubscriptionStatus = "cancelled"
+    StatusExpired   SubscriptionStatus = "expired"
+)
+
 // Subscription represents a subscription object
 type Subscription struct {
-    ID          uint       `json:"id" example:"1"`
-    CreatedAt   time.Time  `json:"created_at" example:"2026-01-28T15:04:05Z"`
-    UpdatedAt   time.Time  `json:"updated_at" example:"2026-01-28T15:04:05Z"`
-    DeletedAt   *time.Time `json:"deleted_at,omitempty"`
-    ServiceName string     `json:"service_name" example:"Netflix"`
-    Price       int        `json:"price" example:"4500"`
-    UserID      string     `json:"user_id" example:"123e4567-e89b-12d3-a456-426614174000"`
-    StartDate   time.Time  `json:"start_date" example:"2026-01-28"`
-    EndDate     *time.Time `json:"end_date,omitempty" example:"2026-06-28"`
+    ID          uint               `json:"id" example:"1"`
+    CreatedAt   time.Time          `json:"created_at" example:"2026-01-28T15:04:05Z"`
+    UpdatedAt   time.Time          `json:"updated_at" example:"2026-01-28T15:04:05Z"`
+    ServiceName string             `json:"service_name" example:"Netflix"`
+    Price       int                `json:"price" example:"4500"`
+    UserID      string             `json:"user_id" example:"123e4567-e89b-12d3-a456-426614174000"`
+    StartDate   time.Time          `json:"start_date" example:"2026-01-28"`
+    EndDate     *time.Time         `json:"end_date,omitempty" example:"2026-06-28"`
+    Status      SubscriptionStatus `json:"status" gorm:"type:varchar(16);not null;default:active" example:"active"`
+    TrialEndsAt *time.Time         `json:"trial_ends_at,omitempty" example:"2026-02-28"`
+    CancelledAt *time.Time         `json:"cancelled_at,omitempty"`
+    Duplicate   bool               `json:"duplicate" gorm:"not null;default:false"`
+}
+
+// SubscriptionPause represents a period during which a subscription was paused
+type SubscriptionPause struct {
+    ID             uint       `json:"id" example:"1"`
+    SubscriptionID uint       `json:"subscription_id" gorm:"not null;index" example:"1"`
+    PausedAt       time.Time  `json:"paused_at" example:"2026-03-01T00:00:00Z"`
+    ResumedAt      *time.Time `json:"resumed_at,omitempty" example:"2026-04-01T00:00:00Z"`
 }
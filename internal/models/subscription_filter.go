@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// SubscriptionFilter narrows down the subscriptions returned by List.
+// From and To select subscriptions whose active period overlaps the range,
+// Active keeps only trial and active subscriptions that have not ended yet.
+type SubscriptionFilter struct {
+    UserID      string
+    ServiceName string
+    From        *time.Time
+    To          *time.Time
+    Active      bool
+}
@@ -0,0 +1,10 @@
+package models
+
+// SubscriptionStats is a snapshot of the subscriptions that are live today.
+// Active counts trial and active subscriptions that have not ended,
+// MonthlySpend sums the monthly price of those that are billed right now,
+// or, once the monthly_spend rollup is built, what the current month bills.
+type SubscriptionStats struct {
+    Active       int64 `json:"active"`
+    MonthlySpend int64 `json:"monthly_spend"`
+}
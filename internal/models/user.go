@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// UserRole decides what a user may do through the API
+type UserRole string
+
+const (
+    RoleUser  UserRole = "user"
+    RoleAdmin UserRole = "admin"
+)
+
+// User is an account that can log in and own API keys
+type User struct {
+    ID           string    `json:"id" gorm:"type:uuid;primaryKey" example:"123e4567-e89b-12d3-a456-426614174000"`
+    Username     string    `json:"username" gorm:"type:varchar(255);not null" example:"alice"`
+    PasswordHash string    `json:"-" gorm:"type:varchar(255);not null"`
+    Role         UserRole  `json:"role" gorm:"type:varchar(16);not null;default:user" example:"user"`
+    CreatedAt    time.Time `json:"created_at" example:"2026-01-28T15:04:05Z"`
+}
+
+// APIKey is a long-lived bearer token issued to a user. Only the SHA-256
+// hash of the token is stored; Prefix keeps its first characters so that
+// operators can tell keys apart.
+type APIKey struct {
+    ID         uint       `json:"id" example:"1"`
+    UserID     string     `json:"user_id" gorm:"type:uuid;not null" example:"123e4567-e89b-12d3-a456-426614174000"`
+    Name       string     `json:"name" gorm:"type:varchar(255);not null" example:"billing-export"`
+    Prefix     string     `json:"prefix" gorm:"type:varchar(16);not null" example:"sk_3f9a1c"`
+    KeyHash    string     `json:"-" gorm:"type:char(64);not null"`
+    CreatedAt  time.Time  `json:"created_at" example:"2026-01-28T15:04:05Z"`
+    ExpiresAt  *time.Time `json:"expires_at,omitempty" example:"2027-01-28T15:04:05Z"`
+    LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
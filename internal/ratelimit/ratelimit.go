@@ -0,0 +1,168 @@
+// Package ratelimit implements token buckets kept in memory or in Redis.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limit allows Requests per Period. A client may spend all of them at once,
+// after which they come back evenly over the period. The zero Limit does
+// not limit at all.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// ParseLimit parses "requests/period", e.g. "10/1m" or "5/s". The period is
+// a Go duration, a bare unit means one of it. An empty string is no limit.
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Limit{}, nil
+	}
+	requests, period, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("limit %q is not requests/period", s)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(requests))
+	if err != nil || n <= 0 {
+		return Limit{}, fmt.Errorf("limit %q: requests must be a positive number", s)
+	}
+	period = strings.TrimSpace(period)
+	if period != "" && (period[0] < '0' || period[0] > '9') {
+		period = "1" + period
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("limit %q: period must be a positive duration", s)
+	}
+	return Limit{Requests: n, Period: d}, nil
+}
+
+func (l Limit) Unlimited() bool {
+	return l.Requests <= 0
+}
+
+func (l Limit) String() string {
+	if l.Unlimited() {
+		return ""
+	}
+	return fmt.Sprintf("%d/%s", l.Requests, l.Period)
+}
+
+// Rules holds the limit of every route, "METHOD /path" as registered with
+// the router; Default applies to the routes not listed.
+type Rules struct {
+	Default Limit
+	Routes  map[string]Limit
+}
+
+func (r Rules) For(route string) Limit {
+	if l, ok := r.Routes[route]; ok {
+		return l
+	}
+	return r.Default
+}
+
+// Decision is the outcome of taking a token from a bucket
+type Decision struct {
+	Allowed   bool
+	Remaining int
+	// RetryAfter is when the next token is available, zero if allowed
+	RetryAfter time.Duration
+	// Reset is when the bucket is full again
+	Reset time.Duration
+}
+
+// Store keeps the buckets; a bucket that does not exist yet is full
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Decision, error)
+}
+
+// refill returns the tokens of a bucket that had tokens elapsed ago.
+func refill(limit Limit, tokens float64, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return tokens
+	}
+	tokens += float64(elapsed) / float64(limit.Period) * float64(limit.Requests)
+	return math.Min(tokens, float64(limit.Requests))
+}
+
+// decide describes a bucket left with tokens after a request that was
+// allowed or not.
+func decide(limit Limit, tokens float64, allowed bool) Decision {
+	perToken := float64(limit.Period) / float64(limit.Requests)
+	d := Decision{
+		Allowed:   allowed,
+		Remaining: int(tokens),
+		Reset:     time.Duration((float64(limit.Requests) - tokens) * perToken),
+	}
+	if !allowed {
+		d.RetryAfter = time.Duration((1 - tokens) * perToken)
+	}
+	return d
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	period  time.Duration
+}
+
+// Memory keeps the buckets in the process, each instance limits on its own
+type Memory struct {
+	now func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{now: time.Now, buckets: make(map[string]*bucket)}
+}
+
+// sweepInterval is how often Memory forgets buckets that are full again
+const sweepInterval = time.Minute
+
+func (m *Memory) Take(ctx context.Context, key string, limit Limit) (Decision, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Requests), updated: now}
+		m.buckets[key] = b
+	}
+	b.tokens = refill(limit, b.tokens, now.Sub(b.updated))
+	b.updated = now
+	b.period = limit.Period
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+
+	// a bucket untouched for a whole period is full, the same as a missing one
+	if now.Sub(m.lastSweep) > sweepInterval {
+		for k, b := range m.buckets {
+			if now.Sub(b.updated) >= b.period {
+				delete(m.buckets, k)
+			}
+		}
+		m.lastSweep = now
+	}
+	return decide(limit, b.tokens, allowed), nil
+}
+
+func (m *Memory) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.buckets)
+}
@@ -0,0 +1,102 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Limit
+	}{
+		{"10/1m", Limit{Requests: 10, Period: time.Minute}},
+		{" 5 / s ", Limit{Requests: 5, Period: time.Second}},
+		{"100/h", Limit{Requests: 100, Period: time.Hour}},
+		{"", Limit{}},
+	}
+	for _, tt := range tests {
+		got, err := ParseLimit(tt.in)
+		require.NoError(t, err, tt.in)
+		assert.Equal(t, tt.want, got, tt.in)
+	}
+	for _, in := range []string{"10", "0/1m", "-1/1m", "ten/1m", "10/", "10/0s", "10/week"} {
+		_, err := ParseLimit(in)
+		assert.Error(t, err, in)
+	}
+}
+
+// testStore checks the behaviour every Store shares; advance moves the
+// store's clock forward
+func testStore(t *testing.T, s Store, advance func(time.Duration)) {
+	ctx := context.Background()
+	limit := Limit{Requests: 3, Period: 3 * time.Second}
+
+	for i := 2; i >= 0; i-- {
+		d, err := s.Take(ctx, "alice", limit)
+		require.NoError(t, err)
+		assert.True(t, d.Allowed)
+		assert.Equal(t, i, d.Remaining)
+	}
+	d, err := s.Take(ctx, "alice", limit)
+	require.NoError(t, err)
+	assert.False(t, d.Allowed, "the bucket is empty")
+	assert.Equal(t, time.Second, d.RetryAfter)
+	assert.Equal(t, 3*time.Second, d.Reset)
+
+	d, err = s.Take(ctx, "bob", limit)
+	require.NoError(t, err)
+	assert.True(t, d.Allowed, "buckets are per key")
+
+	advance(time.Second)
+	d, err = s.Take(ctx, "alice", limit)
+	require.NoError(t, err)
+	assert.True(t, d.Allowed, "a token comes back every period/requests")
+	assert.Equal(t, 0, d.Remaining)
+	d, err = s.Take(ctx, "alice", limit)
+	require.NoError(t, err)
+	assert.False(t, d.Allowed)
+
+	advance(time.Hour)
+	d, err = s.Take(ctx, "alice", limit)
+	require.NoError(t, err)
+	assert.True(t, d.Allowed)
+	assert.Equal(t, 2, d.Remaining, "a bucket never holds more than requests")
+}
+
+func TestMemory(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := NewMemory()
+	m.now = func() time.Time { return now }
+	testStore(t, m, func(d time.Duration) { now = now.Add(d) })
+
+	now = now.Add(time.Hour)
+	m.Take(context.Background(), "carol", Limit{Requests: 1, Period: time.Minute})
+	assert.Equal(t, 1, m.Len(), "full buckets are forgotten")
+}
+
+func TestRedis(t *testing.T) {
+	server := miniredis.RunT(t)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	server.SetTime(now)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	r := NewRedis(client, "subscriptions:ratelimit:")
+	testStore(t, r, func(d time.Duration) {
+		now = now.Add(d)
+		server.SetTime(now)
+		server.FastForward(d)
+	})
+	assert.True(t, server.Exists("subscriptions:ratelimit:alice"), "keys are prefixed")
+
+	server.Close()
+	_, err := r.Take(context.Background(), "alice", Limit{Requests: 1, Period: time.Minute})
+	assert.Error(t, err, "errors of the server are returned")
+}
@@ -0,0 +1,68 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// takeScript refills and takes from the bucket in KEYS[1] atomically, on
+// the Redis clock so that instances with skewed clocks agree. ARGV holds the
+// limit: requests and period in milliseconds. It returns whether the token
+// was taken and the tokens left, as a string to keep the fraction.
+var takeScript = redis.NewScript(`
+local requests = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'updated')
+local tokens = tonumber(state[1])
+local updated = tonumber(state[2])
+if tokens == nil or updated == nil then
+	tokens = requests
+	updated = now
+end
+if now > updated then
+	tokens = math.min(requests, tokens + (now - updated) * requests / period)
+end
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'updated', tostring(now))
+redis.call('PEXPIRE', KEYS[1], period)
+return {allowed, tostring(tokens)}
+`)
+
+// Redis keeps the buckets in Redis, shared by every instance of the service
+type Redis struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedis stores buckets in client under keys starting with prefix
+func NewRedis(client redis.UniversalClient, prefix string) *Redis {
+	return &Redis{client: client, prefix: prefix}
+}
+
+func (r *Redis) Take(ctx context.Context, key string, limit Limit) (Decision, error) {
+	res, err := takeScript.Run(ctx, r.client, []string{r.prefix + key}, limit.Requests, limit.Period.Milliseconds()).Slice()
+	if err != nil {
+		return Decision{}, err
+	}
+	if len(res) != 2 {
+		return Decision{}, fmt.Errorf("rate limit script returned %v", res)
+	}
+	allowed, _ := res[0].(int64)
+	left, _ := res[1].(string)
+	tokens, err := strconv.ParseFloat(left, 64)
+	if err != nil {
+		return Decision{}, fmt.Errorf("rate limit script returned %v", res)
+	}
+	return decide(limit, tokens, allowed == 1), nil
+}
@@ -0,0 +1,48 @@
+package repositories
+
+import (
+    "context"
+    "time"
+
+    "gorm.io/gorm"
+    "subscriptions_service_golang/internal/models"
+)
+
+type APIKeyRepository interface {
+    Create(ctx context.Context, key *models.APIKey) error
+    GetByHash(ctx context.Context, hash string) (*models.APIKey, error)
+    Touch(ctx context.Context, id uint, at time.Time) error
+}
+
+type apiKeyRepository struct {
+    db      *gorm.DB
+    timeout time.Duration
+}
+
+func NewAPIKeyRepository(db *gorm.DB, queryTimeout time.Duration) APIKeyRepository {
+    return &apiKeyRepository{db: db, timeout: queryTimeout}
+}
+
+func (r *apiKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
+    db, cancel := withTimeout(ctx, r.db, r.timeout)
+    defer cancel()
+    return translateError(db.Create(key).Error)
+}
+
+func (r *apiKeyRepository) GetByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+    db, cancel := withTimeout(ctx, r.db, r.timeout)
+    defer cancel()
+    var key models.APIKey
+    if err := db.First(&key, "key_hash = ?", hash).Error; err != nil {
+        return nil, translateError(err)
+    }
+    return &key, nil
+}
+
+// Touch records when the key was last used to authenticate a request
+func (r *apiKeyRepository) Touch(ctx context.Context, id uint, at time.Time) error {
+    db, cancel := withTimeout(ctx, r.db, r.timeout)
+    defer cancel()
+    err := db.Model(&models.APIKey{}).Where("id = ?", id).Update("last_used_at", at).Error
+    return translateError(err)
+}
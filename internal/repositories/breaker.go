@@ -0,0 +1,176 @@
+package repositories
+
+import (
+    "context"
+    "database/sql"
+    "database/sql/driver"
+    "errors"
+    "fmt"
+    "net"
+    "sync"
+    "time"
+
+    "subscriptions_service_golang/pkg/logger"
+
+    "github.com/jackc/pgx/v5/pgconn"
+    "go.uber.org/zap"
+    "gorm.io/gorm"
+)
+
+// CircuitBreaker stops sending queries to a database that keeps failing.
+// After failures consecutive connection errors it opens and every query
+// fails at once with ErrUnavailable instead of waiting for a timeout. Once
+// cooldown has passed one query is let through as a probe: success closes
+// the breaker, failure opens it again, and when the probe is cancelled the
+// next query is the probe.
+//
+// It is a GORM plugin, so it guards every repository built on the same
+// *gorm.DB. Only errors that mean the primary cannot be reached count;
+// constraint violations, missing rows, cancelled requests, statements that
+// ran out of time and reads that went to a replica do not. Replicas are
+// taken out of rotation by their own health checks instead.
+type CircuitBreaker struct {
+    failures int
+    cooldown time.Duration
+    now      func() time.Time
+    primary  gorm.ConnPool
+
+    mu       sync.Mutex
+    failed   int
+    openedAt time.Time
+    open     bool
+    probing  bool
+}
+
+// NewCircuitBreaker returns a breaker to install with db.Use
+func NewCircuitBreaker(failures int, cooldown time.Duration) *CircuitBreaker {
+    return &CircuitBreaker{failures: failures, cooldown: cooldown, now: time.Now}
+}
+
+func (b *CircuitBreaker) Name() string {
+    return "circuit_breaker"
+}
+
+// Initialize registers the breaker first and last in every callback
+// chain, so a rejected statement does not even begin its transaction
+func (b *CircuitBreaker) Initialize(db *gorm.DB) error {
+    b.primary = db.Config.ConnPool
+    cb := db.Callback()
+    return errors.Join(
+        cb.Create().Before("*").Register("circuit_breaker:before_create", b.before),
+        cb.Create().After("*").Register("circuit_breaker:after_create", b.after),
+        cb.Query().Before("*").Register("circuit_breaker:before_query", b.before),
+        cb.Query().After("*").Register("circuit_breaker:after_query", b.after),
+        cb.Update().Before("*").Register("circuit_breaker:before_update", b.before),
+        cb.Update().After("*").Register("circuit_breaker:after_update", b.after),
+        cb.Delete().Before("*").Register("circuit_breaker:before_delete", b.before),
+        cb.Delete().After("*").Register("circuit_breaker:after_delete", b.after),
+        cb.Row().Before("*").Register("circuit_breaker:before_row", b.before),
+        cb.Row().After("*").Register("circuit_breaker:after_row", b.after),
+        cb.Raw().Before("*").Register("circuit_breaker:before_raw", b.before),
+        cb.Raw().After("*").Register("circuit_breaker:after_raw", b.after),
+    )
+}
+
+// breakerPassed marks statements that were let through, only their
+// results are recorded. The value tells whether the statement is the probe.
+const breakerPassed = "circuit_breaker:passed"
+
+func (b *CircuitBreaker) before(db *gorm.DB) {
+    if db.Error != nil {
+        return
+    }
+    passed, probe := b.allow()
+    if !passed {
+        db.AddError(fmt.Errorf("%w: circuit breaker is open", ErrUnavailable))
+        return
+    }
+    db.InstanceSet(breakerPassed, probe)
+}
+
+func (b *CircuitBreaker) after(db *gorm.DB) {
+    if probe, ok := db.InstanceGet(breakerPassed); ok {
+        b.record(db.Error, probe.(bool), b.onReplica(db))
+    }
+}
+
+// onReplica reports whether dbresolver sent the statement to a replica.
+// Transactions always run on the primary.
+func (b *CircuitBreaker) onReplica(db *gorm.DB) bool {
+    pool, ok := db.Statement.ConnPool.(*sql.DB)
+    return ok && gorm.ConnPool(pool) != b.primary
+}
+
+// allow reports whether a query may run and whether it is the probe
+func (b *CircuitBreaker) allow() (passed, probe bool) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    if !b.open {
+        return true, false
+    }
+    if b.probing || b.now().Sub(b.openedAt) < b.cooldown {
+        return false, false
+    }
+    b.probing = true
+    return true, true
+}
+
+// record counts the result of a statement that was let through. Statements
+// started before the breaker opened may finish while the probe runs, only
+// the probe itself ends probing.
+func (b *CircuitBreaker) record(err error, probe, replica bool) {
+    b.mu.Lock()
+    defer b.mu.Unlock()
+    if probe {
+        b.probing = false
+    }
+    switch {
+    case replica:
+        // says nothing about the primary either way, like a cancelled probe
+    case isUnavailable(err):
+        b.failed++
+        if probe || (!b.open && b.failed >= b.failures) {
+            if !b.open {
+                logger.Log.Error("Database circuit breaker opened", zap.Int("failures", b.failed), zap.Error(err))
+            }
+            b.open = true
+            b.openedAt = b.now()
+        }
+    case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+        // the caller went away or the statement was slow, this says nothing
+        // about whether the database is up; such a probe leaves the breaker
+        // half-open for the next query
+    default:
+        if b.open {
+            logger.Log.Info("Database circuit breaker closed")
+        }
+        b.failed = 0
+        b.open = false
+    }
+}
+
+// isUnavailable reports whether err means the database could not be
+// reached. A deadline counts only while connecting: a statement that used
+// up query_timeout or the caller's deadline was slow, the database is up.
+func isUnavailable(err error) bool {
+    if err == nil {
+        return false
+    }
+    var pgErr *pgconn.PgError
+    if errors.As(err, &pgErr) {
+        // connection_exception, insufficient_resources, admin_shutdown,
+        // crash_shutdown, cannot_connect_now
+        return pgErr.Code[:2] == "08" || pgErr.Code[:2] == "53" ||
+            pgErr.Code == "57P01" || pgErr.Code == "57P02" || pgErr.Code == "57P03"
+    }
+    var connectErr *pgconn.ConnectError
+    if errors.As(err, &connectErr) {
+        return true
+    }
+    if errors.Is(err, context.DeadlineExceeded) {
+        // context.DeadlineExceeded is a net.Error as well
+        return false
+    }
+    var netErr net.Error
+    return errors.As(err, &netErr) || errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone)
+}
@@ -0,0 +1,206 @@
+package repositories
+
+import (
+    "context"
+    "database/sql"
+    "database/sql/driver"
+    "errors"
+    "fmt"
+    "net"
+    "os"
+    "testing"
+    "time"
+
+    "subscriptions_service_golang/internal/models"
+    "subscriptions_service_golang/pkg"
+
+    "github.com/glebarez/sqlite"
+    "github.com/jackc/pgx/v5/pgconn"
+    "github.com/stretchr/testify/assert"
+    "github.com/stretchr/testify/require"
+    "gorm.io/gorm"
+    "gorm.io/plugin/dbresolver"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+    db := pkg.InitSQLite("file:circuit_breaker?mode=memory&cache=shared")
+    sqlDB, _ := db.DB()
+    defer sqlDB.Close()
+
+    now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+    breaker := NewCircuitBreaker(3, time.Minute)
+    breaker.now = func() time.Time { return now }
+    require.NoError(t, db.Use(breaker))
+
+    // down makes every query fail the way a lost connection does; like
+    // gorm:query it skips statements that already failed
+    var down, cancelled bool
+    var queries int
+    require.NoError(t, db.Callback().Query().Before("gorm:query").Register("test:down", func(db *gorm.DB) {
+        if db.Error != nil {
+            return
+        }
+        queries++
+        switch {
+        case cancelled:
+            db.AddError(context.Canceled)
+        case down:
+            db.AddError(driver.ErrBadConn)
+        }
+    }))
+
+    ctx := context.Background()
+    repo := NewSubscriptionRepository(db, 0)
+    sub := &models.Subscription{ServiceName: "Netflix", Price: 400, UserID: "60601fee-2bf1-4721-ae6f-7636e79a0cba", StartDate: now}
+    require.NoError(t, repo.Create(ctx, sub))
+
+    for range 5 {
+        _, err := repo.GetByID(ctx, sub.ID+1)
+        assert.ErrorIs(t, err, ErrNotFound, "missing rows do not count as failures")
+    }
+
+    down = true
+    for range 3 {
+        _, err := repo.GetByID(ctx, sub.ID)
+        assert.ErrorIs(t, err, driver.ErrBadConn)
+    }
+    queries = 0
+    _, err := repo.GetByID(ctx, sub.ID)
+    assert.ErrorIs(t, err, ErrUnavailable, "the breaker opens after 3 failures")
+    assert.Zero(t, queries, "an open breaker does not query")
+
+    now = now.Add(time.Minute)
+    _, err = repo.GetByID(ctx, sub.ID)
+    assert.ErrorIs(t, err, driver.ErrBadConn, "a probe is let through after the cooldown")
+    _, err = repo.GetByID(ctx, sub.ID)
+    assert.ErrorIs(t, err, ErrUnavailable, "a failed probe opens the breaker again")
+
+    now = now.Add(time.Minute)
+    cancelled = true
+    _, err = repo.GetByID(ctx, sub.ID)
+    assert.ErrorIs(t, err, context.Canceled)
+    cancelled = false
+    _, err = repo.GetByID(ctx, sub.ID)
+    assert.ErrorIs(t, err, driver.ErrBadConn, "a cancelled probe leaves the breaker half-open")
+    _, err = repo.GetByID(ctx, sub.ID)
+    assert.ErrorIs(t, err, ErrUnavailable, "so the next probe decides")
+
+    down = false
+    now = now.Add(time.Minute)
+    got, err := repo.GetByID(ctx, sub.ID)
+    require.NoError(t, err, "a successful probe closes the breaker")
+    assert.Equal(t, "Netflix", got.ServiceName)
+    _, err = repo.List(ctx, models.SubscriptionFilter{})
+    assert.NoError(t, err)
+}
+
+func TestCircuitBreakerProbe(t *testing.T) {
+    now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+    b := NewCircuitBreaker(1, time.Minute)
+    b.now = func() time.Time { return now }
+
+    passed, _ := b.allow()
+    require.True(t, passed)
+    b.record(driver.ErrBadConn, false, false)
+    passed, _ = b.allow()
+    require.False(t, passed, "open")
+
+    now = now.Add(time.Minute)
+    passed, probe := b.allow()
+    require.True(t, passed)
+    require.True(t, probe)
+
+    // a statement started before the breaker opened finishes first
+    b.record(context.Canceled, false, false)
+    passed, _ = b.allow()
+    assert.False(t, passed, "only the probe ends probing")
+    b.record(driver.ErrBadConn, false, false)
+    passed, _ = b.allow()
+    assert.False(t, passed)
+
+    b.record(nil, true, true)
+    passed, probe = b.allow()
+    assert.True(t, passed, "a probe that went to a replica decides nothing")
+    assert.True(t, probe)
+    b.record(fmt.Errorf("timeout: %w", context.DeadlineExceeded), true, false)
+    passed, probe = b.allow()
+    assert.True(t, passed, "neither does a slow statement")
+    assert.True(t, probe)
+
+    b.record(nil, true, false)
+    passed, probe = b.allow()
+    assert.True(t, passed, "the probe closes the breaker")
+    assert.False(t, probe)
+
+    b.record(driver.ErrBadConn, false, true)
+    passed, _ = b.allow()
+    assert.True(t, passed, "replica errors do not open it")
+}
+
+// TestCircuitBreakerReplica routes reads to a replica that is down; only
+// the primary's errors open the breaker
+func TestCircuitBreakerReplica(t *testing.T) {
+    db := pkg.InitSQLite("file:circuit_breaker_primary?mode=memory&cache=shared")
+    sqlDB, _ := db.DB()
+    defer sqlDB.Close()
+    require.NoError(t, db.Use(dbresolver.Register(dbresolver.Config{
+        Replicas: []gorm.Dialector{sqlite.Open("file:circuit_breaker_replica?mode=memory&cache=shared")},
+    })))
+    breaker := NewCircuitBreaker(2, time.Minute)
+    require.NoError(t, db.Use(breaker))
+
+    var primaryDown bool
+    require.NoError(t, db.Callback().Query().Before("gorm:query").Register("test:down", func(db *gorm.DB) {
+        if db.Error != nil {
+            return
+        }
+        if pool, _ := db.Statement.ConnPool.(*sql.DB); pool != sqlDB || primaryDown {
+            db.AddError(driver.ErrBadConn)
+        }
+    }))
+
+    ctx := context.Background()
+    repo := NewSubscriptionRepository(db, 0)
+    sub := &models.Subscription{ServiceName: "Netflix", Price: 400, UserID: "60601fee-2bf1-4721-ae6f-7636e79a0cba", StartDate: time.Now()}
+    require.NoError(t, repo.Create(ctx, sub))
+    for range 3 {
+        _, err := repo.GetByID(ctx, sub.ID)
+        assert.ErrorIs(t, err, driver.ErrBadConn, "the replica is down")
+    }
+    got, err := repo.GetByID(WithPrimary(ctx), sub.ID)
+    require.NoError(t, err, "the breaker stays closed for the primary")
+    assert.Equal(t, "Netflix", got.ServiceName)
+
+    primaryDown = true
+    for range 2 {
+        _, err = repo.GetByID(WithPrimary(ctx), sub.ID)
+        assert.ErrorIs(t, err, driver.ErrBadConn)
+    }
+    _, err = repo.GetByID(WithPrimary(ctx), sub.ID)
+    assert.ErrorIs(t, err, ErrUnavailable)
+}
+
+func TestIsUnavailable(t *testing.T) {
+    tests := []struct {
+        name string
+        err  error
+        want bool
+    }{
+        {"no error", nil, false},
+        {"bad connection", driver.ErrBadConn, true},
+        {"statement timeout", fmt.Errorf("timeout: %w", context.DeadlineExceeded), false},
+        {"network timeout", &net.OpError{Op: "read", Net: "tcp", Err: os.ErrDeadlineExceeded}, true},
+        {"connect error", &pgconn.ConnectError{}, true},
+        {"too many connections", &pgconn.PgError{Code: "53300"}, true},
+        {"shutting down", &pgconn.PgError{Code: "57P01"}, true},
+        {"unique violation", &pgconn.PgError{Code: "23505"}, false},
+        {"not found", gorm.ErrRecordNotFound, false},
+        {"cancelled", context.Canceled, false},
+        {"other", errors.New("boom"), false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            assert.Equal(t, tt.want, isUnavailable(tt.err))
+        })
+    }
+}
@@ -0,0 +1,53 @@
+package repositories_test
+
+import (
+    "fmt"
+    "os"
+    "testing"
+
+    "subscriptions_service_golang/internal/repositories"
+    "subscriptions_service_golang/internal/repositories/repotest"
+)
+
+// database is Postgres when TEST_DATABASE_DSN or TEST_EMBEDDED_POSTGRES is
+// set and SQLite otherwise, see repotest.StartDatabase
+var database *repotest.Database
+
+func TestMain(m *testing.M) {
+    var err error
+    database, err = repotest.StartDatabase()
+    if err != nil {
+        fmt.Fprintln(os.Stderr, "test database:", err)
+        os.Exit(1)
+    }
+    code := m.Run()
+    if err := database.Close(); err != nil {
+        fmt.Fprintln(os.Stderr, "test database:", err)
+    }
+    os.Exit(code)
+}
+
+func TestMemorySubscriptionRepository(t *testing.T) {
+    repotest.SubscriptionRepository(t, func(t *testing.T) repositories.SubscriptionRepository {
+        return repositories.NewMemorySubscriptionRepository()
+    })
+}
+
+func TestGormSubscriptionRepository(t *testing.T) {
+    t.Logf("running against %s", database.Driver)
+    repotest.SubscriptionRepository(t, func(t *testing.T) repositories.SubscriptionRepository {
+        return repositories.NewSubscriptionRepository(database.Fresh(t), 0)
+    })
+}
+
+func TestMemoryLoginAttemptRepository(t *testing.T) {
+    repotest.LoginAttemptRepository(t, func(t *testing.T) repositories.LoginAttemptRepository {
+        return repositories.NewMemoryLoginAttemptRepository()
+    })
+}
+
+func TestGormLoginAttemptRepository(t *testing.T) {
+    repotest.LoginAttemptRepository(t, func(t *testing.T) repositories.LoginAttemptRepository {
+        return repositories.NewLoginAttemptRepository(database.Fresh(t), 0)
+    })
+}
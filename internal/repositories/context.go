@@ -0,0 +1,43 @@
+package repositories
+
+import (
+    "context"
+    "time"
+
+    "gorm.io/gorm"
+    "gorm.io/plugin/dbresolver"
+)
+
+// primaryKey marks contexts whose reads must not go to a replica
+type primaryKey struct{}
+
+// WithPrimary returns a context whose reads go to the primary database
+// instead of a replica, so the caller sees its own recent writes. It makes
+// no difference without replicas.
+func WithPrimary(ctx context.Context) context.Context {
+    return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// UsesPrimary reports whether ctx was marked with WithPrimary
+func UsesPrimary(ctx context.Context) bool {
+    primary, _ := ctx.Value(primaryKey{}).(bool)
+    return primary
+}
+
+// bind binds db to ctx and keeps it on the primary when ctx asks for it
+func bind(ctx context.Context, db *gorm.DB) *gorm.DB {
+    db = db.WithContext(ctx)
+    if UsesPrimary(ctx) {
+        db = db.Clauses(dbresolver.Write)
+    }
+    return db
+}
+
+// withTimeout binds db to ctx, additionally limited by timeout when it is positive
+func withTimeout(ctx context.Context, db *gorm.DB, timeout time.Duration) (*gorm.DB, context.CancelFunc) {
+    if timeout <= 0 {
+        return bind(ctx, db), func() {}
+    }
+    ctx, cancel := context.WithTimeout(ctx, timeout)
+    return bind(ctx, db), cancel
+}
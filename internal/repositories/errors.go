@@ -0,0 +1,78 @@
+package repositories
+
+import (
+    "errors"
+    "fmt"
+    "io"
+
+    "github.com/jackc/pgx/v5/pgconn"
+    "gorm.io/gorm"
+)
+
+// Errors returned by repositories regardless of the database driver
+var (
+    ErrNotFound   = errors.New("not found")
+    ErrConflict   = errors.New("conflict")
+    ErrValidation = errors.New("validation failed")
+
+    // ErrUnavailable is returned without querying while the database is
+    // considered down, see CircuitBreaker
+    ErrUnavailable = errors.New("database unavailable")
+)
+
+// translateError maps GORM and Postgres errors to the repository errors.
+// Errors it does not recognise are returned unchanged. The driver's message
+// stays out of Error, which ends up in responses, see driverError.
+func translateError(err error) error {
+    if err == nil {
+        return nil
+    }
+    if errors.Is(err, gorm.ErrRecordNotFound) {
+        return &driverError{kind: ErrNotFound, err: err}
+    }
+    // drivers opened with TranslateError, such as SQLite, report these
+    if errors.Is(err, gorm.ErrDuplicatedKey) || errors.Is(err, gorm.ErrForeignKeyViolated) {
+        return &driverError{kind: ErrConflict, err: err}
+    }
+    if errors.Is(err, gorm.ErrCheckConstraintViolated) {
+        return &driverError{kind: ErrValidation, err: err}
+    }
+
+    var pgErr *pgconn.PgError
+    if errors.As(err, &pgErr) {
+        switch {
+        case pgErr.Code == "23505", pgErr.Code == "23503":
+            // unique_violation, foreign_key_violation
+            return &driverError{kind: ErrConflict, err: err}
+        case pgErr.Code == "23502", pgErr.Code == "23514", pgErr.Code[:2] == "22":
+            // not_null_violation, check_violation, data exceptions such as invalid uuid
+            return &driverError{kind: ErrValidation, err: err}
+        }
+    }
+    return err
+}
+
+// driverError is a repository error caused by a database error. Its message
+// is only the repository error's, so table, column and constraint names do
+// not reach clients; both errors match errors.Is. The database error is
+// kept for logs: zap writes the %+v form as errorVerbose.
+type driverError struct {
+    kind error
+    err  error
+}
+
+func (e *driverError) Error() string {
+    return e.kind.Error()
+}
+
+func (e *driverError) Unwrap() []error {
+    return []error{e.kind, e.err}
+}
+
+func (e *driverError) Format(f fmt.State, verb rune) {
+    if verb == 'v' && f.Flag('+') {
+        fmt.Fprintf(f, "%v: %v", e.kind, e.err)
+        return
+    }
+    io.WriteString(f, e.Error())
+}
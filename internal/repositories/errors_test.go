@@ -0,0 +1,41 @@
+package repositories
+
+import (
+    "errors"
+    "fmt"
+    "testing"
+
+    "github.com/jackc/pgx/v5/pgconn"
+    "github.com/stretchr/testify/assert"
+    "gorm.io/gorm"
+)
+
+func TestTranslateError(t *testing.T) {
+    tests := []struct {
+        name string
+        err  error
+        want error
+    }{
+        {"record not found", gorm.ErrRecordNotFound, ErrNotFound},
+        {"unique violation", &pgconn.PgError{Code: "23505"}, ErrConflict},
+        {"foreign key violation", fmt.Errorf("wrapped: %w", &pgconn.PgError{Code: "23503"}), ErrConflict},
+        {"check violation", &pgconn.PgError{Code: "23514"}, ErrValidation},
+        {"invalid uuid", &pgconn.PgError{Code: "22P02"}, ErrValidation},
+        {"translated check violation", gorm.ErrCheckConstraintViolated, ErrValidation},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            assert.ErrorIs(t, translateError(tt.err), tt.want)
+        })
+    }
+
+    // the driver's message is kept for logs but not shown to clients
+    err := translateError(fmt.Errorf("insert: %w", &pgconn.PgError{Code: "23505", Message: `duplicate key value violates unique constraint "users_username_key"`}))
+    assert.Equal(t, "conflict", err.Error())
+    assert.Contains(t, fmt.Sprintf("%+v", err), "users_username_key")
+    assert.Equal(t, "not found", translateError(gorm.ErrRecordNotFound).Error())
+
+    outage := errors.New("connection refused")
+    assert.Equal(t, outage, translateError(outage))
+    assert.Nil(t, translateError(nil))
+}
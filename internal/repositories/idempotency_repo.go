@@ -0,0 +1,68 @@
+package repositories
+
+import (
+    "context"
+    "time"
+
+    "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+    "gorm.io/plugin/dbresolver"
+    "subscriptions_service_golang/internal/models"
+)
+
+type IdempotencyRepository interface {
+    Reserve(ctx context.Context, key *models.IdempotencyKey) (*models.IdempotencyKey, bool, error)
+    Complete(ctx context.Context, key *models.IdempotencyKey) error
+    Release(ctx context.Context, key string) error
+    DeleteExpired(ctx context.Context, before time.Time) (int64, error)
+}
+
+type idempotencyRepository struct {
+    db *gorm.DB
+}
+
+func NewIdempotencyRepository(db *gorm.DB) IdempotencyRepository {
+    return &idempotencyRepository{db: db}
+}
+
+// Reserve inserts key unless a record with the same key already exists.
+// It returns the stored record and whether it was created by this call.
+func (r *idempotencyRepository) Reserve(ctx context.Context, key *models.IdempotencyKey) (*models.IdempotencyKey, bool, error) {
+    res := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(key)
+    if res.Error != nil {
+        return nil, false, translateError(res.Error)
+    }
+    if res.RowsAffected == 1 {
+        return key, true, nil
+    }
+
+    // the conflicting row may not have reached a replica yet
+    var existing models.IdempotencyKey
+    if err := r.db.WithContext(ctx).Clauses(dbresolver.Write).First(&existing, "key = ?", key.Key).Error; err != nil {
+        return nil, false, translateError(err)
+    }
+    return &existing, false, nil
+}
+
+// Complete stores the response for a reserved key
+func (r *idempotencyRepository) Complete(ctx context.Context, key *models.IdempotencyKey) error {
+    err := r.db.WithContext(ctx).Model(&models.IdempotencyKey{}).
+        Where("key = ?", key.Key).
+        Updates(map[string]interface{}{
+            "completed":    true,
+            "status_code":  key.StatusCode,
+            "content_type": key.ContentType,
+            "response":     key.Response,
+        }).Error
+    return translateError(err)
+}
+
+// Release removes a key so the request can be retried
+func (r *idempotencyRepository) Release(ctx context.Context, key string) error {
+    return translateError(r.db.WithContext(ctx).Delete(&models.IdempotencyKey{}, "key = ?", key).Error)
+}
+
+func (r *idempotencyRepository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+    res := r.db.WithContext(ctx).Where("created_at < ?", before).Delete(&models.IdempotencyKey{})
+    return res.RowsAffected, translateError(res.Error)
+}
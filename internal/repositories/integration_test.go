@@ -0,0 +1,137 @@
+package repositories_test
+
+import (
+    "context"
+    "testing"
+    "time"
+
+    "subscriptions_service_golang/internal/models"
+    "subscriptions_service_golang/internal/repositories"
+    "subscriptions_service_golang/internal/services"
+
+    "github.com/stretchr/testify/assert"
+    "github.com/stretchr/testify/require"
+)
+
+const (
+    alice = "11111111-1111-1111-1111-111111111111"
+    bob   = "22222222-2222-2222-2222-222222222222"
+)
+
+func date(s string) *time.Time {
+    t, err := time.Parse("2006-01-02", s)
+    if err != nil {
+        panic(err)
+    }
+    return &t
+}
+
+// TestTotalPriceIntegration runs the billing rules of TotalPrice over rows
+// read back from the database, so the range query in List and the pause
+// lookup are exercised with real SQL
+func TestTotalPriceIntegration(t *testing.T) {
+    ctx := context.Background()
+    repo := repositories.NewSubscriptionRepository(database.Fresh(t), 0)
+    service := services.NewSubscriptionService(repo)
+
+    subs := []models.Subscription{
+        {ServiceName: "Netflix", Price: 500, UserID: alice, StartDate: *date("2025-01-01"), EndDate: date("2025-06-30"), Status: models.StatusActive},
+        // trial until May, paused in July and August
+        {ServiceName: "Spotify", Price: 300, UserID: alice, StartDate: *date("2025-03-15"), TrialEndsAt: date("2025-05-01"), Status: models.StatusActive},
+        {ServiceName: "Netflix", Price: 700, UserID: bob, StartDate: *date("2025-02-01"), Status: models.StatusActive},
+    }
+    for i := range subs {
+        require.NoError(t, repo.Create(ctx, &subs[i]))
+    }
+    pause := &models.SubscriptionPause{SubscriptionID: subs[1].ID, PausedAt: *date("2025-07-01"), ResumedAt: date("2025-09-01")}
+    require.NoError(t, repo.SavePause(ctx, &subs[1], pause))
+
+    tests := []struct {
+        name     string
+        userID   string
+        service  string
+        from, to *time.Time
+        want     int
+    }{
+        {"whole year of one user", alice, "", date("2025-01-01"), date("2025-12-31"), 6*500 + 6*300},
+        {"one service", alice, "Netflix", date("2025-03-01"), date("2025-04-30"), 2 * 500},
+        {"days inside a month count the whole month", "", "", date("2025-06-15"), date("2025-06-15"), 500 + 300 + 700},
+        {"ended subscriptions drop out", alice, "", date("2025-07-01"), date("2025-08-31"), 0},
+        {"before the first start", bob, "", date("2024-01-01"), date("2025-01-31"), 0},
+        {"open start", bob, "", nil, date("2025-03-31"), 2 * 700},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            total, err := service.TotalPrice(ctx, tt.userID, tt.service, tt.from, tt.to)
+            require.NoError(t, err)
+            assert.Equal(t, tt.want, total)
+        })
+    }
+}
+
+// TestDeleteIntegration covers deletes, which are hard: the row and its
+// pauses leave the database, even though the subscription_pauses foreign
+// key points at it
+func TestDeleteIntegration(t *testing.T) {
+    ctx := context.Background()
+    db := database.Fresh(t)
+    repo := repositories.NewSubscriptionRepository(db, 0)
+    service := services.NewSubscriptionService(repo)
+
+    kept := models.Subscription{ServiceName: "Netflix", Price: 500, UserID: alice, StartDate: *date("2025-01-01"), Status: models.StatusActive}
+    deleted := models.Subscription{ServiceName: "Netflix", Price: 300, UserID: alice, StartDate: *date("2025-01-01"), Status: models.StatusActive}
+    require.NoError(t, repo.Create(ctx, &kept))
+    require.NoError(t, repo.Create(ctx, &deleted))
+    _, err := service.Pause(ctx, deleted.ID)
+    require.NoError(t, err)
+    require.NoError(t, service.Delete(ctx, deleted.ID))
+
+    var rows, pauses int64
+    require.NoError(t, db.Table("subscriptions").Where("id = ?", deleted.ID).Count(&rows).Error)
+    require.NoError(t, db.Table("subscription_pauses").Where("subscription_id = ?", deleted.ID).Count(&pauses).Error)
+    assert.Zero(t, rows)
+    assert.Zero(t, pauses)
+
+    _, err = service.GetByID(ctx, deleted.ID)
+    assert.ErrorIs(t, err, services.ErrNotFound)
+    assert.ErrorIs(t, service.Delete(ctx, deleted.ID), services.ErrNotFound)
+
+    list, err := service.List(ctx, models.SubscriptionFilter{UserID: alice})
+    require.NoError(t, err)
+    require.Len(t, list, 1)
+    assert.Equal(t, kept.ID, list[0].ID)
+
+    total, err := service.TotalPrice(ctx, alice, "", date("2025-01-01"), date("2025-01-31"))
+    require.NoError(t, err)
+    assert.Equal(t, 500, total)
+
+    overlapping, err := repo.FindOverlapping(ctx, models.Subscription{UserID: alice, ServiceName: "netflix", StartDate: *date("2025-02-01")})
+    require.NoError(t, err)
+    assert.Len(t, overlapping, 1)
+
+    // ids are never handed out twice
+    again := deleted
+    again.ID = 0
+    require.NoError(t, repo.Create(ctx, &again))
+    assert.Greater(t, again.ID, deleted.ID)
+}
+
+func TestDuplicateDetectionIntegration(t *testing.T) {
+    ctx := context.Background()
+    repo := repositories.NewSubscriptionRepository(database.Fresh(t), 0)
+    service := services.NewSubscriptionService(repo)
+
+    _, err := service.Create(ctx, models.Subscription{ServiceName: "Netflix", Price: 500, UserID: alice, StartDate: *date("2025-01-01"), EndDate: date("2025-03-31")}, false)
+    require.NoError(t, err)
+
+    _, err = service.Create(ctx, models.Subscription{ServiceName: "NETFLIX", Price: 500, UserID: alice, StartDate: *date("2025-03-31")}, false)
+    assert.ErrorIs(t, err, services.ErrDuplicate)
+
+    sub, err := service.Create(ctx, models.Subscription{ServiceName: "NETFLIX", Price: 500, UserID: alice, StartDate: *date("2025-04-01")}, false)
+    require.NoError(t, err)
+    assert.False(t, sub.Duplicate)
+
+    groups, err := service.FindDuplicates(ctx, alice)
+    require.NoError(t, err)
+    assert.Empty(t, groups)
+}
@@ -0,0 +1,131 @@
+package repositories
+
+import (
+    "context"
+    "time"
+
+    "gorm.io/gorm"
+    "subscriptions_service_golang/internal/models"
+)
+
+// LoginAttemptRepository keeps the login audit log and the failure
+// counters behind the lockout of usernames and addresses
+type LoginAttemptRepository interface {
+    Record(ctx context.Context, attempt *models.LoginAttempt) error
+    List(ctx context.Context, filter models.LoginAttemptFilter) ([]models.LoginAttempt, error)
+    UpdateLockout(ctx context.Context, key string, fn func(lockout *models.LoginLockout) error) error
+    ListLocked(ctx context.Context, now time.Time) ([]models.LoginLockout, error)
+    ClearLockout(ctx context.Context, key string) error
+    DeleteAttempts(ctx context.Context, before time.Time) (int64, error)
+    DeleteStaleLockouts(ctx context.Context, failedBefore, now time.Time) (int64, error)
+}
+
+type loginAttemptRepository struct {
+    db      *gorm.DB
+    timeout time.Duration
+}
+
+func NewLoginAttemptRepository(db *gorm.DB, queryTimeout time.Duration) LoginAttemptRepository {
+    return &loginAttemptRepository{db: db, timeout: queryTimeout}
+}
+
+func (r *loginAttemptRepository) Record(ctx context.Context, attempt *models.LoginAttempt) error {
+    db, cancel := withTimeout(ctx, r.db, r.timeout)
+    defer cancel()
+    return translateError(db.Create(attempt).Error)
+}
+
+// List returns the newest attempts first
+func (r *loginAttemptRepository) List(ctx context.Context, filter models.LoginAttemptFilter) ([]models.LoginAttempt, error) {
+    db, cancel := withTimeout(ctx, r.db, r.timeout)
+    defer cancel()
+    query := db.Model(&models.LoginAttempt{})
+    if filter.Username != "" {
+        query = query.Where("lower(username) = lower(?)", filter.Username)
+    }
+    if filter.IP != "" {
+        query = query.Where("ip = ?", filter.IP)
+    }
+    if filter.Limit > 0 {
+        query = query.Limit(filter.Limit)
+    }
+    var attempts []models.LoginAttempt
+    if err := query.Order("id DESC").Find(&attempts).Error; err != nil {
+        return nil, translateError(err)
+    }
+    return attempts, nil
+}
+
+// UpdateLockout calls fn with the lockout of key, a zero one with the key
+// set when there is none, and saves what fn left unless it returns an
+// error. The row stays locked until then, so concurrent logins of the same
+// key see each other's changes instead of the same old counts. Lockouts
+// left without failures or lock are deleted.
+func (r *loginAttemptRepository) UpdateLockout(ctx context.Context, key string, fn func(lockout *models.LoginLockout) error) error {
+    db, cancel := withTimeout(ctx, r.db, r.timeout)
+    defer cancel()
+    return translateError(db.Transaction(func(tx *gorm.DB) error {
+        // the no-op upsert takes the row lock (on SQLite the write lock)
+        // before reading, which SELECT ... FOR UPDATE cannot do for a row
+        // that does not exist yet
+        var lockout models.LoginLockout
+        err := tx.Raw(`INSERT INTO login_lockouts (key, failures, last_failure_at) VALUES (?, 0, ?)
+            ON CONFLICT (key) DO UPDATE SET failures = login_lockouts.failures
+            RETURNING key, failures, last_failure_at, locked_until`, key, time.Unix(0, 0).UTC()).Scan(&lockout).Error
+        if err != nil {
+            return err
+        }
+        if err := fn(&lockout); err != nil {
+            return err
+        }
+        if lockout.Failures == 0 && lockout.LockedUntil == nil {
+            return tx.Where("key = ?", key).Delete(&models.LoginLockout{}).Error
+        }
+        return tx.Save(&lockout).Error
+    }))
+}
+
+// ListLocked returns the keys locked at now, the longest locked first
+func (r *loginAttemptRepository) ListLocked(ctx context.Context, now time.Time) ([]models.LoginLockout, error) {
+    db, cancel := withTimeout(ctx, r.db, r.timeout)
+    defer cancel()
+    var lockouts []models.LoginLockout
+    err := db.Where("locked_until > ?", now).Order("locked_until DESC").Find(&lockouts).Error
+    if err != nil {
+        return nil, translateError(err)
+    }
+    return lockouts, nil
+}
+
+// ClearLockout forgets the failures of key and lifts its lock
+func (r *loginAttemptRepository) ClearLockout(ctx context.Context, key string) error {
+    db, cancel := withTimeout(ctx, r.db, r.timeout)
+    defer cancel()
+    res := db.Where("key = ?", key).Delete(&models.LoginLockout{})
+    if res.Error != nil {
+        return translateError(res.Error)
+    }
+    if res.RowsAffected == 0 {
+        return ErrNotFound
+    }
+    return nil
+}
+
+// DeleteAttempts removes audit log entries older than before
+func (r *loginAttemptRepository) DeleteAttempts(ctx context.Context, before time.Time) (int64, error) {
+    db, cancel := withTimeout(ctx, r.db, r.timeout)
+    defer cancel()
+    res := db.Where("created_at < ?", before).Delete(&models.LoginAttempt{})
+    return res.RowsAffected, translateError(res.Error)
+}
+
+// DeleteStaleLockouts removes the lockouts whose last failure was before
+// failedBefore and that are not locked at now. Their failures no longer
+// count, so a new failure starts from zero either way.
+func (r *loginAttemptRepository) DeleteStaleLockouts(ctx context.Context, failedBefore, now time.Time) (int64, error) {
+    db, cancel := withTimeout(ctx, r.db, r.timeout)
+    defer cancel()
+    res := db.Where("last_failure_at < ? AND (locked_until IS NULL OR locked_until <= ?)", failedBefore, now).
+        Delete(&models.LoginLockout{})
+    return res.RowsAffected, translateError(res.Error)
+}
@@ -0,0 +1,268 @@
+package repositories
+
+import (
+    "context"
+    "fmt"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+
+    "subscriptions_service_golang/internal/models"
+)
+
+// The repositories below complete the memory backend so that the service
+// runs without a database; see NewMemorySubscriptionRepository.
+
+type memoryIdempotencyRepository struct {
+    mu   sync.Mutex
+    keys map[string]models.IdempotencyKey
+}
+
+func NewMemoryIdempotencyRepository() IdempotencyRepository {
+    return &memoryIdempotencyRepository{keys: map[string]models.IdempotencyKey{}}
+}
+
+func (r *memoryIdempotencyRepository) Reserve(ctx context.Context, key *models.IdempotencyKey) (*models.IdempotencyKey, bool, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    if existing, ok := r.keys[key.Key]; ok {
+        return &existing, false, nil
+    }
+    if key.CreatedAt.IsZero() {
+        key.CreatedAt = time.Now()
+    }
+    r.keys[key.Key] = *key
+    return key, true, nil
+}
+
+func (r *memoryIdempotencyRepository) Complete(ctx context.Context, key *models.IdempotencyKey) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    stored, ok := r.keys[key.Key]
+    if !ok {
+        return nil
+    }
+    stored.Completed = true
+    stored.StatusCode = key.StatusCode
+    stored.ContentType = key.ContentType
+    stored.Response = key.Response
+    r.keys[key.Key] = stored
+    return nil
+}
+
+func (r *memoryIdempotencyRepository) Release(ctx context.Context, key string) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    delete(r.keys, key)
+    return nil
+}
+
+func (r *memoryIdempotencyRepository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    var deleted int64
+    for k, key := range r.keys {
+        if key.CreatedAt.Before(before) {
+            delete(r.keys, k)
+            deleted++
+        }
+    }
+    return deleted, nil
+}
+
+type memoryUserRepository struct {
+    mu    sync.Mutex
+    users map[string]models.User
+}
+
+func NewMemoryUserRepository() UserRepository {
+    return &memoryUserRepository{users: map[string]models.User{}}
+}
+
+func (r *memoryUserRepository) Create(ctx context.Context, user *models.User) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    for _, u := range r.users {
+        if u.ID == user.ID || strings.EqualFold(u.Username, user.Username) {
+            return fmt.Errorf("%w: user %s already exists", ErrConflict, user.Username)
+        }
+    }
+    r.users[user.ID] = *user
+    return nil
+}
+
+func (r *memoryUserRepository) GetByID(ctx context.Context, id string) (*models.User, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    user, ok := r.users[id]
+    if !ok {
+        return nil, fmt.Errorf("%w: user %s", ErrNotFound, id)
+    }
+    return &user, nil
+}
+
+func (r *memoryUserRepository) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    for _, user := range r.users {
+        if strings.EqualFold(user.Username, username) {
+            return &user, nil
+        }
+    }
+    return nil, fmt.Errorf("%w: user %s", ErrNotFound, username)
+}
+
+type memoryAPIKeyRepository struct {
+    mu   sync.Mutex
+    keys []models.APIKey
+}
+
+func NewMemoryAPIKeyRepository() APIKeyRepository {
+    return &memoryAPIKeyRepository{}
+}
+
+func (r *memoryAPIKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    for _, k := range r.keys {
+        if k.KeyHash == key.KeyHash {
+            return fmt.Errorf("%w: duplicate key", ErrConflict)
+        }
+    }
+    key.ID = uint(len(r.keys) + 1)
+    r.keys = append(r.keys, *key)
+    return nil
+}
+
+func (r *memoryAPIKeyRepository) GetByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    for _, key := range r.keys {
+        if key.KeyHash == hash {
+            return &key, nil
+        }
+    }
+    return nil, fmt.Errorf("%w: api key", ErrNotFound)
+}
+
+func (r *memoryAPIKeyRepository) Touch(ctx context.Context, id uint, at time.Time) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    if id == 0 || int(id) > len(r.keys) {
+        return nil
+    }
+    r.keys[id-1].LastUsedAt = &at
+    return nil
+}
+
+type memoryLoginAttemptRepository struct {
+    mu       sync.Mutex
+    attempts []models.LoginAttempt
+    lockouts map[string]models.LoginLockout
+}
+
+func NewMemoryLoginAttemptRepository() LoginAttemptRepository {
+    return &memoryLoginAttemptRepository{lockouts: map[string]models.LoginLockout{}}
+}
+
+func (r *memoryLoginAttemptRepository) Record(ctx context.Context, attempt *models.LoginAttempt) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    attempt.ID = 1
+    if n := len(r.attempts); n > 0 {
+        attempt.ID = r.attempts[n-1].ID + 1
+    }
+    if attempt.CreatedAt.IsZero() {
+        attempt.CreatedAt = time.Now()
+    }
+    r.attempts = append(r.attempts, *attempt)
+    return nil
+}
+
+func (r *memoryLoginAttemptRepository) List(ctx context.Context, filter models.LoginAttemptFilter) ([]models.LoginAttempt, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    var out []models.LoginAttempt
+    for i := len(r.attempts) - 1; i >= 0; i-- {
+        a := r.attempts[i]
+        switch {
+        case filter.Username != "" && !strings.EqualFold(a.Username, filter.Username):
+        case filter.IP != "" && a.IP != filter.IP:
+        default:
+            out = append(out, a)
+        }
+        if filter.Limit > 0 && len(out) == filter.Limit {
+            break
+        }
+    }
+    return out, nil
+}
+
+func (r *memoryLoginAttemptRepository) UpdateLockout(ctx context.Context, key string, fn func(lockout *models.LoginLockout) error) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    l, ok := r.lockouts[key]
+    if !ok {
+        l = models.LoginLockout{Key: key, LastFailureAt: time.Unix(0, 0).UTC()}
+    }
+    if err := fn(&l); err != nil {
+        return err
+    }
+    if l.Failures == 0 && l.LockedUntil == nil {
+        delete(r.lockouts, key)
+        return nil
+    }
+    r.lockouts[key] = l
+    return nil
+}
+
+func (r *memoryLoginAttemptRepository) ListLocked(ctx context.Context, now time.Time) ([]models.LoginLockout, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    var out []models.LoginLockout
+    for _, l := range r.lockouts {
+        if l.LockedUntil != nil && l.LockedUntil.After(now) {
+            out = append(out, l)
+        }
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].LockedUntil.After(*out[j].LockedUntil) })
+    return out, nil
+}
+
+func (r *memoryLoginAttemptRepository) ClearLockout(ctx context.Context, key string) error {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    if _, ok := r.lockouts[key]; !ok {
+        return fmt.Errorf("%w: lockout %s", ErrNotFound, key)
+    }
+    delete(r.lockouts, key)
+    return nil
+}
+
+func (r *memoryLoginAttemptRepository) DeleteAttempts(ctx context.Context, before time.Time) (int64, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    kept := r.attempts[:0]
+    for _, a := range r.attempts {
+        if !a.CreatedAt.Before(before) {
+            kept = append(kept, a)
+        }
+    }
+    deleted := int64(len(r.attempts) - len(kept))
+    r.attempts = kept
+    return deleted, nil
+}
+
+func (r *memoryLoginAttemptRepository) DeleteStaleLockouts(ctx context.Context, failedBefore, now time.Time) (int64, error) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    var deleted int64
+    for key, l := range r.lockouts {
+        if l.LastFailureAt.Before(failedBefore) && (l.LockedUntil == nil || !l.LockedUntil.After(now)) {
+            delete(r.lockouts, key)
+            deleted++
+        }
+    }
+    return deleted, nil
+}
@@ -0,0 +1,417 @@
+package repositories
+
+import (
+    "context"
+    "fmt"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+
+    "subscriptions_service_golang/internal/models"
+)
+
+// memoryState is everything a memory repository holds. Transactions work
+// on a copy and replace the original when they commit.
+type memoryState struct {
+    subs        map[uint]models.Subscription
+    pauses      map[uint]models.SubscriptionPause
+    nextSubID   uint
+    nextPauseID uint
+    spend       map[uint][]models.MonthlySpend
+    through     time.Time
+}
+
+func (s *memoryState) clone() *memoryState {
+    c := &memoryState{
+        subs:        make(map[uint]models.Subscription, len(s.subs)),
+        pauses:      make(map[uint]models.SubscriptionPause, len(s.pauses)),
+        nextSubID:   s.nextSubID,
+        nextPauseID: s.nextPauseID,
+        spend:       make(map[uint][]models.MonthlySpend, len(s.spend)),
+        through:     s.through,
+    }
+    for id, sub := range s.subs {
+        c.subs[id] = sub
+    }
+    for id, pause := range s.pauses {
+        c.pauses[id] = pause
+    }
+    for id, rows := range s.spend {
+        c.spend[id] = rows
+    }
+    return c
+}
+
+type memorySubscriptionRepository struct {
+    mu    *sync.Mutex
+    state *memoryState
+    now   func() time.Time
+}
+
+// NewMemorySubscriptionRepository returns a repository that keeps
+// everything in process memory. It behaves like the GORM one and is meant
+// for tests and for running the service without a database; a transaction
+// blocks every other call until it finishes.
+func NewMemorySubscriptionRepository() SubscriptionRepository {
+    return &memorySubscriptionRepository{
+        mu:    new(sync.Mutex),
+        state: &memoryState{
+            subs:   map[uint]models.Subscription{},
+            pauses: map[uint]models.SubscriptionPause{},
+            spend:  map[uint][]models.MonthlySpend{},
+        },
+        now:   time.Now,
+    }
+}
+
+// lock serialises access to the state and fails like a database would
+// when ctx is already done
+func (r *memorySubscriptionRepository) lock(ctx context.Context) (func(), error) {
+    if err := ctx.Err(); err != nil {
+        return nil, err
+    }
+    r.mu.Lock()
+    return r.mu.Unlock, nil
+}
+
+func (r *memorySubscriptionRepository) Create(ctx context.Context, sub *models.Subscription) error {
+    unlock, err := r.lock(ctx)
+    if err != nil {
+        return err
+    }
+    defer unlock()
+    r.insert(sub)
+    return nil
+}
+
+func (r *memorySubscriptionRepository) insert(sub *models.Subscription) {
+    now := r.now()
+    if sub.CreatedAt.IsZero() {
+        sub.CreatedAt = now
+    }
+    sub.UpdatedAt = now
+    if sub.Status == "" {
+        sub.Status = models.StatusActive
+    }
+    if sub.ID == 0 {
+        r.state.nextSubID++
+        sub.ID = r.state.nextSubID
+    }
+    r.state.nextSubID = max(r.state.nextSubID, sub.ID)
+    r.state.subs[sub.ID] = *sub
+}
+
+// CreateBatch inserts all subs or, when one of them is rejected, none
+func (r *memorySubscriptionRepository) CreateBatch(ctx context.Context, subs []models.Subscription) error {
+    unlock, err := r.lock(ctx)
+    if err != nil {
+        return err
+    }
+    defer unlock()
+    for _, sub := range subs {
+        if _, exists := r.state.subs[sub.ID]; sub.ID != 0 && exists {
+            return fmt.Errorf("%w: subscription %d already exists", ErrConflict, sub.ID)
+        }
+    }
+    for i := range subs {
+        r.insert(&subs[i])
+    }
+    return nil
+}
+
+func (r *memorySubscriptionRepository) GetByID(ctx context.Context, id uint) (*models.Subscription, error) {
+    unlock, err := r.lock(ctx)
+    if err != nil {
+        return nil, err
+    }
+    defer unlock()
+    sub, ok := r.state.subs[id]
+    if !ok {
+        return nil, fmt.Errorf("%w: subscription %d", ErrNotFound, id)
+    }
+    return &sub, nil
+}
+
+func (r *memorySubscriptionRepository) List(ctx context.Context, filter models.SubscriptionFilter) ([]models.Subscription, error) {
+    var subs []models.Subscription
+    err := r.Iterate(ctx, filter, func(sub models.Subscription) error {
+        subs = append(subs, sub)
+        return nil
+    })
+    return subs, err
+}
+
+// Iterate calls fn with a snapshot of the matching rows, fn may use the
+// repository again
+func (r *memorySubscriptionRepository) Iterate(ctx context.Context, filter models.SubscriptionFilter, fn func(models.Subscription) error) error {
+    unlock, err := r.lock(ctx)
+    if err != nil {
+        return err
+    }
+    subs := r.matching(func(sub models.Subscription) bool { return matchesFilter(sub, filter) })
+    unlock()
+
+    for _, sub := range subs {
+        if err := ctx.Err(); err != nil {
+            return err
+        }
+        if err := fn(sub); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// matching returns the subscriptions accepted by keep ordered by ID
+func (r *memorySubscriptionRepository) matching(keep func(models.Subscription) bool) []models.Subscription {
+    var subs []models.Subscription
+    for _, sub := range r.state.subs {
+        if keep(sub) {
+            subs = append(subs, sub)
+        }
+    }
+    sort.Slice(subs, func(i, j int) bool { return subs[i].ID < subs[j].ID })
+    return subs
+}
+
+// matchesFilter mirrors the WHERE clause built by filtered
+func matchesFilter(sub models.Subscription, filter models.SubscriptionFilter) bool {
+    switch {
+    case filter.UserID != "" && sub.UserID != filter.UserID:
+        return false
+    case filter.ServiceName != "" && sub.ServiceName != filter.ServiceName:
+        return false
+    case filter.From != nil && sub.EndDate != nil && sub.EndDate.Before(*filter.From):
+        return false
+    case filter.To != nil && sub.StartDate.After(*filter.To):
+        return false
+    }
+    if filter.Active {
+        today := time.Now().UTC().Truncate(24 * time.Hour)
+        live := sub.Status == models.StatusTrial || sub.Status == models.StatusActive
+        if !live || (sub.EndDate != nil && sub.EndDate.Before(today)) {
+            return false
+        }
+    }
+    return true
+}
+
+func (r *memorySubscriptionRepository) FindOverlapping(ctx context.Context, sub models.Subscription) ([]models.Subscription, error) {
+    unlock, err := r.lock(ctx)
+    if err != nil {
+        return nil, err
+    }
+    defer unlock()
+    return r.matching(func(other models.Subscription) bool {
+        return other.ID != sub.ID &&
+            other.UserID == sub.UserID &&
+            strings.EqualFold(other.ServiceName, sub.ServiceName) &&
+            (other.EndDate == nil || !other.EndDate.Before(sub.StartDate)) &&
+            (sub.EndDate == nil || !other.StartDate.After(*sub.EndDate))
+    }), nil
+}
+
+// Update stores sub as a whole, inserting it when the ID is unknown just
+// like GORM's Save
+func (r *memorySubscriptionRepository) Update(ctx context.Context, sub *models.Subscription) error {
+    unlock, err := r.lock(ctx)
+    if err != nil {
+        return err
+    }
+    defer unlock()
+    r.insert(sub)
+    return nil
+}
+
+func (r *memorySubscriptionRepository) Delete(ctx context.Context, id uint) error {
+    unlock, err := r.lock(ctx)
+    if err != nil {
+        return err
+    }
+    defer unlock()
+    if _, ok := r.state.subs[id]; !ok {
+        return ErrNotFound
+    }
+    for pauseID, pause := range r.state.pauses {
+        if pause.SubscriptionID == id {
+            delete(r.state.pauses, pauseID)
+        }
+    }
+    delete(r.state.subs, id)
+    delete(r.state.spend, id)
+    return nil
+}
+
+func (r *memorySubscriptionRepository) SavePause(ctx context.Context, sub *models.Subscription, pause *models.SubscriptionPause) error {
+    unlock, err := r.lock(ctx)
+    if err != nil {
+        return err
+    }
+    defer unlock()
+    if pause.ID == 0 {
+        r.state.nextPauseID++
+        pause.ID = r.state.nextPauseID
+    }
+    r.state.pauses[pause.ID] = *pause
+    r.insert(sub)
+    return nil
+}
+
+func (r *memorySubscriptionRepository) GetOpenPause(ctx context.Context, subscriptionID uint) (*models.SubscriptionPause, error) {
+    unlock, err := r.lock(ctx)
+    if err != nil {
+        return nil, err
+    }
+    defer unlock()
+    var open *models.SubscriptionPause
+    for _, pause := range r.state.pauses {
+        if pause.SubscriptionID == subscriptionID && pause.ResumedAt == nil && (open == nil || pause.PausedAt.After(open.PausedAt)) {
+            pause := pause
+            open = &pause
+        }
+    }
+    if open == nil {
+        return nil, fmt.Errorf("%w: no open pause for subscription %d", ErrNotFound, subscriptionID)
+    }
+    return open, nil
+}
+
+func (r *memorySubscriptionRepository) ListPauses(ctx context.Context, subscriptionIDs []uint) ([]models.SubscriptionPause, error) {
+    unlock, err := r.lock(ctx)
+    if err != nil {
+        return nil, err
+    }
+    defer unlock()
+    wanted := make(map[uint]bool, len(subscriptionIDs))
+    for _, id := range subscriptionIDs {
+        wanted[id] = true
+    }
+    pauses := []models.SubscriptionPause{}
+    for _, pause := range r.state.pauses {
+        if wanted[pause.SubscriptionID] {
+            pauses = append(pauses, pause)
+        }
+    }
+    sort.Slice(pauses, func(i, j int) bool { return pauses[i].PausedAt.Before(pauses[j].PausedAt) })
+    return pauses, nil
+}
+
+// Stats follows the rules of the GORM implementation: trial and active
+// subscriptions not ended count, started active ones add to the spend
+func (r *memorySubscriptionRepository) Stats(ctx context.Context, on time.Time) (models.SubscriptionStats, error) {
+    unlock, err := r.lock(ctx)
+    if err != nil {
+        return models.SubscriptionStats{}, err
+    }
+    defer unlock()
+    var stats models.SubscriptionStats
+    for _, sub := range r.state.subs {
+        live := sub.Status == models.StatusTrial || sub.Status == models.StatusActive
+        if !live || (sub.EndDate != nil && sub.EndDate.Before(on)) {
+            continue
+        }
+        stats.Active++
+        if sub.Status == models.StatusActive && !sub.StartDate.After(on) {
+            stats.MonthlySpend += int64(sub.Price)
+        }
+    }
+    return stats, nil
+}
+
+func (r *memorySubscriptionRepository) Expire(ctx context.Context, statuses []models.SubscriptionStatus, endedBefore time.Time) ([]models.Subscription, error) {
+    unlock, err := r.lock(ctx)
+    if err != nil {
+        return nil, err
+    }
+    defer unlock()
+    expired := r.matching(func(sub models.Subscription) bool {
+        if sub.EndDate == nil || !sub.EndDate.Before(endedBefore) {
+            return false
+        }
+        for _, status := range statuses {
+            if sub.Status == status {
+                return true
+            }
+        }
+        return false
+    })
+    for i := range expired {
+        expired[i].Status = models.StatusExpired
+        expired[i].UpdatedAt = r.now()
+        r.state.subs[expired[i].ID] = expired[i]
+    }
+    return expired, nil
+}
+
+func (r *memorySubscriptionRepository) ReplaceMonthlySpend(ctx context.Context, subscriptionID uint, rows []models.MonthlySpend) error {
+    unlock, err := r.lock(ctx)
+    if err != nil {
+        return err
+    }
+    defer unlock()
+    if len(rows) == 0 {
+        delete(r.state.spend, subscriptionID)
+        return nil
+    }
+    r.state.spend[subscriptionID] = append([]models.MonthlySpend(nil), rows...)
+    return nil
+}
+
+func (r *memorySubscriptionRepository) SumMonthlySpend(ctx context.Context, filter models.SubscriptionFilter) (int64, error) {
+    unlock, err := r.lock(ctx)
+    if err != nil {
+        return 0, err
+    }
+    defer unlock()
+    var total int64
+    for _, rows := range r.state.spend {
+        for _, row := range rows {
+            switch {
+            case filter.UserID != "" && row.UserID != filter.UserID:
+            case filter.ServiceName != "" && row.ServiceName != filter.ServiceName:
+            case filter.From != nil && row.Month.Before(*filter.From):
+            case filter.To != nil && row.Month.After(*filter.To):
+            default:
+                total += int64(row.Amount)
+            }
+        }
+    }
+    return total, nil
+}
+
+func (r *memorySubscriptionRepository) MonthlySpendThrough(ctx context.Context) (time.Time, error) {
+    unlock, err := r.lock(ctx)
+    if err != nil {
+        return time.Time{}, err
+    }
+    defer unlock()
+    return r.state.through, nil
+}
+
+func (r *memorySubscriptionRepository) SetMonthlySpendThrough(ctx context.Context, through time.Time) error {
+    unlock, err := r.lock(ctx)
+    if err != nil {
+        return err
+    }
+    defer unlock()
+    r.state.through = through
+    return nil
+}
+
+// Transaction runs fn against a copy of the data that replaces the
+// original only when fn succeeds. Nested calls copy again, which gives
+// them savepoint semantics.
+func (r *memorySubscriptionRepository) Transaction(ctx context.Context, fn func(repo SubscriptionRepository) error) error {
+    unlock, err := r.lock(ctx)
+    if err != nil {
+        return err
+    }
+    defer unlock()
+    tx := &memorySubscriptionRepository{mu: new(sync.Mutex), state: r.state.clone(), now: r.now}
+    if err := fn(tx); err != nil {
+        return err
+    }
+    r.state = tx.state
+    return nil
+}
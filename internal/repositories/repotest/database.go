@@ -0,0 +1,143 @@
+package repotest
+
+import (
+    "context"
+    "fmt"
+    "net"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+
+    "subscriptions_service_golang/migrations"
+    "subscriptions_service_golang/pkg"
+    "subscriptions_service_golang/pkg/migrate"
+
+    embeddedpostgres "github.com/fergusstrange/embedded-postgres"
+    "gorm.io/gorm"
+)
+
+// Environment variables choosing the database of the integration tests.
+// Without either of them the tests run against SQLite.
+const (
+    // DSNEnv points the tests at an existing, disposable Postgres database,
+    // for example a CI service container. Its tables are truncated.
+    DSNEnv = "TEST_DATABASE_DSN"
+    // EmbeddedEnv set to 1 downloads (once, into the user cache) and starts
+    // a throwaway Postgres from the test binary. It refuses to run as root.
+    EmbeddedEnv = "TEST_EMBEDDED_POSTGRES"
+)
+
+// tables are emptied between tests, children before parents
+var tables = []string{"monthly_spend", "monthly_spend_state", "subscription_pauses", "subscriptions", "idempotency_keys", "login_attempts", "login_lockouts", "api_keys", "users"}
+
+// Database is the database shared by the integration tests of a package.
+// Start it once in TestMain and get an empty schema per test with Fresh.
+type Database struct {
+    Driver string
+
+    db       *gorm.DB
+    embedded *embeddedpostgres.EmbeddedPostgres
+    runtime  string
+}
+
+// StartDatabase connects to the database selected by the environment and
+// brings the Postgres schema up to date with the embedded migrations
+func StartDatabase() (*Database, error) {
+    dsn := os.Getenv(DSNEnv)
+    d := &Database{Driver: "sqlite"}
+
+    if dsn == "" && os.Getenv(EmbeddedEnv) == "1" {
+        port, err := freePort()
+        if err != nil {
+            return nil, err
+        }
+        cache, err := os.UserCacheDir()
+        if err != nil {
+            return nil, err
+        }
+        d.runtime, err = os.MkdirTemp("", "embedded-postgres-")
+        if err != nil {
+            return nil, err
+        }
+        d.embedded = embeddedpostgres.NewDatabase(embeddedpostgres.DefaultConfig().
+            Port(uint32(port)).
+            Database("subscriptions").
+            RuntimePath(d.runtime).
+            CachePath(filepath.Join(cache, "embedded-postgres")).
+            Logger(nil))
+        if err := d.embedded.Start(); err != nil {
+            os.RemoveAll(d.runtime)
+            return nil, fmt.Errorf("embedded postgres: %w", err)
+        }
+        dsn = fmt.Sprintf("host=127.0.0.1 port=%d user=postgres password=postgres dbname=subscriptions sslmode=disable", port)
+    }
+    if dsn == "" {
+        return d, nil
+    }
+
+    d.Driver = "postgres"
+    db, err := pkg.Open(context.Background(), dsn, pkg.Options{})
+    if err != nil {
+        d.Close()
+        return nil, err
+    }
+    d.db = db
+    sqlDB, err := d.db.DB()
+    if err != nil {
+        d.Close()
+        return nil, err
+    }
+    migrator, err := migrate.New(sqlDB, migrations.FS)
+    if err == nil {
+        _, err = migrator.Up(context.Background())
+    }
+    if err != nil {
+        d.Close()
+        return nil, fmt.Errorf("migrations: %w", err)
+    }
+    return d, nil
+}
+
+// Fresh returns a connection to an empty schema. Postgres tables are
+// truncated, so tests using it must not run in parallel; SQLite gets a new
+// in-memory database per test.
+func (d *Database) Fresh(t *testing.T) *gorm.DB {
+    t.Helper()
+    if d.Driver == "sqlite" {
+        name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+        db := pkg.InitSQLite(fmt.Sprintf("file:%s?mode=memory&cache=shared", name))
+        sqlDB, _ := db.DB()
+        t.Cleanup(func() { sqlDB.Close() })
+        return db
+    }
+
+    err := d.db.Exec("TRUNCATE " + strings.Join(tables, ", ") + " RESTART IDENTITY CASCADE").Error
+    if err != nil {
+        t.Fatalf("truncate: %v", err)
+    }
+    return d.db
+}
+
+// Close disconnects and stops the embedded Postgres if one was started
+func (d *Database) Close() error {
+    if d.db != nil {
+        if sqlDB, err := d.db.DB(); err == nil {
+            sqlDB.Close()
+        }
+    }
+    if d.embedded != nil {
+        defer os.RemoveAll(d.runtime)
+        return d.embedded.Stop()
+    }
+    return nil
+}
+
+func freePort() (int, error) {
+    l, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil {
+        return 0, err
+    }
+    defer l.Close()
+    return l.Addr().(*net.TCPAddr).Port, nil
+}
@@ -0,0 +1,164 @@
+package repotest
+
+import (
+    "context"
+    "errors"
+    "sync"
+    "testing"
+    "time"
+
+    "subscriptions_service_golang/internal/models"
+    "subscriptions_service_golang/internal/repositories"
+
+    "github.com/stretchr/testify/assert"
+    "github.com/stretchr/testify/require"
+)
+
+// LoginAttemptRepository runs the conformance suite of the login audit log
+// and lockouts against the repositories returned by newRepo
+func LoginAttemptRepository(t *testing.T, newRepo func(t *testing.T) repositories.LoginAttemptRepository) {
+    t.Run("Audit", func(t *testing.T) {
+        repo := newRepo(t)
+        ctx := context.Background()
+        at := date("2026-01-01")
+        for i, a := range []models.LoginAttempt{
+            {Username: "alice", IP: "10.0.0.1", Result: models.LoginFailed},
+            {Username: "Alice", IP: "10.0.0.2", Result: models.LoginSucceeded},
+            {Username: "bob", IP: "10.0.0.1", Result: models.LoginLocked},
+        } {
+            a.CreatedAt = at.Add(time.Duration(i) * time.Minute)
+            require.NoError(t, repo.Record(ctx, &a))
+            assert.NotZero(t, a.ID)
+        }
+
+        all, err := repo.List(ctx, models.LoginAttemptFilter{})
+        require.NoError(t, err)
+        require.Len(t, all, 3)
+        assert.Equal(t, "bob", all[0].Username, "newest first")
+
+        alice, err := repo.List(ctx, models.LoginAttemptFilter{Username: "ALICE"})
+        require.NoError(t, err)
+        assert.Len(t, alice, 2)
+        fromIP, err := repo.List(ctx, models.LoginAttemptFilter{IP: "10.0.0.1", Limit: 1})
+        require.NoError(t, err)
+        require.Len(t, fromIP, 1)
+        assert.Equal(t, models.LoginLocked, fromIP[0].Result)
+    })
+
+    t.Run("Lockouts", func(t *testing.T) {
+        repo := newRepo(t)
+        ctx := context.Background()
+        at := date("2026-01-01")
+        until := at.Add(15 * time.Minute)
+        fail := func(l *models.LoginLockout) error {
+            l.Failures++
+            l.LastFailureAt = at
+            return nil
+        }
+
+        require.NoError(t, repo.UpdateLockout(ctx, "user:alice", func(l *models.LoginLockout) error {
+            assert.Equal(t, "user:alice", l.Key)
+            assert.Zero(t, l.Failures, "a new key has no failures")
+            assert.Nil(t, l.LockedUntil)
+            return fail(l)
+        }))
+        require.NoError(t, repo.UpdateLockout(ctx, "user:alice", func(l *models.LoginLockout) error {
+            assert.Equal(t, 1, l.Failures)
+            assert.True(t, at.Equal(l.LastFailureAt))
+            l.LockedUntil = &until
+            return fail(l)
+        }))
+        require.NoError(t, repo.UpdateLockout(ctx, "ip:10.0.0.1", fail))
+
+        errStop := errors.New("stop")
+        assert.ErrorIs(t, repo.UpdateLockout(ctx, "user:alice", func(l *models.LoginLockout) error {
+            l.Failures = 100
+            return errStop
+        }), errStop, "the error of fn is returned")
+
+        locked, err := repo.ListLocked(ctx, at)
+        require.NoError(t, err)
+        require.Len(t, locked, 1)
+        assert.Equal(t, "user:alice", locked[0].Key)
+        assert.Equal(t, 2, locked[0].Failures, "nothing is saved when fn fails")
+        assert.True(t, until.Equal(*locked[0].LockedUntil))
+        locked, err = repo.ListLocked(ctx, until)
+        require.NoError(t, err)
+        assert.Empty(t, locked, "expired locks are not listed")
+
+        require.NoError(t, repo.ClearLockout(ctx, "user:alice"))
+        assert.ErrorIs(t, repo.ClearLockout(ctx, "user:alice"), repositories.ErrNotFound)
+
+        // a key left without failures and lock is deleted
+        require.NoError(t, repo.UpdateLockout(ctx, "ip:10.0.0.1", func(l *models.LoginLockout) error {
+            l.Failures = 0
+            return nil
+        }))
+        assert.ErrorIs(t, repo.ClearLockout(ctx, "ip:10.0.0.1"), repositories.ErrNotFound)
+    })
+
+    t.Run("Cleanup", func(t *testing.T) {
+        repo := newRepo(t)
+        ctx := context.Background()
+        at := date("2026-01-01")
+        for i := range 3 {
+            a := models.LoginAttempt{Username: "alice", IP: "10.0.0.1", Result: models.LoginFailed, CreatedAt: at.Add(time.Duration(i) * time.Hour)}
+            require.NoError(t, repo.Record(ctx, &a))
+        }
+        deleted, err := repo.DeleteAttempts(ctx, at.Add(90*time.Minute))
+        require.NoError(t, err)
+        assert.EqualValues(t, 2, deleted)
+        left, err := repo.List(ctx, models.LoginAttemptFilter{})
+        require.NoError(t, err)
+        require.Len(t, left, 1)
+        assert.True(t, at.Add(2*time.Hour).Equal(left[0].CreatedAt))
+        later := models.LoginAttempt{Username: "alice", IP: "10.0.0.1", Result: models.LoginFailed, CreatedAt: at.Add(3 * time.Hour)}
+        require.NoError(t, repo.Record(ctx, &later))
+        assert.Greater(t, later.ID, left[0].ID, "ids are not reused")
+
+        lockout := func(key string, lastFailure time.Time, lockedUntil *time.Time) {
+            require.NoError(t, repo.UpdateLockout(ctx, key, func(l *models.LoginLockout) error {
+                l.Failures, l.LastFailureAt, l.LockedUntil = 1, lastFailure, lockedUntil
+                return nil
+            }))
+        }
+        now := at.Add(2 * time.Hour)
+        lockout("user:stale", at, nil)
+        lockout("user:expired-lock", at, ptr(at.Add(time.Hour)))
+        lockout("user:locked", at, ptr(now.Add(time.Hour)))
+        lockout("user:recent", now.Add(-time.Minute), nil)
+
+        deleted, err = repo.DeleteStaleLockouts(ctx, now.Add(-time.Hour), now)
+        require.NoError(t, err)
+        assert.EqualValues(t, 2, deleted)
+        for _, key := range []string{"user:stale", "user:expired-lock"} {
+            assert.ErrorIs(t, repo.ClearLockout(ctx, key), repositories.ErrNotFound, key)
+        }
+        for _, key := range []string{"user:locked", "user:recent"} {
+            assert.NoError(t, repo.ClearLockout(ctx, key), key)
+        }
+    })
+
+    t.Run("ConcurrentLockoutUpdates", func(t *testing.T) {
+        repo := newRepo(t)
+        ctx := context.Background()
+        var wg sync.WaitGroup
+        for range 10 {
+            wg.Add(1)
+            go func() {
+                defer wg.Done()
+                assert.NoError(t, repo.UpdateLockout(ctx, "ip:10.0.0.1", func(l *models.LoginLockout) error {
+                    l.Failures++
+                    l.LastFailureAt = date("2026-01-01")
+                    l.LockedUntil = ptr(date("2026-01-02"))
+                    return nil
+                }))
+            }()
+        }
+        wg.Wait()
+        locked, err := repo.ListLocked(ctx, date("2026-01-01"))
+        require.NoError(t, err)
+        require.Len(t, locked, 1)
+        assert.Equal(t, 10, locked[0].Failures, "no update is lost")
+    })
+}
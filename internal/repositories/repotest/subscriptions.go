@@ -0,0 +1,480 @@
+// Package repotest is the conformance suite every SubscriptionRepository
+// implementation has to pass, so that the memory, SQLite and Postgres
+// backends stay interchangeable.
+package repotest
+
+import (
+    "context"
+    "errors"
+    "testing"
+    "time"
+
+    "subscriptions_service_golang/internal/models"
+    "subscriptions_service_golang/internal/repositories"
+
+    "github.com/stretchr/testify/assert"
+    "github.com/stretchr/testify/require"
+)
+
+const (
+    alice = "11111111-1111-1111-1111-111111111111"
+    bob   = "22222222-2222-2222-2222-222222222222"
+)
+
+// Factory returns an empty repository; it is called once per subtest
+type Factory func(t *testing.T) repositories.SubscriptionRepository
+
+// SubscriptionRepository runs the conformance suite against the
+// repositories returned by newRepo
+func SubscriptionRepository(t *testing.T, newRepo Factory) {
+    tests := []struct {
+        name string
+        fn   func(t *testing.T, repo repositories.SubscriptionRepository)
+    }{
+        {"CreateAndGet", testCreateAndGet},
+        {"CreateBatch", testCreateBatch},
+        {"ListFilters", testListFilters},
+        {"Iterate", testIterate},
+        {"FindOverlapping", testFindOverlapping},
+        {"UpdateAndDelete", testUpdateAndDelete},
+        {"Pauses", testPauses},
+        {"DeletePaused", testDeletePaused},
+        {"Stats", testStats},
+        {"Expire", testExpire},
+        {"MonthlySpend", testMonthlySpend},
+        {"Transaction", testTransaction},
+        {"CancelledContext", testCancelledContext},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            tt.fn(t, newRepo(t))
+        })
+    }
+}
+
+func date(s string) time.Time {
+    t, err := time.Parse("2006-01-02", s)
+    if err != nil {
+        panic(err)
+    }
+    return t
+}
+
+func ptr(t time.Time) *time.Time { return &t }
+
+func sub(user, service, start string, end *time.Time) models.Subscription {
+    return models.Subscription{
+        ServiceName: service,
+        Price:       100,
+        UserID:      user,
+        StartDate:   date(start),
+        EndDate:     end,
+        Status:      models.StatusActive,
+    }
+}
+
+// create stores subs in order and returns them with their IDs
+func create(t *testing.T, repo repositories.SubscriptionRepository, subs ...models.Subscription) []models.Subscription {
+    t.Helper()
+    for i := range subs {
+        require.NoError(t, repo.Create(context.Background(), &subs[i]))
+    }
+    return subs
+}
+
+func ids(subs []models.Subscription) []uint {
+    out := []uint{}
+    for _, s := range subs {
+        out = append(out, s.ID)
+    }
+    return out
+}
+
+func testCreateAndGet(t *testing.T, repo repositories.SubscriptionRepository) {
+    ctx := context.Background()
+    s := sub(alice, "Netflix", "2025-01-01", ptr(date("2025-06-30")))
+    s.TrialEndsAt = ptr(date("2025-02-01"))
+    require.NoError(t, repo.Create(ctx, &s))
+    assert.NotZero(t, s.ID)
+    assert.False(t, s.CreatedAt.IsZero())
+
+    got, err := repo.GetByID(ctx, s.ID)
+    require.NoError(t, err)
+    assert.Equal(t, "Netflix", got.ServiceName)
+    assert.Equal(t, alice, got.UserID)
+    assert.Equal(t, 100, got.Price)
+    assert.Equal(t, models.StatusActive, got.Status)
+    assert.True(t, got.StartDate.Equal(date("2025-01-01")))
+    require.NotNil(t, got.EndDate)
+    assert.True(t, got.EndDate.Equal(date("2025-06-30")))
+    require.NotNil(t, got.TrialEndsAt)
+    assert.True(t, got.TrialEndsAt.Equal(date("2025-02-01")))
+
+    other := sub(alice, "Spotify", "2025-01-01", nil)
+    require.NoError(t, repo.Create(ctx, &other))
+    assert.Greater(t, other.ID, s.ID)
+
+    _, err = repo.GetByID(ctx, other.ID+100)
+    assert.ErrorIs(t, err, repositories.ErrNotFound)
+}
+
+func testCreateBatch(t *testing.T, repo repositories.SubscriptionRepository) {
+    ctx := context.Background()
+    subs := []models.Subscription{
+        sub(alice, "Netflix", "2025-01-01", nil),
+        sub(bob, "Spotify", "2025-02-01", nil),
+    }
+    require.NoError(t, repo.CreateBatch(ctx, subs))
+    assert.NotZero(t, subs[0].ID)
+    assert.NotZero(t, subs[1].ID)
+
+    all, err := repo.List(ctx, models.SubscriptionFilter{})
+    require.NoError(t, err)
+    assert.Equal(t, ids(subs), ids(all))
+}
+
+func testListFilters(t *testing.T, repo repositories.SubscriptionRepository) {
+    ctx := context.Background()
+    today := time.Now().UTC().Truncate(24 * time.Hour)
+    cancelled := sub(alice, "Spotify", "2025-01-01", nil)
+    cancelled.Status = models.StatusCancelled
+    subs := create(t, repo,
+        sub(alice, "Netflix", "2025-01-01", ptr(date("2025-03-31"))),
+        sub(alice, "Netflix", "2025-06-01", nil),
+        sub(bob, "Netflix", "2025-02-01", ptr(today.AddDate(1, 0, 0))),
+        cancelled,
+    )
+
+    tests := []struct {
+        name   string
+        filter models.SubscriptionFilter
+        want   []uint
+    }{
+        {"all, ordered by id", models.SubscriptionFilter{}, ids(subs)},
+        {"user", models.SubscriptionFilter{UserID: bob}, []uint{subs[2].ID}},
+        {"service name is exact", models.SubscriptionFilter{ServiceName: "Netflix", UserID: alice}, []uint{subs[0].ID, subs[1].ID}},
+        {"service name differs in case", models.SubscriptionFilter{ServiceName: "netflix"}, []uint{}},
+        {"from excludes ended", models.SubscriptionFilter{From: ptr(date("2025-04-01"))}, []uint{subs[1].ID, subs[2].ID, subs[3].ID}},
+        {"from includes end day", models.SubscriptionFilter{From: ptr(date("2025-03-31")), UserID: alice, ServiceName: "Netflix"}, []uint{subs[0].ID, subs[1].ID}},
+        {"to excludes later starts", models.SubscriptionFilter{To: ptr(date("2025-01-31"))}, []uint{subs[0].ID, subs[3].ID}},
+        {"to includes start day", models.SubscriptionFilter{To: ptr(date("2025-02-01")), UserID: bob}, []uint{subs[2].ID}},
+        {"range", models.SubscriptionFilter{From: ptr(date("2025-04-01")), To: ptr(date("2025-05-31"))}, []uint{subs[2].ID, subs[3].ID}},
+        {"active", models.SubscriptionFilter{Active: true}, []uint{subs[1].ID, subs[2].ID}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got, err := repo.List(ctx, tt.filter)
+            require.NoError(t, err)
+            assert.Equal(t, tt.want, ids(got))
+        })
+    }
+}
+
+func testIterate(t *testing.T, repo repositories.SubscriptionRepository) {
+    ctx := context.Background()
+    subs := create(t, repo,
+        sub(alice, "Netflix", "2025-01-01", nil),
+        sub(bob, "Netflix", "2025-01-01", nil),
+        sub(alice, "Spotify", "2025-01-01", nil),
+    )
+
+    var seen []models.Subscription
+    err := repo.Iterate(ctx, models.SubscriptionFilter{UserID: alice}, func(s models.Subscription) error {
+        seen = append(seen, s)
+        return nil
+    })
+    require.NoError(t, err)
+    assert.Equal(t, []uint{subs[0].ID, subs[2].ID}, ids(seen))
+
+    stop := errors.New("stop")
+    calls := 0
+    err = repo.Iterate(ctx, models.SubscriptionFilter{}, func(models.Subscription) error {
+        calls++
+        return stop
+    })
+    assert.ErrorIs(t, err, stop)
+    assert.Equal(t, 1, calls)
+}
+
+func testFindOverlapping(t *testing.T, repo repositories.SubscriptionRepository) {
+    ctx := context.Background()
+    subs := create(t, repo,
+        sub(alice, "Netflix", "2025-01-01", ptr(date("2025-03-31"))),
+        sub(alice, "NETFLIX", "2025-06-01", nil),
+        sub(alice, "Spotify", "2025-01-01", nil),
+        sub(bob, "Netflix", "2025-01-01", nil),
+    )
+
+    tests := []struct {
+        name string
+        sub  models.Subscription
+        want []uint
+    }{
+        {"open ended overlaps both", sub(alice, "netflix", "2025-03-01", nil), []uint{subs[0].ID, subs[1].ID}},
+        {"touching end date", sub(alice, "Netflix", "2025-03-31", ptr(date("2025-04-30"))), []uint{subs[0].ID}},
+        {"gap between periods", sub(alice, "Netflix", "2025-04-01", ptr(date("2025-05-31"))), []uint{}},
+        {"touching start date", sub(alice, "Netflix", "2024-01-01", ptr(date("2025-01-01"))), []uint{subs[0].ID}},
+        {"excludes itself", subs[0], []uint{}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            got, err := repo.FindOverlapping(ctx, tt.sub)
+            require.NoError(t, err)
+            assert.Equal(t, tt.want, ids(got))
+        })
+    }
+}
+
+func testUpdateAndDelete(t *testing.T, repo repositories.SubscriptionRepository) {
+    ctx := context.Background()
+    s := create(t, repo, sub(alice, "Netflix", "2025-01-01", nil))[0]
+
+    s.Price = 250
+    s.EndDate = ptr(date("2025-12-31"))
+    s.Duplicate = true
+    require.NoError(t, repo.Update(ctx, &s))
+    got, err := repo.GetByID(ctx, s.ID)
+    require.NoError(t, err)
+    assert.Equal(t, 250, got.Price)
+    assert.True(t, got.Duplicate)
+    require.NotNil(t, got.EndDate)
+    assert.True(t, got.EndDate.Equal(date("2025-12-31")))
+
+    require.NoError(t, repo.Delete(ctx, s.ID))
+    _, err = repo.GetByID(ctx, s.ID)
+    assert.ErrorIs(t, err, repositories.ErrNotFound)
+    assert.ErrorIs(t, repo.Delete(ctx, s.ID), repositories.ErrNotFound)
+}
+
+// testDeletePaused checks that pauses do not keep a subscription from
+// being deleted and go away with it
+func testDeletePaused(t *testing.T, repo repositories.SubscriptionRepository) {
+    ctx := context.Background()
+    subs := create(t, repo,
+        sub(alice, "Netflix", "2025-01-01", nil),
+        sub(alice, "Spotify", "2025-01-01", nil),
+    )
+    for i := range subs {
+        subs[i].Status = models.StatusPaused
+        require.NoError(t, repo.SavePause(ctx, &subs[i], &models.SubscriptionPause{SubscriptionID: subs[i].ID, PausedAt: date("2025-02-01")}))
+    }
+
+    require.NoError(t, repo.Delete(ctx, subs[0].ID))
+    _, err := repo.GetByID(ctx, subs[0].ID)
+    assert.ErrorIs(t, err, repositories.ErrNotFound)
+    _, err = repo.GetOpenPause(ctx, subs[0].ID)
+    assert.ErrorIs(t, err, repositories.ErrNotFound)
+
+    pauses, err := repo.ListPauses(ctx, []uint{subs[0].ID, subs[1].ID})
+    require.NoError(t, err)
+    require.Len(t, pauses, 1)
+    assert.Equal(t, subs[1].ID, pauses[0].SubscriptionID)
+}
+
+func testPauses(t *testing.T, repo repositories.SubscriptionRepository) {
+    ctx := context.Background()
+    subs := create(t, repo,
+        sub(alice, "Netflix", "2025-01-01", nil),
+        sub(alice, "Spotify", "2025-01-01", nil),
+    )
+
+    _, err := repo.GetOpenPause(ctx, subs[0].ID)
+    assert.ErrorIs(t, err, repositories.ErrNotFound)
+
+    first := &models.SubscriptionPause{SubscriptionID: subs[0].ID, PausedAt: date("2025-02-01"), ResumedAt: ptr(date("2025-03-01"))}
+    subs[0].Status = models.StatusActive
+    require.NoError(t, repo.SavePause(ctx, &subs[0], first))
+    second := &models.SubscriptionPause{SubscriptionID: subs[0].ID, PausedAt: date("2025-05-01")}
+    subs[0].Status = models.StatusPaused
+    require.NoError(t, repo.SavePause(ctx, &subs[0], second))
+    other := &models.SubscriptionPause{SubscriptionID: subs[1].ID, PausedAt: date("2025-04-01")}
+    require.NoError(t, repo.SavePause(ctx, &subs[1], other))
+
+    got, err := repo.GetByID(ctx, subs[0].ID)
+    require.NoError(t, err)
+    assert.Equal(t, models.StatusPaused, got.Status)
+
+    open, err := repo.GetOpenPause(ctx, subs[0].ID)
+    require.NoError(t, err)
+    assert.Equal(t, second.ID, open.ID)
+
+    pauses, err := repo.ListPauses(ctx, []uint{subs[0].ID})
+    require.NoError(t, err)
+    require.Len(t, pauses, 2)
+    assert.Equal(t, first.ID, pauses[0].ID)
+    assert.Equal(t, second.ID, pauses[1].ID)
+
+    pauses, err = repo.ListPauses(ctx, []uint{subs[0].ID, subs[1].ID})
+    require.NoError(t, err)
+    assert.Len(t, pauses, 3)
+
+    pauses, err = repo.ListPauses(ctx, nil)
+    require.NoError(t, err)
+    assert.Empty(t, pauses)
+
+    // resuming closes the pause
+    second.ResumedAt = ptr(date("2025-06-01"))
+    subs[0].Status = models.StatusActive
+    require.NoError(t, repo.SavePause(ctx, &subs[0], second))
+    _, err = repo.GetOpenPause(ctx, subs[0].ID)
+    assert.ErrorIs(t, err, repositories.ErrNotFound)
+}
+
+func testStats(t *testing.T, repo repositories.SubscriptionRepository) {
+    ctx := context.Background()
+    on := date("2025-06-15")
+    trial := sub(alice, "Trial", "2025-06-01", nil)
+    trial.Status = models.StatusTrial
+    paused := sub(alice, "Paused", "2025-01-01", nil)
+    paused.Status = models.StatusPaused
+    future := sub(alice, "Future", "2025-07-01", nil)
+    future.Price = 1000
+    create(t, repo,
+        sub(alice, "Netflix", "2025-01-01", nil),
+        sub(bob, "Spotify", "2025-01-01", ptr(date("2025-06-15"))),
+        sub(bob, "Ended", "2025-01-01", ptr(date("2025-06-14"))),
+        trial,
+        paused,
+        future,
+    )
+
+    stats, err := repo.Stats(ctx, on)
+    require.NoError(t, err)
+    assert.Equal(t, int64(4), stats.Active)
+    assert.Equal(t, int64(200), stats.MonthlySpend)
+}
+
+func testExpire(t *testing.T, repo repositories.SubscriptionRepository) {
+    ctx := context.Background()
+    cancelled := sub(alice, "Cancelled", "2025-01-01", ptr(date("2025-02-01")))
+    cancelled.Status = models.StatusCancelled
+    subs := create(t, repo,
+        sub(alice, "Netflix", "2025-01-01", ptr(date("2025-02-01"))),
+        sub(alice, "Spotify", "2025-01-01", ptr(date("2025-03-01"))),
+        sub(alice, "Open", "2025-01-01", nil),
+        cancelled,
+    )
+
+    expired, err := repo.Expire(ctx, []models.SubscriptionStatus{models.StatusActive, models.StatusTrial}, date("2025-03-01"))
+    require.NoError(t, err)
+    require.Len(t, expired, 1)
+    assert.Equal(t, subs[0].ID, expired[0].ID)
+    assert.Equal(t, models.StatusExpired, expired[0].Status)
+    assert.Equal(t, alice, expired[0].UserID)
+
+    for _, s := range subs {
+        got, err := repo.GetByID(ctx, s.ID)
+        require.NoError(t, err)
+        if s.ID == subs[0].ID {
+            assert.Equal(t, models.StatusExpired, got.Status)
+        } else {
+            assert.Equal(t, s.Status, got.Status)
+        }
+    }
+}
+
+func testMonthlySpend(t *testing.T, repo repositories.SubscriptionRepository) {
+    ctx := context.Background()
+    subs := create(t, repo,
+        sub(alice, "Netflix", "2025-01-01", nil),
+        sub(bob, "Spotify", "2025-01-01", nil),
+    )
+    rows := func(s models.Subscription, months ...string) []models.MonthlySpend {
+        var out []models.MonthlySpend
+        for _, m := range months {
+            out = append(out, models.MonthlySpend{SubscriptionID: s.ID, Month: date(m), UserID: s.UserID, ServiceName: s.ServiceName, Amount: s.Price})
+        }
+        return out
+    }
+    require.NoError(t, repo.ReplaceMonthlySpend(ctx, subs[0].ID, rows(subs[0], "2025-01-01", "2025-02-01", "2025-03-01")))
+    require.NoError(t, repo.ReplaceMonthlySpend(ctx, subs[1].ID, rows(subs[1], "2025-02-01")))
+
+    tests := []struct {
+        name   string
+        filter models.SubscriptionFilter
+        want   int64
+    }{
+        {"everything", models.SubscriptionFilter{}, 400},
+        {"one user", models.SubscriptionFilter{UserID: alice}, 300},
+        {"one service", models.SubscriptionFilter{ServiceName: "Spotify"}, 100},
+        {"months in range", models.SubscriptionFilter{From: ptr(date("2025-02-01")), To: ptr(date("2025-02-28"))}, 200},
+        {"nothing in range", models.SubscriptionFilter{From: ptr(date("2025-04-01"))}, 0},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            total, err := repo.SumMonthlySpend(ctx, tt.filter)
+            require.NoError(t, err)
+            assert.Equal(t, tt.want, total)
+        })
+    }
+
+    // replacing drops the months that are gone
+    require.NoError(t, repo.ReplaceMonthlySpend(ctx, subs[0].ID, rows(subs[0], "2025-03-01")))
+    total, err := repo.SumMonthlySpend(ctx, models.SubscriptionFilter{UserID: alice})
+    require.NoError(t, err)
+    assert.Equal(t, int64(100), total)
+    require.NoError(t, repo.ReplaceMonthlySpend(ctx, subs[0].ID, nil))
+    total, err = repo.SumMonthlySpend(ctx, models.SubscriptionFilter{UserID: alice})
+    require.NoError(t, err)
+    assert.Zero(t, total)
+
+    through, err := repo.MonthlySpendThrough(ctx)
+    require.NoError(t, err)
+    assert.True(t, through.IsZero())
+    for _, m := range []string{"2025-12-01", "2026-01-01"} {
+        require.NoError(t, repo.SetMonthlySpendThrough(ctx, date(m)))
+        through, err = repo.MonthlySpendThrough(ctx)
+        require.NoError(t, err)
+        assert.True(t, date(m).Equal(through), "through %s", through)
+    }
+}
+
+func testTransaction(t *testing.T, repo repositories.SubscriptionRepository) {
+    ctx := context.Background()
+    failure := errors.New("failure")
+
+    err := repo.Transaction(ctx, func(tx repositories.SubscriptionRepository) error {
+        s := sub(alice, "Rolled back", "2025-01-01", nil)
+        if err := tx.Create(ctx, &s); err != nil {
+            return err
+        }
+        return failure
+    })
+    assert.ErrorIs(t, err, failure)
+    all, err := repo.List(ctx, models.SubscriptionFilter{})
+    require.NoError(t, err)
+    assert.Empty(t, all)
+
+    err = repo.Transaction(ctx, func(tx repositories.SubscriptionRepository) error {
+        kept := sub(alice, "Kept", "2025-01-01", nil)
+        if err := tx.Create(ctx, &kept); err != nil {
+            return err
+        }
+        // a failing nested transaction only undoes its own changes
+        nested := tx.Transaction(ctx, func(inner repositories.SubscriptionRepository) error {
+            s := sub(alice, "Savepoint", "2025-01-01", nil)
+            if err := inner.Create(ctx, &s); err != nil {
+                return err
+            }
+            return failure
+        })
+        assert.ErrorIs(t, nested, failure)
+        return nil
+    })
+    require.NoError(t, err)
+    all, err = repo.List(ctx, models.SubscriptionFilter{})
+    require.NoError(t, err)
+    require.Len(t, all, 1)
+    assert.Equal(t, "Kept", all[0].ServiceName)
+}
+
+func testCancelledContext(t *testing.T, repo repositories.SubscriptionRepository) {
+    s := create(t, repo, sub(alice, "Netflix", "2025-01-01", nil))[0]
+    ctx, cancel := context.WithCancel(context.Background())
+    cancel()
+
+    _, err := repo.GetByID(ctx, s.ID)
+    assert.ErrorIs(t, err, context.Canceled)
+    _, err = repo.List(ctx, models.SubscriptionFilter{})
+    assert.ErrorIs(t, err, context.Canceled)
+}
@@ -1,82 +1,324 @@
 package repositories
 
 import (
-	"gorm.io/gorm"
-	"subscriptions_service_golang/internal/models"
-)
+    "context"
+    "errors"
+    "fmt"
+    "time"
 
+    "gorm.io/gorm"
+    "gorm.io/gorm/clause"
+    "subscriptions_service_golang/internal/models"
+)
 
 type SubscriptionRepository interface {
-    Create(sub *models.Subscription) error
-    GetByID(id uint) (*models.Subscription, error)
-    List(filter map[string]interface{}) ([]models.Subscription, error)
-    Update(sub *models.Subscription) error
-    Delete(id uint) error
-    TotalPrice(userID string, serviceName string, from, to string) (int, error)
+    Create(ctx context.Context, sub *models.Subscription) error
+    CreateBatch(ctx context.Context, subs []models.Subscription) error
+    GetByID(ctx context.Context, id uint) (*models.Subscription, error)
+    List(ctx context.Context, filter models.SubscriptionFilter) ([]models.Subscription, error)
+    Iterate(ctx context.Context, filter models.SubscriptionFilter, fn func(models.Subscription) error) error
+    FindOverlapping(ctx context.Context, sub models.Subscription) ([]models.Subscription, error)
+    Update(ctx context.Context, sub *models.Subscription) error
+    Delete(ctx context.Context, id uint) error
+    SavePause(ctx context.Context, sub *models.Subscription, pause *models.SubscriptionPause) error
+    GetOpenPause(ctx context.Context, subscriptionID uint) (*models.SubscriptionPause, error)
+    ListPauses(ctx context.Context, subscriptionIDs []uint) ([]models.SubscriptionPause, error)
+    Stats(ctx context.Context, on time.Time) (models.SubscriptionStats, error)
+    Expire(ctx context.Context, statuses []models.SubscriptionStatus, endedBefore time.Time) ([]models.Subscription, error)
+    ReplaceMonthlySpend(ctx context.Context, subscriptionID uint, rows []models.MonthlySpend) error
+    SumMonthlySpend(ctx context.Context, filter models.SubscriptionFilter) (int64, error)
+    MonthlySpendThrough(ctx context.Context) (time.Time, error)
+    SetMonthlySpendThrough(ctx context.Context, through time.Time) error
+    Transaction(ctx context.Context, fn func(repo SubscriptionRepository) error) error
 }
 
 type subscriptionRepository struct {
-    db *gorm.DB
+    db      *gorm.DB
+    timeout time.Duration
+}
+
+// NewSubscriptionRepository returns a GORM backed repository. Every query
+// is bounded by queryTimeout on top of the caller's context; zero disables it.
+func NewSubscriptionRepository(db *gorm.DB, queryTimeout time.Duration) SubscriptionRepository {
+    return &subscriptionRepository{db: db, timeout: queryTimeout}
+}
+
+// conn returns a session bound to ctx and limited by the query timeout
+func (r *subscriptionRepository) conn(ctx context.Context) (*gorm.DB, context.CancelFunc) {
+    return withTimeout(ctx, r.db, r.timeout)
 }
 
-func NewSubscriptionRepository(db *gorm.DB) SubscriptionRepository {
-    return &subscriptionRepository{db: db}
+func (r *subscriptionRepository) Create(ctx context.Context, sub *models.Subscription) error {
+    db, cancel := r.conn(ctx)
+    defer cancel()
+    return translateError(db.Create(sub).Error)
 }
 
-func (r *subscriptionRepository) Create(sub *models.Subscription) error {
-    return r.db.Create(sub).Error
+// CreateBatch inserts all subs in one transaction; IDs are filled in place.
+func (r *subscriptionRepository) CreateBatch(ctx context.Context, subs []models.Subscription) error {
+    db, cancel := r.conn(ctx)
+    defer cancel()
+    return translateError(db.Transaction(func(tx *gorm.DB) error {
+        return tx.Create(&subs).Error
+    }))
 }
 
-func (r *subscriptionRepository) GetByID(id uint) (*models.Subscription, error) {
+func (r *subscriptionRepository) GetByID(ctx context.Context, id uint) (*models.Subscription, error) {
+    db, cancel := r.conn(ctx)
+    defer cancel()
     var sub models.Subscription
-    if err := r.db.First(&sub, id).Error; err != nil {
-        return nil, err
+    err := db.First(&sub, id).Error
+    if errors.Is(err, gorm.ErrRecordNotFound) {
+        return nil, fmt.Errorf("%w: subscription %d", ErrNotFound, id)
+    }
+    if err != nil {
+        return nil, translateError(err)
     }
     return &sub, nil
 }
 
-func (r *subscriptionRepository) List(filter map[string]interface{}) ([]models.Subscription, error) {
+func (r *subscriptionRepository) List(ctx context.Context, filter models.SubscriptionFilter) ([]models.Subscription, error) {
+    db, cancel := r.conn(ctx)
+    defer cancel()
+    var subs []models.Subscription
+    if err := filtered(db, filter).Find(&subs).Error; err != nil {
+        return nil, translateError(err)
+    }
+    return subs, nil
+}
+
+// Iterate streams subscriptions matching filter to fn one row at a time
+// instead of loading the whole result set into memory. The query timeout
+// does not apply here, the stream lives as long as ctx.
+func (r *subscriptionRepository) Iterate(ctx context.Context, filter models.SubscriptionFilter, fn func(models.Subscription) error) error {
+    db := bind(ctx, r.db)
+    rows, err := filtered(db, filter).Rows()
+    if err != nil {
+        return translateError(err)
+    }
+    defer rows.Close()
+
+    for rows.Next() {
+        var sub models.Subscription
+        if err := db.ScanRows(rows, &sub); err != nil {
+            return translateError(err)
+        }
+        if err := fn(sub); err != nil {
+            return err
+        }
+    }
+    return translateError(rows.Err())
+}
+
+// FindOverlapping returns other subscriptions of the same user and service
+// (compared case-insensitively) whose period intersects sub's period.
+func (r *subscriptionRepository) FindOverlapping(ctx context.Context, sub models.Subscription) ([]models.Subscription, error) {
+    db, cancel := r.conn(ctx)
+    defer cancel()
     var subs []models.Subscription
-    query := r.db.Model(&models.Subscription{})
-    for k, v := range filter {
-        query = query.Where(k+" = ?", v)
+    query := db.Where("user_id = ? AND LOWER(service_name) = LOWER(?) AND id <> ?", sub.UserID, sub.ServiceName, sub.ID).
+        Where("end_date IS NULL OR end_date >= ?", sub.StartDate)
+    if sub.EndDate != nil {
+        query = query.Where("start_date <= ?", *sub.EndDate)
     }
-    if err := query.Find(&subs).Error; err != nil {
-        return nil, err
+    if err := query.Order("id").Find(&subs).Error; err != nil {
+        return nil, translateError(err)
     }
     return subs, nil
 }
 
+func filtered(db *gorm.DB, filter models.SubscriptionFilter) *gorm.DB {
+    query := db.Model(&models.Subscription{})
+
+    if filter.UserID != "" {
+        query = query.Where("user_id = ?", filter.UserID)
+    }
+    if filter.ServiceName != "" {
+        query = query.Where("service_name = ?", filter.ServiceName)
+    }
+    if filter.From != nil {
+        query = query.Where("end_date IS NULL OR end_date >= ?", *filter.From)
+    }
+    if filter.To != nil {
+        query = query.Where("start_date <= ?", *filter.To)
+    }
+    if filter.Active {
+        query = query.Where("status IN ?", []models.SubscriptionStatus{models.StatusTrial, models.StatusActive}).
+            Where("end_date IS NULL OR end_date >= ?", time.Now().UTC().Truncate(24*time.Hour))
+    }
+
+    return query.Order("id")
+}
+
+
+func (r *subscriptionRepository) Update(ctx context.Context, sub *models.Subscription) error {
+    db, cancel := r.conn(ctx)
+    defer cancel()
+    return translateError(db.Save(sub).Error)
+}
+
+
+// Delete removes the subscription together with its pauses. Postgres
+// cascades them as well; the SQLite schema has no foreign keys.
+func (r *subscriptionRepository) Delete(ctx context.Context, id uint) error {
+    db, cancel := r.conn(ctx)
+    defer cancel()
+    return translateError(db.Transaction(func(tx *gorm.DB) error {
+        if err := tx.Where("subscription_id = ?", id).Delete(&models.SubscriptionPause{}).Error; err != nil {
+            return err
+        }
+        res := tx.Delete(&models.Subscription{}, id)
+        if res.Error != nil {
+            return res.Error
+        }
+        if res.RowsAffected == 0 {
+            return ErrNotFound
+        }
+        return nil
+    }))
+}
+
+// SavePause stores the subscription status together with the pause record
+// in a single transaction, so the two never disagree.
+func (r *subscriptionRepository) SavePause(ctx context.Context, sub *models.Subscription, pause *models.SubscriptionPause) error {
+    db, cancel := r.conn(ctx)
+    defer cancel()
+    return translateError(db.Transaction(func(tx *gorm.DB) error {
+        if err := tx.Save(pause).Error; err != nil {
+            return err
+        }
+        return tx.Save(sub).Error
+    }))
+}
 
-func (r *subscriptionRepository) Update(sub *models.Subscription) error {
-    return r.db.Save(sub).Error
+func (r *subscriptionRepository) GetOpenPause(ctx context.Context, subscriptionID uint) (*models.SubscriptionPause, error) {
+    db, cancel := r.conn(ctx)
+    defer cancel()
+    var pause models.SubscriptionPause
+    err := db.Where("subscription_id = ? AND resumed_at IS NULL", subscriptionID).
+        Order("paused_at DESC").
+        First(&pause).Error
+    if err != nil {
+        return nil, translateError(err)
+    }
+    return &pause, nil
 }
 
+func (r *subscriptionRepository) ListPauses(ctx context.Context, subscriptionIDs []uint) ([]models.SubscriptionPause, error) {
+    var pauses []models.SubscriptionPause
+    if len(subscriptionIDs) == 0 {
+        return pauses, nil
+    }
+    db, cancel := r.conn(ctx)
+    defer cancel()
+    err := db.Where("subscription_id IN ?", subscriptionIDs).
+        Order("paused_at").
+        Find(&pauses).Error
+    if err != nil {
+        return nil, translateError(err)
+    }
+    return pauses, nil
+}
 
-func (r *subscriptionRepository) Delete(id uint) error {
-    return r.db.Delete(&models.Subscription{}, id).Error
+// Stats counts subscriptions that are live on the given day. Only active
+// (not trial, not paused) subscriptions that have started add to the spend.
+func (r *subscriptionRepository) Stats(ctx context.Context, on time.Time) (models.SubscriptionStats, error) {
+    db, cancel := r.conn(ctx)
+    defer cancel()
+    var stats models.SubscriptionStats
+    err := db.Model(&models.Subscription{}).
+        Select("COUNT(*) AS active, COALESCE(SUM(CASE WHEN status = ? AND start_date <= ? THEN price ELSE 0 END), 0) AS monthly_spend", models.StatusActive, on).
+        Where("status IN ?", []models.SubscriptionStatus{models.StatusTrial, models.StatusActive}).
+        Where("end_date IS NULL OR end_date >= ?", on).
+        Scan(&stats).Error
+    return stats, translateError(err)
 }
 
+// Expire moves subscriptions in one of statuses whose end_date is before
+// endedBefore to the expired status and returns the updated rows.
+func (r *subscriptionRepository) Expire(ctx context.Context, statuses []models.SubscriptionStatus, endedBefore time.Time) ([]models.Subscription, error) {
+    db, cancel := r.conn(ctx)
+    defer cancel()
+    var subs []models.Subscription
+    err := db.Model(&subs).
+        Clauses(clause.Returning{}).
+        Where("status IN ? AND end_date < ?", statuses, endedBefore).
+        Update("status", models.StatusExpired).Error
+    if err != nil {
+        return nil, translateError(err)
+    }
+    return subs, nil
+}
 
-func (r *subscriptionRepository) TotalPrice(userID string, serviceName string, from, to string) (int, error) {
-    var total int
-    query := r.db.Model(&models.Subscription{}).Select("SUM(price)")
+// ReplaceMonthlySpend swaps the monthly_spend rows of a subscription for
+// rows in one transaction; no rows removes them
+func (r *subscriptionRepository) ReplaceMonthlySpend(ctx context.Context, subscriptionID uint, rows []models.MonthlySpend) error {
+    db, cancel := r.conn(ctx)
+    defer cancel()
+    return translateError(db.Transaction(func(tx *gorm.DB) error {
+        if err := tx.Where("subscription_id = ?", subscriptionID).Delete(&models.MonthlySpend{}).Error; err != nil {
+            return err
+        }
+        if len(rows) == 0 {
+            return nil
+        }
+        return tx.CreateInBatches(rows, 500).Error
+    }))
+}
 
-    if userID != "" {
-        query = query.Where("user_id = ?", userID)
+// SumMonthlySpend adds up monthly_spend for the user and service of filter
+// over the months from filter.From to filter.To, both inclusive and
+// optional. Other fields of filter are ignored.
+func (r *subscriptionRepository) SumMonthlySpend(ctx context.Context, filter models.SubscriptionFilter) (int64, error) {
+    db, cancel := r.conn(ctx)
+    defer cancel()
+    query := db.Model(&models.MonthlySpend{})
+    if filter.UserID != "" {
+        query = query.Where("user_id = ?", filter.UserID)
     }
-    if serviceName != "" {
-        query = query.Where("service_name = ?", serviceName)
+    if filter.ServiceName != "" {
+        query = query.Where("service_name = ?", filter.ServiceName)
     }
-    if from != "" {
-        query = query.Where("start_date >= ?", from)
+    if filter.From != nil {
+        query = query.Where("month >= ?", *filter.From)
     }
-    if to != "" {
-        query = query.Where("start_date <= ?", to)
+    if filter.To != nil {
+        query = query.Where("month <= ?", *filter.To)
     }
+    var total int64
+    err := query.Select("COALESCE(SUM(amount), 0)").Scan(&total).Error
+    return total, translateError(err)
+}
 
-    if err := query.Scan(&total).Error; err != nil {
-        return 0, err
+// MonthlySpendThrough returns the month monthly_spend is complete for, or
+// the zero time when it has never been built
+func (r *subscriptionRepository) MonthlySpendThrough(ctx context.Context) (time.Time, error) {
+    db, cancel := r.conn(ctx)
+    defer cancel()
+    var states []models.MonthlySpendState
+    if err := db.Where("id = 1").Limit(1).Find(&states).Error; err != nil {
+        return time.Time{}, translateError(err)
+    }
+    if len(states) == 0 {
+        return time.Time{}, nil
     }
-    return total, nil
+    return states[0].Through.UTC(), nil
+}
+
+func (r *subscriptionRepository) SetMonthlySpendThrough(ctx context.Context, through time.Time) error {
+    db, cancel := r.conn(ctx)
+    defer cancel()
+    err := db.Clauses(clause.OnConflict{UpdateAll: true}).
+        Create(&models.MonthlySpendState{ID: 1, Through: through}).Error
+    return translateError(err)
+}
+
+// Transaction runs fn with a repository bound to a single transaction.
+// Calling Transaction again on that repository opens a savepoint, so a
+// failing nested call only rolls back its own changes. The query timeout
+// applies to each statement inside fn, not to the transaction as a whole.
+func (r *subscriptionRepository) Transaction(ctx context.Context, fn func(repo SubscriptionRepository) error) error {
+    return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+        return fn(&subscriptionRepository{db: tx, timeout: r.timeout})
+    })
 }
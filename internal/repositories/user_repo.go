@@ -0,0 +1,51 @@
+package repositories
+
+import (
+    "context"
+    "time"
+
+    "gorm.io/gorm"
+    "subscriptions_service_golang/internal/models"
+)
+
+type UserRepository interface {
+    Create(ctx context.Context, user *models.User) error
+    GetByID(ctx context.Context, id string) (*models.User, error)
+    GetByUsername(ctx context.Context, username string) (*models.User, error)
+}
+
+type userRepository struct {
+    db      *gorm.DB
+    timeout time.Duration
+}
+
+func NewUserRepository(db *gorm.DB, queryTimeout time.Duration) UserRepository {
+    return &userRepository{db: db, timeout: queryTimeout}
+}
+
+func (r *userRepository) Create(ctx context.Context, user *models.User) error {
+    db, cancel := withTimeout(ctx, r.db, r.timeout)
+    defer cancel()
+    return translateError(db.Create(user).Error)
+}
+
+func (r *userRepository) GetByID(ctx context.Context, id string) (*models.User, error) {
+    db, cancel := withTimeout(ctx, r.db, r.timeout)
+    defer cancel()
+    var user models.User
+    if err := db.First(&user, "id = ?", id).Error; err != nil {
+        return nil, translateError(err)
+    }
+    return &user, nil
+}
+
+// GetByUsername looks the user up ignoring case, matching the unique index
+func (r *userRepository) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+    db, cancel := withTimeout(ctx, r.db, r.timeout)
+    defer cancel()
+    var user models.User
+    if err := db.First(&user, "lower(username) = lower(?)", username).Error; err != nil {
+        return nil, translateError(err)
+    }
+    return &user, nil
+}
@@ -0,0 +1,259 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"subscriptions_service_golang/internal/models"
+	"subscriptions_service_golang/internal/repositories"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// APIKeyPrefix starts every issued token so that leaked keys are easy to
+// recognise in logs and by secret scanners
+const APIKeyPrefix = "sk_"
+
+// minPasswordLength is the shortest password CreateUser accepts
+const minPasswordLength = 8
+
+// maxUsernameLength is the longest username CreateUser accepts, in bytes;
+// users.username and login_attempts.username hold that many characters
+const maxUsernameLength = 255
+
+// loginKeyName names the keys issued by Login
+const loginKeyName = "login"
+
+// The built-in login for local development, accepted when allowStatic is
+// set; the auth middleware accepts the token
+const (
+	staticUsername = "admin"
+	staticPassword = "password"
+	staticToken    = "test-token"
+)
+
+// dummyHash is compared against when the user does not exist, so that
+// Login takes the same time for unknown users and wrong passwords
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+	return hash
+})
+
+type AuthService interface {
+	CreateUser(ctx context.Context, username, password string, role models.UserRole) (*models.User, error)
+	IssueAPIKey(ctx context.Context, username, name string, ttl time.Duration) (string, *models.APIKey, error)
+	Login(ctx context.Context, username, password, ip string) (string, error)
+	Authenticate(ctx context.Context, token string) (*models.User, error)
+	LoginAttempts(ctx context.Context, filter models.LoginAttemptFilter) ([]models.LoginAttempt, error)
+	Lockouts(ctx context.Context) ([]models.LoginLockout, error)
+	ClearLockout(ctx context.Context, username, ip string) error
+}
+
+type authService struct {
+	users       repositories.UserRepository
+	keys        repositories.APIKeyRepository
+	attempts    repositories.LoginAttemptRepository
+	policy      LoginPolicy
+	sessionTTL  time.Duration
+	allowStatic bool
+	now         func() time.Time
+}
+
+// NewAuthService returns the account service; tokens issued by Login
+// expire after sessionTTL. Logins are recorded in attempts and throttled
+// according to policy. allowStatic also accepts the built-in admin/password
+// login, under the same policy.
+func NewAuthService(users repositories.UserRepository, keys repositories.APIKeyRepository, attempts repositories.LoginAttemptRepository, policy LoginPolicy, sessionTTL time.Duration, allowStatic bool) AuthService {
+	return &authService{users: users, keys: keys, attempts: attempts, policy: policy, sessionTTL: sessionTTL, allowStatic: allowStatic, now: time.Now}
+}
+
+// CreateUser yangi foydalanuvchi yaratadi, parol faqat bcrypt xeshi sifatida saqlanadi
+func (s *authService) CreateUser(ctx context.Context, username, password string, role models.UserRole) (*models.User, error) {
+	username = strings.TrimSpace(username)
+	if role == "" {
+		role = models.RoleUser
+	}
+	switch {
+	case username == "" || len(username) > maxUsernameLength:
+		return nil, fmt.Errorf("%w: username must be 1 to %d characters", ErrValidation, maxUsernameLength)
+	case len(password) < minPasswordLength:
+		return nil, fmt.Errorf("%w: password must be at least %d characters", ErrValidation, minPasswordLength)
+	case role != models.RoleUser && role != models.RoleAdmin:
+		return nil, fmt.Errorf("%w: role must be user or admin", ErrValidation)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrValidation, err)
+	}
+	user := &models.User{
+		ID:           uuid.NewString(),
+		Username:     username,
+		PasswordHash: string(hash),
+		Role:         role,
+		CreatedAt:    s.now(),
+	}
+	if err := s.users.Create(ctx, user); err != nil {
+		if errors.Is(err, ErrConflict) {
+			return nil, fmt.Errorf("%w: username %q is taken", ErrConflict, username)
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// IssueAPIKey foydalanuvchiga yangi API kalit beradi. Kalitning o‘zi faqat
+// shu yerda qaytariladi, bazada uning SHA-256 xeshi saqlanadi. ttl nol
+// bo‘lsa kalit muddatsiz bo‘ladi.
+func (s *authService) IssueAPIKey(ctx context.Context, username, name string, ttl time.Duration) (string, *models.APIKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 255 {
+		return "", nil, fmt.Errorf("%w: key name must be 1 to 255 characters", ErrValidation)
+	}
+	if ttl < 0 {
+		return "", nil, fmt.Errorf("%w: ttl must not be negative", ErrValidation)
+	}
+	user, err := s.users.GetByUsername(ctx, username)
+	if err != nil {
+		return "", nil, err
+	}
+	return s.issue(ctx, user, name, ttl)
+}
+
+func (s *authService) issue(ctx context.Context, user *models.User, name string, ttl time.Duration) (string, *models.APIKey, error) {
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, err
+	}
+	token := APIKeyPrefix + hex.EncodeToString(secret)
+	key := &models.APIKey{
+		UserID:    user.ID,
+		Name:      name,
+		Prefix:    token[:len(APIKeyPrefix)+6],
+		KeyHash:   hashToken(token),
+		CreatedAt: s.now(),
+	}
+	if ttl > 0 {
+		expires := key.CreatedAt.Add(ttl)
+		key.ExpiresAt = &expires
+	}
+	if err := s.keys.Create(ctx, key); err != nil {
+		return "", nil, err
+	}
+	return token, key, nil
+}
+
+// Login parolni tekshiradi va sessionTTL muddatli kalit qaytaradi.
+// Foydalanuvchi topilmasa ham, parol noto‘g‘ri bo‘lsa ham ErrUnauthorized.
+// Foydalanuvchi nomi yoki ip bloklangan bo‘lsa parol tekshirilmaydi,
+// LoginLockedError qaytadi. Har bir urinish jurnalga yoziladi.
+func (s *authService) Login(ctx context.Context, username, password, ip string) (string, error) {
+	// the database keeps microseconds, release compares the locks it set
+	now := s.now().UTC().Truncate(time.Microsecond)
+	if len(username) > maxUsernameLength {
+		// no user has such a name, see CreateUser, and it would not fit the
+		// lockout key
+		if err := s.audit(ctx, now, username, ip, models.LoginFailed); err != nil {
+			return "", err
+		}
+		return "", ErrUnauthorized
+	}
+	taken, err := s.reserve(ctx, now, username, ip)
+	var locked *LoginLockedError
+	if errors.As(err, &locked) {
+		if err := s.audit(ctx, now, username, ip, models.LoginLocked); err != nil {
+			return "", err
+		}
+		return "", locked
+	}
+	if err != nil {
+		return "", err
+	}
+
+	token, err := s.checkPassword(ctx, username, password)
+	if errors.Is(err, ErrUnauthorized) {
+		// the failure was already counted by reserve
+		if err := s.audit(ctx, now, username, ip, models.LoginFailed); err != nil {
+			return "", err
+		}
+		return "", ErrUnauthorized
+	}
+	if err != nil {
+		return "", errors.Join(err, s.release(ctx, taken))
+	}
+	if err := s.audit(ctx, now, username, ip, models.LoginSucceeded); err != nil {
+		return "", err
+	}
+	// the username starts over; the address only gets this attempt back, a
+	// success must not reset password spraying
+	if err := s.attempts.ClearLockout(ctx, taken[0].key); err != nil && !errors.Is(err, ErrNotFound) {
+		return "", err
+	}
+	if err := s.release(ctx, taken[1:]); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// checkPassword issues a session token when password matches
+func (s *authService) checkPassword(ctx context.Context, username, password string) (string, error) {
+	if s.allowStatic && username == staticUsername && password == staticPassword {
+		return staticToken, nil
+	}
+	user, err := s.users.GetByUsername(ctx, username)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return "", err
+	}
+	hash := dummyHash()
+	if user != nil {
+		hash = []byte(user.PasswordHash)
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil || user == nil {
+		return "", ErrUnauthorized
+	}
+	token, _, err := s.issue(ctx, user, loginKeyName, s.sessionTTL)
+	return token, err
+}
+
+// Authenticate kalit egasini qaytaradi. Noma’lum yoki muddati o‘tgan
+// kalit uchun ErrUnauthorized.
+func (s *authService) Authenticate(ctx context.Context, token string) (*models.User, error) {
+	if !strings.HasPrefix(token, APIKeyPrefix) {
+		return nil, ErrUnauthorized
+	}
+	key, err := s.keys.GetByHash(ctx, hashToken(token))
+	if errors.Is(err, ErrNotFound) {
+		return nil, ErrUnauthorized
+	}
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	if key.ExpiresAt != nil && !now.Before(*key.ExpiresAt) {
+		return nil, ErrUnauthorized
+	}
+	user, err := s.users.GetByID(ctx, key.UserID)
+	if errors.Is(err, ErrNotFound) {
+		return nil, ErrUnauthorized
+	}
+	if err != nil {
+		return nil, err
+	}
+	// last use is informational, a failed update must not reject the request
+	_ = s.keys.Touch(ctx, key.ID, now)
+	return user, nil
+}
+
+// hashToken returns the hex SHA-256 of token as stored in api_keys.key_hash
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,286 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"subscriptions_service_golang/internal/models"
+	"subscriptions_service_golang/internal/repositories"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memUsers struct{ users map[string]models.User }
+
+func (r *memUsers) Create(ctx context.Context, user *models.User) error {
+	for _, u := range r.users {
+		if strings.EqualFold(u.Username, user.Username) {
+			return ErrConflict
+		}
+	}
+	r.users[user.ID] = *user
+	return nil
+}
+
+func (r *memUsers) GetByID(ctx context.Context, id string) (*models.User, error) {
+	u, ok := r.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &u, nil
+}
+
+func (r *memUsers) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+	for _, u := range r.users {
+		if strings.EqualFold(u.Username, username) {
+			return &u, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+type memKeys struct{ keys []models.APIKey }
+
+func (r *memKeys) Create(ctx context.Context, key *models.APIKey) error {
+	key.ID = uint(len(r.keys) + 1)
+	r.keys = append(r.keys, *key)
+	return nil
+}
+
+func (r *memKeys) GetByHash(ctx context.Context, hash string) (*models.APIKey, error) {
+	for _, k := range r.keys {
+		if k.KeyHash == hash {
+			return &k, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memKeys) Touch(ctx context.Context, id uint, at time.Time) error {
+	r.keys[id-1].LastUsedAt = &at
+	return nil
+}
+
+func TestAuthService(t *testing.T) {
+	ctx := context.Background()
+	keys := &memKeys{}
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	service := &authService{
+		users:      &memUsers{users: map[string]models.User{}},
+		keys:       keys,
+		attempts:   repositories.NewMemoryLoginAttemptRepository(),
+		sessionTTL: time.Hour,
+		now:        func() time.Time { return now },
+	}
+
+	user, err := service.CreateUser(ctx, "alice", "correct horse", "")
+	assert.NoError(t, err)
+	assert.Equal(t, models.RoleUser, user.Role)
+	assert.NotContains(t, user.PasswordHash, "correct horse")
+
+	_, err = service.CreateUser(ctx, "Alice", "another password", models.RoleAdmin)
+	assert.ErrorIs(t, err, ErrConflict)
+	_, err = service.CreateUser(ctx, "bob", "short", "")
+	assert.ErrorIs(t, err, ErrValidation)
+
+	t.Run("api key", func(t *testing.T) {
+		token, key, err := service.IssueAPIKey(ctx, "alice", "export", 0)
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(token, APIKeyPrefix))
+		assert.True(t, strings.HasPrefix(token, key.Prefix))
+		assert.NotContains(t, key.KeyHash, token)
+
+		owner, err := service.Authenticate(ctx, token)
+		assert.NoError(t, err)
+		assert.Equal(t, user.ID, owner.ID)
+		assert.NotNil(t, keys.keys[key.ID-1].LastUsedAt)
+
+		_, err = service.Authenticate(ctx, token+"x")
+		assert.ErrorIs(t, err, ErrUnauthorized)
+		_, _, err = service.IssueAPIKey(ctx, "nobody", "export", 0)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("login", func(t *testing.T) {
+		_, err := service.Login(ctx, "alice", "wrong password", "")
+		assert.ErrorIs(t, err, ErrUnauthorized)
+		_, err = service.Login(ctx, "nobody", "correct horse", "")
+		assert.ErrorIs(t, err, ErrUnauthorized)
+
+		token, err := service.Login(ctx, "ALICE", "correct horse", "")
+		assert.NoError(t, err)
+		_, err = service.Authenticate(ctx, token)
+		assert.NoError(t, err)
+
+		now = now.Add(time.Hour)
+		_, err = service.Authenticate(ctx, token)
+		assert.ErrorIs(t, err, ErrUnauthorized)
+	})
+}
+
+func TestLoginLockout(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	attempts := repositories.NewMemoryLoginAttemptRepository()
+	service := &authService{
+		users:    &memUsers{users: map[string]models.User{}},
+		keys:     &memKeys{},
+		attempts: attempts,
+		policy: LoginPolicy{
+			MaxFailures:   3,
+			MaxIPFailures: 5,
+			Delay:         time.Second,
+			Lockout:       15 * time.Minute,
+			Window:        time.Hour,
+		},
+		sessionTTL: time.Hour,
+		now:        func() time.Time { return now },
+	}
+	_, err := service.CreateUser(ctx, "alice", "correct horse", "")
+	require.NoError(t, err)
+	login := func(username, password, ip string) error {
+		_, err := service.Login(ctx, username, password, ip)
+		return err
+	}
+	lockedFor := func(err error) time.Duration {
+		t.Helper()
+		var locked *LoginLockedError
+		require.ErrorAs(t, err, &locked)
+		assert.ErrorIs(t, err, ErrRateLimited)
+		return locked.Until.Sub(now)
+	}
+
+	assert.ErrorIs(t, login("alice", "guess 1", "10.0.0.1"), ErrUnauthorized)
+	assert.Equal(t, time.Second, lockedFor(login("alice", "correct horse", "10.0.0.2")), "the next attempt has to wait")
+	now = now.Add(time.Second)
+	assert.ErrorIs(t, login("Alice", "guess 2", "10.0.0.1"), ErrUnauthorized)
+	assert.Equal(t, 2*time.Second, lockedFor(login("alice", "guess 3", "10.0.0.1")), "delays double")
+	now = now.Add(2 * time.Second)
+	assert.ErrorIs(t, login("alice", "guess 3", "10.0.0.1"), ErrUnauthorized)
+	assert.Equal(t, 15*time.Minute, lockedFor(login("alice", "correct horse", "10.0.0.3")), "the threshold locks the username")
+
+	lockouts, err := service.Lockouts(ctx)
+	require.NoError(t, err)
+	require.Len(t, lockouts, 1)
+	assert.Equal(t, "user:alice", lockouts[0].Key)
+	assert.Equal(t, 3, lockouts[0].Failures)
+
+	assert.ErrorIs(t, service.ClearLockout(ctx, "", ""), ErrValidation)
+	assert.ErrorIs(t, service.ClearLockout(ctx, "nobody", ""), ErrNotFound)
+	require.NoError(t, service.ClearLockout(ctx, "ALICE", ""))
+	assert.NoError(t, login("alice", "correct horse", "10.0.0.1"))
+
+	// spraying from one address locks the address, not the usernames
+	for i := 0; i < 2; i++ {
+		assert.ErrorIs(t, login(fmt.Sprintf("user%d", i), "guess", "10.0.0.1"), ErrUnauthorized)
+	}
+	assert.Equal(t, 15*time.Minute, lockedFor(login("alice", "correct horse", "10.0.0.1")))
+	assert.NoError(t, login("alice", "correct horse", "10.0.0.2"))
+
+	now = now.Add(15 * time.Minute)
+	assert.NoError(t, login("alice", "correct horse", "10.0.0.1"), "locks expire")
+
+	log, err := service.LoginAttempts(ctx, models.LoginAttemptFilter{Username: "alice", IP: "10.0.0.1", Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, log, 2)
+	assert.Equal(t, models.LoginSucceeded, log[0].Result)
+	assert.Equal(t, models.LoginLocked, log[1].Result)
+
+	// too long for any user: refused, audited whole characters, not counted
+	long := strings.Repeat("ж", 200)
+	assert.ErrorIs(t, login(long, "guess", "10.0.0.4"), ErrUnauthorized)
+	log, err = service.LoginAttempts(ctx, models.LoginAttemptFilter{IP: "10.0.0.4"})
+	require.NoError(t, err)
+	require.Len(t, log, 1)
+	assert.Equal(t, models.LoginFailed, log[0].Result)
+	assert.Equal(t, strings.Repeat("ж", 200), log[0].Username, "400 bytes but 200 characters")
+	assert.ErrorIs(t, login(strings.Repeat("ж", 300), "guess", "10.0.0.4"), ErrUnauthorized)
+	log, err = service.LoginAttempts(ctx, models.LoginAttemptFilter{IP: "10.0.0.4", Limit: 1})
+	require.NoError(t, err)
+	assert.Equal(t, strings.Repeat("ж", maxUsernameLength), log[0].Username)
+	assert.True(t, utf8.ValidString(log[0].Username))
+	lockouts, err = service.Lockouts(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, lockouts)
+}
+
+func TestLoginConcurrentGuesses(t *testing.T) {
+	ctx := context.Background()
+	newService := func(policy LoginPolicy) *authService {
+		return &authService{
+			users:      &memUsers{users: map[string]models.User{}},
+			keys:       &memKeys{},
+			attempts:   repositories.NewMemoryLoginAttemptRepository(),
+			policy:     policy,
+			sessionTTL: time.Hour,
+			now:        time.Now,
+		}
+	}
+	// guess sends n wrong passwords at once and counts those that were
+	// checked rather than refused as locked
+	guess := func(service *authService, n int) int {
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		checked := 0
+		for i := range n {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := service.Login(ctx, "alice", fmt.Sprintf("guess %d", i), "10.0.0.1")
+				if errors.Is(err, ErrUnauthorized) {
+					mu.Lock()
+					checked++
+					mu.Unlock()
+					return
+				}
+				var locked *LoginLockedError
+				assert.ErrorAs(t, err, &locked)
+			}()
+		}
+		wg.Wait()
+		return checked
+	}
+
+	assert.Equal(t, 1, guess(newService(LoginPolicy{MaxFailures: 5, Delay: time.Minute, Lockout: time.Hour, Window: time.Hour}), 20),
+		"the delay holds back guesses sent together")
+	assert.Equal(t, 3, guess(newService(LoginPolicy{MaxFailures: 3, Lockout: time.Hour, Window: time.Hour}), 20),
+		"no more than the threshold are checked")
+	assert.Equal(t, 4, guess(newService(LoginPolicy{MaxIPFailures: 4, Lockout: time.Hour, Window: time.Hour}), 20))
+}
+
+func TestLoginStaticCredentials(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	service := &authService{
+		users:       &memUsers{users: map[string]models.User{}},
+		keys:        &memKeys{},
+		attempts:    repositories.NewMemoryLoginAttemptRepository(),
+		policy:      LoginPolicy{MaxFailures: 2, Lockout: 15 * time.Minute, Window: time.Hour},
+		sessionTTL:  time.Hour,
+		allowStatic: true,
+		now:         func() time.Time { return now },
+	}
+
+	token, err := service.Login(ctx, "admin", "password", "10.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, "test-token", token)
+
+	for range 2 {
+		_, err = service.Login(ctx, "admin", "guess", "10.0.0.1")
+		assert.ErrorIs(t, err, ErrUnauthorized)
+	}
+	_, err = service.Login(ctx, "admin", "password", "10.0.0.2")
+	assert.ErrorIs(t, err, ErrRateLimited, "the static login is locked like any other")
+
+	service.allowStatic = false
+	now = now.Add(15 * time.Minute)
+	_, err = service.Login(ctx, "admin", "password", "10.0.0.1")
+	assert.ErrorIs(t, err, ErrUnauthorized, "it is off unless allowed")
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"time"
+
+	"subscriptions_service_golang/internal/models"
+)
+
+// monthStart returns the first day of the month t falls in.
+func monthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// monthEnd returns the last instant of the month t falls in.
+func monthEnd(t time.Time) time.Time {
+	return monthStart(t).AddDate(0, 1, 0).Add(-time.Nanosecond)
+}
+
+// billableMonths returns the first day of every month in [from, to] for
+// which sub is charged. A month is charged from the month of StartDate up to
+// and including the month of EndDate, unless the subscription is still in
+// its trial or paused at the start of that month.
+func billableMonths(sub models.Subscription, pauses []models.SubscriptionPause, from, to time.Time) []time.Time {
+	first := monthStart(sub.StartDate)
+	if f := monthStart(from); f.After(first) {
+		first = f
+	}
+	last := monthStart(to)
+	if sub.EndDate != nil {
+		if e := monthStart(*sub.EndDate); e.Before(last) {
+			last = e
+		}
+	}
+
+	var months []time.Time
+	for m := first; !m.After(last); m = m.AddDate(0, 1, 0) {
+		anchor := m
+		if sub.StartDate.After(anchor) {
+			anchor = sub.StartDate
+		}
+		if sub.TrialEndsAt != nil && anchor.Before(*sub.TrialEndsAt) {
+			continue
+		}
+		if pausedAt(pauses, anchor) {
+			continue
+		}
+		months = append(months, m)
+	}
+	return months
+}
+
+// pausedAt reports whether any of the pauses covers t.
+func pausedAt(pauses []models.SubscriptionPause, t time.Time) bool {
+	for _, p := range pauses {
+		if p.PausedAt.After(t) {
+			continue
+		}
+		if p.ResumedAt == nil || p.ResumedAt.After(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// subscriptionCost returns how much sub costs over [from, to].
+func subscriptionCost(sub models.Subscription, pauses []models.SubscriptionPause, from, to time.Time) int {
+	return len(billableMonths(sub, pauses, from, to)) * sub.Price
+}
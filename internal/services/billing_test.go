@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"subscriptions_service_golang/internal/models"
+	"subscriptions_service_golang/internal/repositories"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func date(s string) time.Time {
+	t, _ := time.Parse("2006-01-02", s)
+	return t
+}
+
+func ptr(t time.Time) *time.Time {
+	return &t
+}
+
+func TestSubscriptionCost(t *testing.T) {
+	sub := models.Subscription{Price: 100, StartDate: date("2025-01-01"), EndDate: ptr(date("2025-06-30"))}
+
+	t.Run("whole period", func(t *testing.T) {
+		assert.Equal(t, 600, subscriptionCost(sub, nil, date("2024-01-01"), date("2025-12-31")))
+	})
+
+	t.Run("clipped to range", func(t *testing.T) {
+		assert.Equal(t, 200, subscriptionCost(sub, nil, date("2025-03-15"), date("2025-04-01")))
+	})
+
+	t.Run("trial months are free", func(t *testing.T) {
+		trial := sub
+		trial.TrialEndsAt = ptr(date("2025-03-01"))
+		assert.Equal(t, 400, subscriptionCost(trial, nil, date("2025-01-01"), date("2025-12-31")))
+	})
+
+	t.Run("paused months are free", func(t *testing.T) {
+		pauses := []models.SubscriptionPause{
+			{PausedAt: date("2025-02-10"), ResumedAt: ptr(date("2025-04-10"))},
+		}
+		assert.Equal(t, 400, subscriptionCost(sub, pauses, date("2025-01-01"), date("2025-12-31")))
+	})
+
+	t.Run("open pause", func(t *testing.T) {
+		pauses := []models.SubscriptionPause{{PausedAt: date("2025-05-01")}}
+		assert.Equal(t, 400, subscriptionCost(sub, pauses, date("2025-01-01"), date("2025-12-31")))
+	})
+}
+
+func TestCanTransition(t *testing.T) {
+	assert.True(t, canTransition(models.StatusActive, models.StatusPaused))
+	assert.True(t, canTransition(models.StatusPaused, models.StatusActive))
+	assert.True(t, canTransition(models.StatusTrial, models.StatusCancelled))
+	assert.False(t, canTransition(models.StatusTrial, models.StatusPaused))
+	assert.False(t, canTransition(models.StatusCancelled, models.StatusActive))
+	assert.False(t, canTransition(models.StatusExpired, models.StatusPaused))
+}
+
+func TestResumeRequiresPause(t *testing.T) {
+	ctx := context.Background()
+	repo := repositories.NewMemorySubscriptionRepository()
+	service := &subscriptionService{repo: repo, now: time.Now}
+	trial := &models.Subscription{ServiceName: "Netflix", Price: 500, UserID: importUser, StartDate: date("2025-01-01"),
+		TrialEndsAt: ptr(time.Now().AddDate(0, 1, 0)), Status: models.StatusTrial}
+	require.NoError(t, repo.Create(ctx, trial))
+
+	_, err := service.Resume(ctx, trial.ID)
+	assert.ErrorIs(t, err, ErrInvalidTransition, "a trial has no pause to resume")
+	assert.NotErrorIs(t, err, ErrNotFound)
+
+	trial.Status = models.StatusActive
+	require.NoError(t, repo.Update(ctx, trial))
+	_, err = service.Resume(ctx, trial.ID)
+	assert.ErrorIs(t, err, ErrInvalidTransition)
+}
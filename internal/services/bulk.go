@@ -0,0 +1,254 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"subscriptions_service_golang/internal/models"
+	"subscriptions_service_golang/internal/repositories"
+)
+
+// maxBulkItems limits the number of operations in one bulk request
+const maxBulkItems = 1000
+
+// Bulk modes
+const (
+	BulkAtomic     = "atomic"
+	BulkBestEffort = "best_effort"
+)
+
+// Bulk operations
+const (
+	BulkCreate = "create"
+	BulkUpdate = "update"
+	BulkDelete = "delete"
+)
+
+// Bulk filter actions
+const (
+	BulkSetEndDate = "set_end_date"
+	BulkCancel     = "cancel"
+)
+
+// Bulk item outcomes
+const (
+	BulkOK         = "ok"
+	BulkFailed     = "failed"
+	BulkRolledBack = "rolled_back"
+	BulkNotRun     = "not_run"
+)
+
+// BulkRequest holds either a list of operations or a filter-based action
+type BulkRequest struct {
+	Mode           string          `json:"mode" example:"atomic"`
+	Operations     []BulkOperation `json:"operations,omitempty"`
+	Action         *BulkAction     `json:"action,omitempty"`
+	AllowDuplicate bool            `json:"allow_duplicate,omitempty"`
+}
+
+// BulkOperation is a single create, update, cancel or delete.
+// cancel uses subscription.end_date as the effective date when given.
+type BulkOperation struct {
+	Op           string               `json:"op" example:"update"`
+	ID           uint                 `json:"id,omitempty" example:"1"`
+	Subscription *models.Subscription `json:"subscription,omitempty"`
+}
+
+// BulkAction applies one change to every subscription matching the filter.
+// Type is set_end_date, cancel or delete; EndDate is used by set_end_date
+// and as the effective date of cancel.
+type BulkAction struct {
+	Type        string `json:"type" example:"set_end_date"`
+	UserID      string `json:"user_id,omitempty" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
+	ServiceName string `json:"service_name,omitempty" example:"Netflix"`
+	Active      bool   `json:"active,omitempty"`
+	EndDate     string `json:"end_date,omitempty" example:"2026-03-31"`
+}
+
+// BulkResult is the outcome for one item of a bulk request
+type BulkResult struct {
+	Index        int                  `json:"index" example:"0"`
+	Op           string               `json:"op" example:"update"`
+	ID           uint                 `json:"id,omitempty" example:"1"`
+	Status       string               `json:"status" example:"ok"`
+	Error        string               `json:"error,omitempty"`
+	Subscription *models.Subscription `json:"subscription,omitempty"`
+}
+
+// BulkReport summarises a bulk request. Committed is false when an atomic
+// request was rolled back.
+type BulkReport struct {
+	Mode      string       `json:"mode" example:"atomic"`
+	Committed bool         `json:"committed"`
+	Succeeded int          `json:"succeeded"`
+	Failed    int          `json:"failed"`
+	Results   []BulkResult `json:"results"`
+}
+
+// errBulkRollback aborts the transaction of an atomic bulk request
+var errBulkRollback = errors.New("bulk request rolled back")
+
+// Bulk operatsiyalarni bitta tranzaksiyada bajaradi. atomic rejimida bitta
+// xato hammasini bekor qiladi, best_effort rejimida har bir operatsiya
+// o‘z savepointida bajariladi va xatolisi o‘tkazib yuboriladi.
+func (s *subscriptionService) Bulk(ctx context.Context, req BulkRequest) (*BulkReport, error) {
+	if req.Mode == "" {
+		req.Mode = BulkAtomic
+	}
+	if req.Mode != BulkAtomic && req.Mode != BulkBestEffort {
+		return nil, fmt.Errorf("%w: mode must be %s or %s", ErrValidation, BulkAtomic, BulkBestEffort)
+	}
+	if (len(req.Operations) == 0) == (req.Action == nil) {
+		return nil, fmt.Errorf("%w: either operations or action is required", ErrValidation)
+	}
+	if len(req.Operations) > maxBulkItems {
+		return nil, fmt.Errorf("%w: at most %d operations are allowed", ErrValidation, maxBulkItems)
+	}
+
+	report := &BulkReport{Mode: req.Mode}
+	err := s.repo.Transaction(ctx, func(repo repositories.SubscriptionRepository) error {
+		ops, err := s.bulkOperations(ctx, repo, req)
+		if err != nil {
+			return err
+		}
+		report.Results = make([]BulkResult, len(ops))
+		for i, op := range ops {
+			report.Results[i] = BulkResult{Index: i, Op: op.Op, ID: op.ID, Status: BulkNotRun}
+		}
+
+		for i, op := range ops {
+			result := &report.Results[i]
+			err := repo.Transaction(ctx, func(repo repositories.SubscriptionRepository) error {
+				tx := &subscriptionService{repo: repo, now: s.now}
+				sub, err := tx.applyBulk(ctx, op, req.AllowDuplicate)
+				result.Subscription = sub
+				return err
+			})
+			if err != nil {
+				result.Status = BulkFailed
+				result.Error = err.Error()
+				result.Subscription = nil
+				if req.Mode == BulkAtomic {
+					return errBulkRollback
+				}
+				continue
+			}
+			result.Status = BulkOK
+			if result.Subscription != nil {
+				result.ID = result.Subscription.ID
+			}
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errBulkRollback) {
+		return nil, err
+	}
+
+	report.Committed = err == nil
+	for i := range report.Results {
+		r := &report.Results[i]
+		if !report.Committed && r.Status == BulkOK {
+			r.Status = BulkRolledBack
+			r.Subscription = nil
+		}
+		switch r.Status {
+		case BulkOK:
+			report.Succeeded++
+		case BulkFailed:
+			report.Failed++
+		}
+	}
+	return report, nil
+}
+
+// bulkOperations expands a filter-based action into one operation per
+// matching subscription; plain operation lists are returned as is.
+func (s *subscriptionService) bulkOperations(ctx context.Context, repo repositories.SubscriptionRepository, req BulkRequest) ([]BulkOperation, error) {
+	if req.Action == nil {
+		return req.Operations, nil
+	}
+	action := req.Action
+	if action.UserID == "" && action.ServiceName == "" {
+		return nil, fmt.Errorf("%w: action needs user_id or service_name", ErrValidation)
+	}
+
+	var endDate time.Time
+	switch action.Type {
+	case BulkSetEndDate, BulkCancel:
+		if action.EndDate == "" && action.Type == BulkSetEndDate {
+			return nil, fmt.Errorf("%w: end_date is required", ErrValidation)
+		}
+		endDate = s.now().UTC().Truncate(24 * time.Hour)
+		if action.EndDate != "" {
+			t, err := time.Parse("2006-01-02", action.EndDate)
+			if err != nil {
+				return nil, fmt.Errorf("%w: invalid end_date", ErrValidation)
+			}
+			endDate = t
+		}
+	case BulkDelete:
+	default:
+		return nil, fmt.Errorf("%w: unknown action %q", ErrValidation, action.Type)
+	}
+
+	subs, err := repo.List(ctx, models.SubscriptionFilter{
+		UserID:      action.UserID,
+		ServiceName: action.ServiceName,
+		Active:      action.Active,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(subs) > maxBulkItems {
+		return nil, fmt.Errorf("%w: action matches more than %d subscriptions", ErrValidation, maxBulkItems)
+	}
+
+	ops := make([]BulkOperation, len(subs))
+	for i, sub := range subs {
+		switch action.Type {
+		case BulkSetEndDate:
+			sub.EndDate = &endDate
+			ops[i] = BulkOperation{Op: BulkUpdate, ID: sub.ID, Subscription: &sub}
+		case BulkCancel:
+			sub.EndDate = &endDate
+			ops[i] = BulkOperation{Op: BulkCancel, ID: sub.ID, Subscription: &sub}
+		case BulkDelete:
+			ops[i] = BulkOperation{Op: BulkDelete, ID: sub.ID}
+		}
+	}
+	return ops, nil
+}
+
+func (s *subscriptionService) applyBulk(ctx context.Context, op BulkOperation, allowDuplicate bool) (*models.Subscription, error) {
+	switch op.Op {
+	case BulkCreate:
+		if op.Subscription == nil {
+			return nil, fmt.Errorf("%w: subscription is required", ErrValidation)
+		}
+		return s.Create(ctx, *op.Subscription, allowDuplicate)
+	case BulkUpdate:
+		if op.Subscription == nil || op.ID == 0 {
+			return nil, fmt.Errorf("%w: id and subscription are required", ErrValidation)
+		}
+		sub := *op.Subscription
+		sub.ID = op.ID
+		return s.Update(ctx, sub)
+	case BulkCancel:
+		if op.ID == 0 {
+			return nil, fmt.Errorf("%w: id is required", ErrValidation)
+		}
+		effective := s.now().UTC().Truncate(24 * time.Hour)
+		if op.Subscription != nil && op.Subscription.EndDate != nil {
+			effective = *op.Subscription.EndDate
+		}
+		return s.Cancel(ctx, op.ID, effective)
+	case BulkDelete:
+		if op.ID == 0 {
+			return nil, fmt.Errorf("%w: id is required", ErrValidation)
+		}
+		return nil, s.Delete(ctx, op.ID)
+	}
+	return nil, fmt.Errorf("%w: unknown op %q", ErrValidation, op.Op)
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"subscriptions_service_golang/internal/models"
+	"subscriptions_service_golang/internal/repositories"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mapRepo keeps subscriptions in a map and restores a snapshot when a
+// transaction fails, which is enough to exercise the bulk modes.
+type mapRepo struct {
+	repositories.SubscriptionRepository
+	subs   map[uint]models.Subscription
+	nextID uint
+}
+
+func newMapRepo(subs ...models.Subscription) *mapRepo {
+	r := &mapRepo{subs: make(map[uint]models.Subscription)}
+	for _, sub := range subs {
+		r.subs[sub.ID] = sub
+		r.nextID = max(r.nextID, sub.ID)
+	}
+	return r
+}
+
+func (r *mapRepo) Create(ctx context.Context, sub *models.Subscription) error {
+	r.nextID++
+	sub.ID = r.nextID
+	r.subs[sub.ID] = *sub
+	return nil
+}
+
+func (r *mapRepo) GetByID(ctx context.Context, id uint) (*models.Subscription, error) {
+	sub, ok := r.subs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &sub, nil
+}
+
+func (r *mapRepo) Update(ctx context.Context, sub *models.Subscription) error {
+	r.subs[sub.ID] = *sub
+	return nil
+}
+
+func (r *mapRepo) Delete(ctx context.Context, id uint) error {
+	delete(r.subs, id)
+	return nil
+}
+
+func (r *mapRepo) List(ctx context.Context, filter models.SubscriptionFilter) ([]models.Subscription, error) {
+	var subs []models.Subscription
+	for id := uint(1); id <= r.nextID; id++ {
+		if sub, ok := r.subs[id]; ok && (filter.UserID == "" || sub.UserID == filter.UserID) {
+			subs = append(subs, sub)
+		}
+	}
+	return subs, nil
+}
+
+func (r *mapRepo) FindOverlapping(ctx context.Context, sub models.Subscription) ([]models.Subscription, error) {
+	var found []models.Subscription
+	for _, other := range r.subs {
+		if other.ID != sub.ID && overlaps(sub, other) {
+			found = append(found, other)
+		}
+	}
+	return found, nil
+}
+
+func (r *mapRepo) Transaction(ctx context.Context, fn func(repositories.SubscriptionRepository) error) error {
+	snapshot := make(map[uint]models.Subscription, len(r.subs))
+	for id, sub := range r.subs {
+		snapshot[id] = sub
+	}
+	nextID := r.nextID
+	if err := fn(r); err != nil {
+		r.subs, r.nextID = snapshot, nextID
+		return err
+	}
+	return nil
+}
+
+func TestBulk(t *testing.T) {
+	existing := models.Subscription{ID: 1, ServiceName: "Netflix", Price: 500, UserID: importUser, StartDate: date("2025-07-01"), Status: models.StatusActive}
+	valid := models.Subscription{ServiceName: "Spotify", Price: 300, UserID: importUser, StartDate: date("2025-08-01")}
+	invalid := valid
+	invalid.Price = -1
+	ops := []BulkOperation{
+		{Op: BulkCreate, Subscription: &valid},
+		{Op: BulkCreate, Subscription: &invalid},
+		{Op: BulkDelete, ID: 1},
+	}
+
+	t.Run("atomic rolls back everything", func(t *testing.T) {
+		repo := newMapRepo(existing)
+		service := &subscriptionService{repo: repo, now: time.Now}
+		report, err := service.Bulk(context.Background(), BulkRequest{Mode: BulkAtomic, Operations: ops})
+		assert.NoError(t, err)
+		assert.False(t, report.Committed)
+		assert.Equal(t, BulkRolledBack, report.Results[0].Status)
+		assert.Equal(t, BulkFailed, report.Results[1].Status)
+		assert.Equal(t, BulkNotRun, report.Results[2].Status)
+		assert.Len(t, repo.subs, 1)
+	})
+
+	t.Run("best effort skips failures", func(t *testing.T) {
+		repo := newMapRepo(existing)
+		service := &subscriptionService{repo: repo, now: time.Now}
+		report, err := service.Bulk(context.Background(), BulkRequest{Mode: BulkBestEffort, Operations: ops})
+		assert.NoError(t, err)
+		assert.True(t, report.Committed)
+		assert.Equal(t, 2, report.Succeeded)
+		assert.Equal(t, 1, report.Failed)
+		assert.Len(t, repo.subs, 1)
+		assert.Equal(t, "Spotify", repo.subs[2].ServiceName)
+	})
+
+	t.Run("action by filter", func(t *testing.T) {
+		other := existing
+		other.ID, other.UserID = 2, "11111111-2222-3333-4444-555555555555"
+		repo := newMapRepo(existing, other)
+		service := &subscriptionService{repo: repo, now: time.Now}
+		report, err := service.Bulk(context.Background(), BulkRequest{Action: &BulkAction{Type: BulkSetEndDate, UserID: importUser, EndDate: "2026-03-31"}})
+		assert.NoError(t, err)
+		assert.Len(t, report.Results, 1)
+		assert.Equal(t, "2026-03-31", repo.subs[1].EndDate.Format("2006-01-02"))
+		assert.Nil(t, repo.subs[2].EndDate)
+	})
+
+	t.Run("invalid request", func(t *testing.T) {
+		service := &subscriptionService{repo: newMapRepo(), now: time.Now}
+		_, err := service.Bulk(context.Background(), BulkRequest{Mode: "sometimes", Operations: ops})
+		assert.True(t, errors.Is(err, ErrValidation))
+		_, err = service.Bulk(context.Background(), BulkRequest{Action: &BulkAction{Type: BulkDelete}})
+		assert.True(t, errors.Is(err, ErrValidation))
+	})
+}
@@ -0,0 +1,275 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"subscriptions_service_golang/internal/cache"
+	"subscriptions_service_golang/internal/models"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Cache key scopes. A user's totals depend on that user's subscriptions
+// only, totals over all users and the stats on every subscription.
+const (
+	scopeAll    = "all"
+	scopeGlobal = "global"
+)
+
+// minGenerationTTL keeps generation tokens around much longer than the
+// entries built on them; an expired token only causes cache misses
+const minGenerationTTL = 24 * time.Hour
+
+// cachingService caches TotalPrice and Stats.
+//
+// Entries are not deleted on changes. Every key contains the current
+// generation token of its scope instead, and a change replaces the tokens
+// of the affected user and of the global scope, so older entries are
+// never read again and expire on their own. This works the same for a
+// local LRU and for Redis, where deleting keys by pattern is expensive.
+// Bulk requests may touch any user and replace the token shared by all
+// entries.
+type cachingService struct {
+	next     SubscriptionService
+	cache    cache.Cache
+	ttl      time.Duration
+	now      func() time.Time
+	requests *prometheus.CounterVec
+}
+
+// NewCachingService next ni o‘rab, TotalPrice va Stats natijalarini ttl
+// davomida c da saqlaydi. Subscription o‘zgarganda tegishli foydalanuvchi
+// keshi eskiradi. reg nil bo‘lsa metrikalar ro‘yxatdan o‘tkazilmaydi.
+func NewCachingService(next SubscriptionService, c cache.Cache, ttl time.Duration, reg prometheus.Registerer) SubscriptionService {
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "subscription_cache_requests_total",
+		Help: "Cache lookups of subscription totals and stats by operation and result (hit, miss or error).",
+	}, []string{"operation", "result"})
+	if reg != nil {
+		reg.MustRegister(requests)
+	}
+	return &cachingService{next: next, cache: c, ttl: ttl, now: time.Now, requests: requests}
+}
+
+// cached returns the value stored under the key of operation and params
+// in scope, or computes, stores and returns it. A failing cache is
+// bypassed, it never fails the request.
+func cached[T any](ctx context.Context, s *cachingService, operation, scope string, params url.Values, compute func() (T, error)) (T, error) {
+	key, err := s.key(ctx, operation, scope, params)
+	if err != nil {
+		s.requests.WithLabelValues(operation, "error").Inc()
+		return compute()
+	}
+	data, ok, err := s.cache.Get(ctx, key)
+	var value T
+	switch {
+	case err != nil:
+		s.requests.WithLabelValues(operation, "error").Inc()
+		return compute()
+	case ok && json.Unmarshal(data, &value) == nil:
+		s.requests.WithLabelValues(operation, "hit").Inc()
+		return value, nil
+	}
+	s.requests.WithLabelValues(operation, "miss").Inc()
+
+	value, err = compute()
+	if err != nil {
+		return value, err
+	}
+	if data, err := json.Marshal(value); err == nil {
+		s.cache.Set(ctx, key, data, s.ttl)
+	}
+	return value, nil
+}
+
+// key builds the cache key from the generation tokens and the params,
+// which url.Values encodes sorted and escaped
+func (s *cachingService) key(ctx context.Context, operation, scope string, params url.Values) (string, error) {
+	all, err := s.generation(ctx, scopeAll)
+	if err != nil {
+		return "", err
+	}
+	gen, err := s.generation(ctx, scope)
+	if err != nil {
+		return "", err
+	}
+	return operation + ":" + all + ":" + gen + ":" + params.Encode(), nil
+}
+
+// generation returns the current token of scope, starting a new one when
+// there is none
+func (s *cachingService) generation(ctx context.Context, scope string) (string, error) {
+	token, ok, err := s.cache.Get(ctx, "gen:"+scope)
+	if err != nil || ok {
+		return string(token), err
+	}
+	return s.invalidate(ctx, scope)
+}
+
+// invalidate gives scope a new token, so nothing cached under the old one
+// is read again
+func (s *cachingService) invalidate(ctx context.Context, scope string) (string, error) {
+	b := make([]byte, 8)
+	rand.Read(b)
+	token := hex.EncodeToString(b)
+	return token, s.cache.Set(ctx, "gen:"+scope, []byte(token), max(s.ttl, minGenerationTTL))
+}
+
+func userScope(userID string) string {
+	return "user:" + strings.ToLower(strings.TrimSpace(userID))
+}
+
+// invalidateUsers drops the cached results that may include subscriptions
+// of the given users. It ignores cancellation of ctx, so that a request
+// cancelled right after its change does not leave stale entries behind.
+func (s *cachingService) invalidateUsers(ctx context.Context, userIDs ...string) {
+	ctx = context.WithoutCancel(ctx)
+	seen := make(map[string]bool, len(userIDs))
+	for _, userID := range userIDs {
+		if userID != "" && !seen[userID] {
+			seen[userID] = true
+			s.invalidate(ctx, userScope(userID))
+		}
+	}
+	s.invalidate(ctx, scopeGlobal)
+}
+
+// userOf returns the owner of subscription id before it is changed
+func (s *cachingService) userOf(ctx context.Context, id uint) string {
+	sub, err := s.next.GetByID(ctx, id)
+	if err != nil {
+		return ""
+	}
+	return sub.UserID
+}
+
+func (s *cachingService) TotalPrice(ctx context.Context, userID string, serviceName string, from, to *time.Time) (int, error) {
+	// the service counts whole months, so the key holds months only; an
+	// open range ends in the current month
+	params := url.Values{"service": {serviceName}, "to": {s.now().Format("2006-01")}}
+	if from != nil {
+		params.Set("from", from.Format("2006-01"))
+	}
+	if to != nil {
+		params.Set("to", to.Format("2006-01"))
+	}
+	scope := scopeGlobal
+	if userID != "" {
+		scope = userScope(userID)
+	}
+	return cached(ctx, s, "total_price", scope, params, func() (int, error) {
+		return s.next.TotalPrice(ctx, userID, serviceName, from, to)
+	})
+}
+
+func (s *cachingService) Stats(ctx context.Context) (models.SubscriptionStats, error) {
+	params := url.Values{"on": {s.now().UTC().Format(time.DateOnly)}}
+	return cached(ctx, s, "stats", scopeGlobal, params, func() (models.SubscriptionStats, error) {
+		return s.next.Stats(ctx)
+	})
+}
+
+func (s *cachingService) Create(ctx context.Context, sub models.Subscription, allowDuplicate bool) (*models.Subscription, error) {
+	res, err := s.next.Create(ctx, sub, allowDuplicate)
+	if err == nil {
+		s.invalidateUsers(ctx, res.UserID)
+	}
+	return res, err
+}
+
+func (s *cachingService) Update(ctx context.Context, sub models.Subscription) (*models.Subscription, error) {
+	// the subscription may move to another user
+	previous := s.userOf(ctx, sub.ID)
+	res, err := s.next.Update(ctx, sub)
+	if err == nil {
+		s.invalidateUsers(ctx, previous, res.UserID)
+	}
+	return res, err
+}
+
+func (s *cachingService) Delete(ctx context.Context, id uint) error {
+	userID := s.userOf(ctx, id)
+	err := s.next.Delete(ctx, id)
+	if err == nil {
+		s.invalidateUsers(ctx, userID)
+	}
+	return err
+}
+
+func (s *cachingService) Pause(ctx context.Context, id uint) (*models.Subscription, error) {
+	res, err := s.next.Pause(ctx, id)
+	if err == nil {
+		s.invalidateUsers(ctx, res.UserID)
+	}
+	return res, err
+}
+
+func (s *cachingService) Resume(ctx context.Context, id uint) (*models.Subscription, error) {
+	res, err := s.next.Resume(ctx, id)
+	if err == nil {
+		s.invalidateUsers(ctx, res.UserID)
+	}
+	return res, err
+}
+
+func (s *cachingService) Cancel(ctx context.Context, id uint, effectiveDate time.Time) (*models.Subscription, error) {
+	res, err := s.next.Cancel(ctx, id, effectiveDate)
+	if err == nil {
+		s.invalidateUsers(ctx, res.UserID)
+	}
+	return res, err
+}
+
+func (s *cachingService) ExpireDue(ctx context.Context, now time.Time) ([]models.Subscription, error) {
+	res, err := s.next.ExpireDue(ctx, now)
+	if len(res) > 0 {
+		userIDs := make([]string, len(res))
+		for i, sub := range res {
+			userIDs[i] = sub.UserID
+		}
+		s.invalidateUsers(ctx, userIDs...)
+	}
+	return res, err
+}
+
+func (s *cachingService) Import(ctx context.Context, rows []ImportRow, opts ImportOptions) (*ImportReport, error) {
+	res, err := s.next.Import(ctx, rows, opts)
+	if res != nil && res.Created > 0 && !opts.DryRun {
+		userIDs := make([]string, len(rows))
+		for i, row := range rows {
+			userIDs[i] = row.Subscription.UserID
+		}
+		s.invalidateUsers(ctx, userIDs...)
+	}
+	return res, err
+}
+
+func (s *cachingService) Bulk(ctx context.Context, req BulkRequest) (*BulkReport, error) {
+	res, err := s.next.Bulk(ctx, req)
+	// filter actions and deletes by ID do not tell which users they touched
+	s.invalidate(context.WithoutCancel(ctx), scopeAll)
+	return res, err
+}
+
+func (s *cachingService) GetByID(ctx context.Context, id uint) (*models.Subscription, error) {
+	return s.next.GetByID(ctx, id)
+}
+
+func (s *cachingService) List(ctx context.Context, filter models.SubscriptionFilter) ([]models.Subscription, error) {
+	return s.next.List(ctx, filter)
+}
+
+func (s *cachingService) Export(ctx context.Context, filter models.SubscriptionFilter, format string, w io.Writer) error {
+	return s.next.Export(ctx, filter, format, w)
+}
+
+func (s *cachingService) FindDuplicates(ctx context.Context, userID string) ([]DuplicateGroup, error) {
+	return s.next.FindDuplicates(ctx, userID)
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"subscriptions_service_golang/internal/cache"
+	"subscriptions_service_golang/internal/models"
+	"subscriptions_service_golang/internal/repositories"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const otherUser = "7d3f1c2a-9b8e-4f6d-a5c4-3b2a1f0e9d8c"
+
+// countingService counts the totals it computes
+type countingService struct {
+	SubscriptionService
+	totals int
+}
+
+func (s *countingService) TotalPrice(ctx context.Context, userID string, serviceName string, from, to *time.Time) (int, error) {
+	s.totals++
+	return s.SubscriptionService.TotalPrice(ctx, userID, serviceName, from, to)
+}
+
+// brokenCache fails like an unreachable Redis
+type brokenCache struct{}
+
+func (brokenCache) Get(context.Context, string) ([]byte, bool, error) {
+	return nil, false, errors.New("connection refused")
+}
+
+func (brokenCache) Set(context.Context, string, []byte, time.Duration) error {
+	return errors.New("connection refused")
+}
+
+func TestCachingService(t *testing.T) {
+	ctx := context.Background()
+	now := func() time.Time { return date("2025-09-15") }
+	repo := repositories.NewMemorySubscriptionRepository()
+	require.NoError(t, repo.CreateBatch(ctx, []models.Subscription{
+		{ID: 1, ServiceName: "Netflix", Price: 500, UserID: importUser, StartDate: date("2025-07-01"), Status: models.StatusActive},
+		{ID: 2, ServiceName: "Spotify", Price: 300, UserID: otherUser, StartDate: date("2025-07-01"), Status: models.StatusActive},
+	}))
+	next := &countingService{SubscriptionService: &subscriptionService{repo: repo, now: now}}
+	reg := prometheus.NewRegistry()
+	service := NewCachingService(next, cache.NewLRU(100), time.Minute, reg)
+	service.(*cachingService).now = now
+
+	total := func(userID string, from string) int {
+		t.Helper()
+		start := date(from)
+		got, err := service.TotalPrice(ctx, userID, "", &start, nil)
+		require.NoError(t, err)
+		return got
+	}
+	computed := func() int {
+		n := next.totals
+		next.totals = 0
+		return n
+	}
+
+	assert.Equal(t, 1500, total(importUser, "2025-07-01"))
+	assert.Equal(t, 1500, total(importUser, "2025-07-20"), "dates in the same month share the entry")
+	assert.Equal(t, 900, total(otherUser, "2025-07-01"))
+	assert.Equal(t, 2400, total("", "2025-07-01"))
+	assert.Equal(t, 3, computed())
+
+	requests := service.(*cachingService).requests
+	assert.Equal(t, 1.0, testutil.ToFloat64(requests.WithLabelValues("total_price", "hit")))
+	assert.Equal(t, 3.0, testutil.ToFloat64(requests.WithLabelValues("total_price", "miss")))
+
+	t.Run("a change invalidates its user and the global totals", func(t *testing.T) {
+		_, err := service.Create(ctx, models.Subscription{ServiceName: "YouTube", Price: 100, UserID: importUser, StartDate: date("2025-09-01")}, false)
+		require.NoError(t, err)
+		assert.Equal(t, 1600, total(importUser, "2025-07-01"))
+		assert.Equal(t, 900, total(otherUser, "2025-07-01"))
+		assert.Equal(t, 2500, total("", "2025-07-01"))
+		assert.Equal(t, 2, computed(), "the other user's total is still cached")
+
+		require.NoError(t, service.Delete(ctx, 2))
+		assert.Equal(t, 0, total(otherUser, "2025-07-01"))
+		assert.Equal(t, 1600, total(importUser, "2025-07-01"))
+		assert.Equal(t, 1, computed())
+	})
+
+	t.Run("bulk requests invalidate everything", func(t *testing.T) {
+		_, err := service.Bulk(ctx, BulkRequest{Mode: BulkBestEffort, Action: &BulkAction{Type: BulkSetEndDate, UserID: importUser, EndDate: "2025-08-31"}})
+		require.NoError(t, err)
+		assert.Equal(t, 1100, total(importUser, "2025-07-01"))
+		assert.Equal(t, 0, total(otherUser, "2025-07-01"))
+		assert.Equal(t, 2, computed())
+	})
+
+	t.Run("a failing cache is bypassed", func(t *testing.T) {
+		broken := NewCachingService(next, brokenCache{}, time.Minute, nil)
+		start := date("2025-07-01")
+		got, err := broken.TotalPrice(ctx, importUser, "", &start, nil)
+		require.NoError(t, err)
+		assert.Equal(t, 1100, got)
+		assert.Equal(t, 1, computed())
+	})
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"subscriptions_service_golang/internal/models"
+)
+
+// DuplicateGroup lists subscriptions of one service whose periods overlap
+type DuplicateGroup struct {
+	ServiceName   string                `json:"service_name" example:"Netflix"`
+	Subscriptions []models.Subscription `json:"subscriptions"`
+}
+
+// overlaps reports whether a and b belong to the same user and service
+// and their periods intersect. A missing end date means open-ended.
+func overlaps(a, b models.Subscription) bool {
+	if a.UserID != b.UserID || !strings.EqualFold(a.ServiceName, b.ServiceName) {
+		return false
+	}
+	if a.EndDate != nil && a.EndDate.Before(b.StartDate) {
+		return false
+	}
+	if b.EndDate != nil && b.EndDate.Before(a.StartDate) {
+		return false
+	}
+	return true
+}
+
+// checkDuplicate rejects sub if it overlaps an existing subscription,
+// or flags it as a duplicate when allowDuplicate is set
+func (s *subscriptionService) checkDuplicate(ctx context.Context, sub *models.Subscription, allowDuplicate bool) error {
+	existing, err := s.repo.FindOverlapping(ctx, *sub)
+	if err != nil {
+		return err
+	}
+	if len(existing) == 0 {
+		return nil
+	}
+	if !allowDuplicate {
+		return fmt.Errorf("%w: overlaps subscription %d", ErrDuplicate, existing[0].ID)
+	}
+	sub.Duplicate = true
+	return nil
+}
+
+// FindDuplicates foydalanuvchining bir xil servisga vaqti ustma-ust tushgan
+// subscriptionlarini guruhlab qaytaradi
+func (s *subscriptionService) FindDuplicates(ctx context.Context, userID string) ([]DuplicateGroup, error) {
+	subs, err := s.repo.List(ctx, models.SubscriptionFilter{UserID: userID})
+	if err != nil {
+		return nil, err
+	}
+
+	byService := make(map[string][]models.Subscription)
+	var names []string
+	for _, sub := range subs {
+		key := strings.ToLower(sub.ServiceName)
+		if _, ok := byService[key]; !ok {
+			names = append(names, key)
+		}
+		byService[key] = append(byService[key], sub)
+	}
+	sort.Strings(names)
+
+	groups := []DuplicateGroup{}
+	for _, name := range names {
+		list := byService[name]
+		sort.SliceStable(list, func(i, j int) bool { return list[i].StartDate.Before(list[j].StartDate) })
+
+		// sorted by start date, a subscription joins the current group
+		// while it starts before the group's latest end date
+		group := []models.Subscription{list[0]}
+		end := list[0].EndDate
+		flush := func() {
+			if len(group) > 1 {
+				groups = append(groups, DuplicateGroup{ServiceName: group[0].ServiceName, Subscriptions: group})
+			}
+		}
+		for _, sub := range list[1:] {
+			if end == nil || !sub.StartDate.After(*end) {
+				group = append(group, sub)
+				if end != nil && (sub.EndDate == nil || sub.EndDate.After(*end)) {
+					end = sub.EndDate
+				}
+				continue
+			}
+			flush()
+			group = []models.Subscription{sub}
+			end = sub.EndDate
+		}
+		flush()
+	}
+	return groups, nil
+}
@@ -0,0 +1,53 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"subscriptions_service_golang/internal/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateDuplicate(t *testing.T) {
+	existing := models.Subscription{ID: 1, ServiceName: "Netflix", Price: 500, UserID: importUser, StartDate: date("2025-01-01"), EndDate: ptr(date("2025-06-30"))}
+	service := &subscriptionService{repo: newMapRepo(existing), now: time.Now}
+
+	overlapping := models.Subscription{ServiceName: "netflix", Price: 500, UserID: importUser, StartDate: date("2025-06-01")}
+	_, err := service.Create(context.Background(), overlapping, false)
+	assert.ErrorIs(t, err, ErrDuplicate)
+
+	sub, err := service.Create(context.Background(), overlapping, true)
+	assert.NoError(t, err)
+	assert.True(t, sub.Duplicate)
+
+	later := overlapping
+	later.ServiceName = "Spotify"
+	sub, err = service.Create(context.Background(), later, false)
+	assert.NoError(t, err)
+	assert.False(t, sub.Duplicate)
+}
+
+func TestFindDuplicates(t *testing.T) {
+	sub := func(id uint, service, start string, end *time.Time) models.Subscription {
+		return models.Subscription{ID: id, ServiceName: service, UserID: importUser, StartDate: date(start), EndDate: end}
+	}
+	repo := newMapRepo(
+		sub(1, "Netflix", "2025-01-01", ptr(date("2025-03-31"))),
+		sub(2, "Netflix", "2025-03-01", ptr(date("2025-04-30"))),
+		sub(3, "Netflix", "2025-06-01", nil),
+		sub(4, "Spotify", "2025-01-01", nil),
+		sub(5, "spotify", "2026-01-01", nil),
+		sub(6, "YouTube", "2025-01-01", nil),
+	)
+	service := &subscriptionService{repo: repo, now: time.Now}
+
+	groups, err := service.FindDuplicates(context.Background(), importUser)
+	assert.NoError(t, err)
+	assert.Len(t, groups, 2)
+	assert.Equal(t, "Netflix", groups[0].ServiceName)
+	assert.Equal(t, uint(1), groups[0].Subscriptions[0].ID)
+	assert.Equal(t, uint(2), groups[0].Subscriptions[1].ID)
+	assert.Len(t, groups[1].Subscriptions, 2)
+}
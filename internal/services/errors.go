@@ -0,0 +1,35 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"subscriptions_service_golang/internal/repositories"
+)
+
+// Domain errors returned by the services. Handlers compare against them
+// with errors.Is; the more specific errors below wrap one of these.
+var (
+	ErrNotFound   = repositories.ErrNotFound
+	ErrConflict   = repositories.ErrConflict
+	ErrValidation = repositories.ErrValidation
+	// ErrUnavailable is returned while the database is down
+	ErrUnavailable = repositories.ErrUnavailable
+	ErrForbidden   = errors.New("forbidden")
+
+	// ErrUnauthorized is returned when credentials or a token are not valid
+	ErrUnauthorized = errors.New("unauthorized")
+
+	// ErrRateLimited is returned when a client has made too many requests
+	ErrRateLimited = errors.New("too many requests")
+)
+
+var (
+	// ErrInvalidTransition is returned when a status change is not allowed
+	// from the subscription's current status
+	ErrInvalidTransition = fmt.Errorf("%w: invalid status transition", ErrConflict)
+
+	// ErrDuplicate is returned by Create when the user already has a
+	// subscription to the same service for an overlapping period
+	ErrDuplicate = fmt.Errorf("%w: duplicate subscription", ErrConflict)
+)
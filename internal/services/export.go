@@ -0,0 +1,183 @@
+package services
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"subscriptions_service_golang/internal/models"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// ExportContentTypes maps supported export formats to their content type
+var ExportContentTypes = map[string]string{
+	"csv":    "text/csv",
+	"ndjson": "application/x-ndjson",
+	"xlsx":   "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+var exportHeader = []string{
+	"id", "user_id", "service_name", "price", "status",
+	"start_date", "end_date", "trial_ends_at", "cancelled_at", "created_at", "updated_at",
+}
+
+// exporter writes subscriptions one by one in a specific format. Close
+// finishes the file, Abort releases it without writing what is buffered.
+type exporter interface {
+	Write(sub models.Subscription) error
+	Close() error
+	Abort()
+}
+
+func newExporter(format string, w io.Writer) (exporter, error) {
+	switch format {
+	case "csv":
+		return newCSVExporter(w)
+	case "ndjson":
+		return &ndjsonExporter{enc: json.NewEncoder(w)}, nil
+	case "xlsx":
+		return newXLSXExporter(w)
+	}
+	return nil, fmt.Errorf("%w: unsupported export format %q", ErrValidation, format)
+}
+
+func exportRecord(sub models.Subscription) []string {
+	return []string{
+		strconv.FormatUint(uint64(sub.ID), 10),
+		sub.UserID,
+		sub.ServiceName,
+		strconv.Itoa(sub.Price),
+		string(sub.Status),
+		sub.StartDate.Format("2006-01-02"),
+		formatDate(sub.EndDate),
+		formatDate(sub.TrialEndsAt),
+		formatTimestamp(sub.CancelledAt),
+		sub.CreatedAt.Format(time.RFC3339),
+		sub.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+func formatDate(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
+
+func formatTimestamp(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+type csvExporter struct {
+	w *csv.Writer
+}
+
+func newCSVExporter(w io.Writer) (*csvExporter, error) {
+	e := &csvExporter{w: csv.NewWriter(w)}
+	return e, e.w.Write(exportHeader)
+}
+
+func (e *csvExporter) Write(sub models.Subscription) error {
+	record := exportRecord(sub)
+	for i, v := range record {
+		record[i] = csvCell(v)
+	}
+	return e.w.Write(record)
+}
+
+// csvCell keeps spreadsheets from running a user supplied value such as
+// "=HYPERLINK(...)" as a formula by prefixing it with a quote
+func csvCell(v string) string {
+	if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
+
+func (e *csvExporter) Close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvExporter) Abort() {}
+
+type ndjsonExporter struct {
+	enc *json.Encoder
+}
+
+func (e *ndjsonExporter) Write(sub models.Subscription) error {
+	return e.enc.Encode(sub)
+}
+
+func (e *ndjsonExporter) Close() error {
+	return nil
+}
+
+func (e *ndjsonExporter) Abort() {}
+
+// xlsxExporter uses excelize's stream writer, which spills rows to a
+// temporary file instead of keeping the whole sheet in memory.
+type xlsxExporter struct {
+	out  io.Writer
+	file *excelize.File
+	sw   *excelize.StreamWriter
+	row  int
+}
+
+func newXLSXExporter(w io.Writer) (*xlsxExporter, error) {
+	file := excelize.NewFile()
+	sw, err := file.NewStreamWriter("Sheet1")
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	e := &xlsxExporter{out: w, file: file, sw: sw, row: 1}
+	header := make([]interface{}, len(exportHeader))
+	for i, h := range exportHeader {
+		header[i] = h
+	}
+	return e, e.writeRow(header)
+}
+
+// Write stores the text cells as strings; excelize only writes a formula
+// for an explicit excelize.Cell, so "=..." stays plain text
+func (e *xlsxExporter) Write(sub models.Subscription) error {
+	record := exportRecord(sub)
+	row := make([]interface{}, len(record))
+	for i, v := range record {
+		row[i] = v
+	}
+	row[0] = sub.ID
+	row[3] = sub.Price
+	return e.writeRow(row)
+}
+
+func (e *xlsxExporter) writeRow(values []interface{}) error {
+	cell, err := excelize.CoordinatesToCellName(1, e.row)
+	if err != nil {
+		return err
+	}
+	e.row++
+	return e.sw.SetRow(cell, values)
+}
+
+func (e *xlsxExporter) Close() error {
+	defer e.file.Close()
+	if err := e.sw.Flush(); err != nil {
+		return err
+	}
+	_, err := e.file.WriteTo(e.out)
+	return err
+}
+
+func (e *xlsxExporter) Abort() {
+	e.file.Close()
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"subscriptions_service_golang/internal/models"
+	"subscriptions_service_golang/internal/repositories"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xuri/excelize/v2"
+)
+
+type iterRepo struct {
+	repositories.SubscriptionRepository
+	subs []models.Subscription
+	err  error
+}
+
+func (r *iterRepo) Iterate(ctx context.Context, filter models.SubscriptionFilter, fn func(models.Subscription) error) error {
+	if r.err != nil {
+		return r.err
+	}
+	for _, sub := range r.subs {
+		if err := fn(sub); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestExport(t *testing.T) {
+	repo := &iterRepo{subs: []models.Subscription{
+		{ID: 1, ServiceName: "Netflix", Price: 500, UserID: importUser, StartDate: date("2025-07-01"), Status: models.StatusActive},
+		{ID: 2, ServiceName: "Spotify", Price: 300, UserID: importUser, StartDate: date("2025-08-01"), EndDate: ptr(date("2025-12-31")), Status: models.StatusCancelled},
+	}}
+	service := NewSubscriptionService(repo)
+
+	t.Run("csv", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, service.Export(context.Background(), models.SubscriptionFilter{}, "csv", &buf))
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		assert.Len(t, lines, 3)
+		assert.True(t, strings.HasPrefix(lines[0], "id,user_id,service_name,price"))
+		assert.Contains(t, lines[2], "Spotify,300,cancelled,2025-08-01,2025-12-31")
+	})
+
+	t.Run("ndjson", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, service.Export(context.Background(), models.SubscriptionFilter{}, "ndjson", &buf))
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		assert.Len(t, lines, 2)
+		var sub models.Subscription
+		assert.NoError(t, json.Unmarshal([]byte(lines[1]), &sub))
+		assert.Equal(t, "Spotify", sub.ServiceName)
+	})
+
+	t.Run("xlsx", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, service.Export(context.Background(), models.SubscriptionFilter{}, "xlsx", &buf))
+		file, err := excelize.OpenReader(&buf)
+		assert.NoError(t, err)
+		defer file.Close()
+		rows, err := file.GetRows("Sheet1")
+		assert.NoError(t, err)
+		assert.Len(t, rows, 3)
+		assert.Equal(t, "Netflix", rows[1][2])
+	})
+
+	t.Run("failed query writes nothing", func(t *testing.T) {
+		broken := NewSubscriptionService(&iterRepo{err: ErrUnavailable})
+		for _, format := range []string{"csv", "ndjson", "xlsx"} {
+			var buf bytes.Buffer
+			assert.ErrorIs(t, broken.Export(context.Background(), models.SubscriptionFilter{}, format, &buf), ErrUnavailable)
+			assert.Zero(t, buf.Len(), format)
+		}
+	})
+
+	t.Run("formulas stay text", func(t *testing.T) {
+		formulas := NewSubscriptionService(&iterRepo{subs: []models.Subscription{
+			{ID: 1, ServiceName: "=HYPERLINK(\"http://evil\")", Price: 500, UserID: importUser, StartDate: date("2025-07-01"), Status: models.StatusActive},
+			{ID: 2, ServiceName: "@SUM(A1)", Price: 300, UserID: importUser, StartDate: date("2025-08-01"), Status: models.StatusActive},
+			{ID: 3, ServiceName: "Netflix-2", Price: 300, UserID: importUser, StartDate: date("2025-08-01"), Status: models.StatusActive},
+		}})
+
+		var buf bytes.Buffer
+		assert.NoError(t, formulas.Export(context.Background(), models.SubscriptionFilter{}, "csv", &buf))
+		records, err := csv.NewReader(&buf).ReadAll()
+		assert.NoError(t, err)
+		assert.Equal(t, `'=HYPERLINK("http://evil")`, records[1][2])
+		assert.Equal(t, "'@SUM(A1)", records[2][2])
+		assert.Equal(t, "Netflix-2", records[3][2])
+
+		buf.Reset()
+		assert.NoError(t, formulas.Export(context.Background(), models.SubscriptionFilter{}, "xlsx", &buf))
+		file, err := excelize.OpenReader(&buf)
+		assert.NoError(t, err)
+		defer file.Close()
+		formula, err := file.GetCellFormula("Sheet1", "C2")
+		assert.NoError(t, err)
+		assert.Empty(t, formula)
+		value, err := file.GetCellValue("Sheet1", "C2")
+		assert.NoError(t, err)
+		assert.Equal(t, `=HYPERLINK("http://evil")`, value)
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		assert.ErrorIs(t, service.Export(context.Background(), models.SubscriptionFilter{}, "pdf", &bytes.Buffer{}), ErrValidation)
+	})
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"subscriptions_service_golang/internal/models"
+)
+
+// importBatchSize is the number of rows inserted per transaction
+const importBatchSize = 100
+
+// Import row outcomes
+const (
+	ImportCreated = "created"
+	ImportSkipped = "skipped"
+	ImportFailed  = "failed"
+)
+
+// ImportOptions control how Import treats the rows
+type ImportOptions struct {
+	DryRun         bool
+	AllowDuplicate bool
+}
+
+// ImportRow is a parsed line of an import file. Err is set when the line
+// could not be parsed into a subscription.
+type ImportRow struct {
+	Line         int
+	Subscription models.Subscription
+	Err          error
+}
+
+// ImportResult is the outcome for one row of an import
+type ImportResult struct {
+	Line   int    `json:"line" example:"2"`
+	Status string `json:"status" example:"created"`
+	ID     uint   `json:"id,omitempty" example:"42"`
+	Reason string `json:"reason,omitempty" example:"price must not be negative"`
+}
+
+// ImportReport summarises an import
+type ImportReport struct {
+	DryRun  bool           `json:"dry_run"`
+	Created int            `json:"created"`
+	Skipped int            `json:"skipped"`
+	Failed  int            `json:"failed"`
+	Rows    []ImportResult `json:"rows"`
+}
+
+// importRecord is the shape of a row in both CSV and NDJSON uploads
+type importRecord struct {
+	ServiceName string `json:"service_name"`
+	Price       int    `json:"price"`
+	UserID      string `json:"user_id"`
+	StartDate   string `json:"start_date"`
+	EndDate     string `json:"end_date"`
+	TrialEndsAt string `json:"trial_ends_at"`
+}
+
+var importColumns = []string{"service_name", "price", "user_id", "start_date", "end_date", "trial_ends_at"}
+
+// ParseImportCSV reads a CSV file with a header row naming the columns.
+// service_name, price, user_id and start_date columns are required.
+func ParseImportCSV(r io.Reader) ([]ImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read csv header: %w", err)
+	}
+	index := make(map[string]int)
+	for i, name := range header {
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range importColumns[:4] {
+		if _, ok := index[name]; !ok {
+			return nil, fmt.Errorf("csv header is missing column %q", name)
+		}
+	}
+
+	var rows []ImportRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				rows = append(rows, ImportRow{Line: parseErr.Line, Err: parseErr.Err})
+				continue
+			}
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		field := func(name string) string {
+			if i, ok := index[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		row := ImportRow{Line: line}
+		rec := importRecord{
+			ServiceName: field("service_name"),
+			UserID:      field("user_id"),
+			StartDate:   field("start_date"),
+			EndDate:     field("end_date"),
+			TrialEndsAt: field("trial_ends_at"),
+		}
+		if rec.Price, err = strconv.Atoi(field("price")); err != nil {
+			row.Err = fmt.Errorf("invalid price %q", field("price"))
+		} else {
+			row.Subscription, row.Err = rec.subscription()
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// ParseImportNDJSON reads one JSON subscription object per line.
+// Blank lines are ignored.
+func ParseImportNDJSON(r io.Reader) ([]ImportRow, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var rows []ImportRow
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		row := ImportRow{Line: line}
+		var rec importRecord
+		if err := json.Unmarshal([]byte(text), &rec); err != nil {
+			row.Err = fmt.Errorf("invalid json: %v", err)
+		} else {
+			row.Subscription, row.Err = rec.subscription()
+		}
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func (rec importRecord) subscription() (models.Subscription, error) {
+	sub := models.Subscription{
+		ServiceName: rec.ServiceName,
+		Price:       rec.Price,
+		UserID:      rec.UserID,
+	}
+	var err error
+	if sub.StartDate, err = parseImportDate(rec.StartDate); err != nil {
+		return sub, fmt.Errorf("invalid start_date: %w", err)
+	}
+	if rec.EndDate != "" {
+		t, err := parseImportDate(rec.EndDate)
+		if err != nil {
+			return sub, fmt.Errorf("invalid end_date: %w", err)
+		}
+		sub.EndDate = &t
+	}
+	if rec.TrialEndsAt != "" {
+		t, err := parseImportDate(rec.TrialEndsAt)
+		if err != nil {
+			return sub, fmt.Errorf("invalid trial_ends_at: %w", err)
+		}
+		sub.TrialEndsAt = &t
+	}
+	return sub, nil
+}
+
+// parseImportDate accepts both YYYY-MM-DD and RFC 3339 timestamps
+func parseImportDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, errors.New("value is empty")
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
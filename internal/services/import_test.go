@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"subscriptions_service_golang/internal/models"
+	"subscriptions_service_golang/internal/repositories"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type batchRepo struct {
+	repositories.SubscriptionRepository
+	batches [][]models.Subscription
+	failOn  int
+}
+
+func (r *batchRepo) FindOverlapping(ctx context.Context, sub models.Subscription) ([]models.Subscription, error) {
+	if sub.ServiceName == "Existing" {
+		return []models.Subscription{{ID: 7}}, nil
+	}
+	return nil, nil
+}
+
+func (r *batchRepo) CreateBatch(ctx context.Context, subs []models.Subscription) error {
+	if len(r.batches)+1 == r.failOn {
+		r.batches = append(r.batches, nil)
+		return errors.New("db is down")
+	}
+	for i := range subs {
+		subs[i].ID = uint(100*len(r.batches) + i + 1)
+	}
+	r.batches = append(r.batches, subs)
+	return nil
+}
+
+const importUser = "60601fee-2bf1-4721-ae6f-7636e79a0cba"
+
+func TestParseImport(t *testing.T) {
+	t.Run("csv", func(t *testing.T) {
+		rows, err := ParseImportCSV(strings.NewReader(
+			"user_id,service_name,price,start_date,end_date\n" +
+				importUser + ",Netflix,500,2025-07-01,2025-12-31\n" +
+				importUser + ",Spotify,x,2025-07-01,\n"))
+		assert.NoError(t, err)
+		assert.Len(t, rows, 2)
+		assert.NoError(t, rows[0].Err)
+		assert.Equal(t, 2, rows[0].Line)
+		assert.Equal(t, "Netflix", rows[0].Subscription.ServiceName)
+		assert.Equal(t, "2025-12-31", rows[0].Subscription.EndDate.Format("2006-01-02"))
+		assert.Error(t, rows[1].Err)
+	})
+
+	t.Run("csv without required column", func(t *testing.T) {
+		_, err := ParseImportCSV(strings.NewReader("service_name,price\nNetflix,1\n"))
+		assert.Error(t, err)
+	})
+
+	t.Run("ndjson", func(t *testing.T) {
+		rows, err := ParseImportNDJSON(strings.NewReader(
+			`{"service_name":"Netflix","price":500,"user_id":"` + importUser + `","start_date":"2025-07-01T00:00:00Z"}` + "\n\n{oops\n"))
+		assert.NoError(t, err)
+		assert.Len(t, rows, 2)
+		assert.NoError(t, rows[0].Err)
+		assert.Equal(t, 3, rows[1].Line)
+		assert.Error(t, rows[1].Err)
+	})
+}
+
+func TestImport(t *testing.T) {
+	sub := models.Subscription{ServiceName: "Netflix", Price: 500, UserID: importUser, StartDate: date("2025-07-01")}
+	invalid := sub
+	invalid.Price = -1
+	existing := sub
+	existing.ServiceName = "Existing"
+	rows := []ImportRow{
+		{Line: 1, Subscription: sub},
+		{Line: 2, Subscription: sub},
+		{Line: 3, Subscription: invalid},
+		{Line: 4, Err: errors.New("invalid price")},
+		{Line: 5, Subscription: existing},
+	}
+
+	t.Run("dry run", func(t *testing.T) {
+		repo := &batchRepo{}
+		service := &subscriptionService{repo: repo, now: time.Now}
+		report, err := service.Import(context.Background(), rows, ImportOptions{DryRun: true})
+		assert.NoError(t, err)
+		assert.Empty(t, repo.batches)
+		assert.Equal(t, 1, report.Created)
+		assert.Equal(t, 2, report.Skipped)
+		assert.Equal(t, 2, report.Failed)
+		assert.Contains(t, report.Rows[1].Reason, "overlaps line 1")
+		assert.Contains(t, report.Rows[4].Reason, "overlaps subscription 7")
+	})
+
+	t.Run("allow duplicates", func(t *testing.T) {
+		repo := &batchRepo{}
+		service := &subscriptionService{repo: repo, now: time.Now}
+		report, err := service.Import(context.Background(), rows, ImportOptions{AllowDuplicate: true})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, report.Created)
+		assert.False(t, repo.batches[0][0].Duplicate)
+		assert.True(t, repo.batches[0][1].Duplicate)
+		assert.True(t, repo.batches[0][2].Duplicate)
+	})
+
+	t.Run("batches", func(t *testing.T) {
+		var many []ImportRow
+		for i := 0; i < importBatchSize+1; i++ {
+			s := sub
+			s.ServiceName = fmt.Sprintf("Service %d", i)
+			many = append(many, ImportRow{Line: i + 2, Subscription: s})
+		}
+		repo := &batchRepo{failOn: 2}
+		service := &subscriptionService{repo: repo, now: time.Now}
+		report, err := service.Import(context.Background(), many, ImportOptions{})
+		assert.NoError(t, err)
+		assert.Len(t, repo.batches, 2)
+		assert.Equal(t, importBatchSize, report.Created)
+		assert.Equal(t, 1, report.Failed)
+		assert.Equal(t, uint(1), report.Rows[0].ID)
+		assert.Equal(t, models.StatusActive, repo.batches[0][0].Status)
+	})
+}
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+	"time"
+
+	"subscriptions_service_golang/internal/models"
+)
+
+// maxLoginAttempts caps the audit entries LoginAttempts returns
+const maxLoginAttempts = 500
+
+// LoginPolicy limits password guessing. After every failed login of a
+// username the next attempt has to wait Delay, doubled with each failure;
+// MaxFailures failures lock the username for Lockout. An address is locked
+// after MaxIPFailures failures, whatever the usernames. Failures older than
+// Window are forgotten. Zero MaxFailures or MaxIPFailures turn the lockout
+// off, zero Delay the delays.
+type LoginPolicy struct {
+	MaxFailures   int
+	MaxIPFailures int
+	Delay         time.Duration
+	Lockout       time.Duration
+	Window        time.Duration
+}
+
+// LoginLockedError is returned by Login while the username or the address
+// is locked; it wraps ErrRateLimited
+type LoginLockedError struct {
+	Until time.Time
+}
+
+func (e *LoginLockedError) Error() string {
+	return fmt.Sprintf("%s: login is locked until %s", ErrRateLimited, e.Until.UTC().Format(time.RFC3339))
+}
+
+func (e *LoginLockedError) Unwrap() error {
+	return ErrRateLimited
+}
+
+func userLockKey(username string) string {
+	return "user:" + strings.ToLower(strings.TrimSpace(username))
+}
+
+func ipLockKey(ip string) string {
+	return "ip:" + ip
+}
+
+// lockKeys returns the lockout keys of a login; callers without an address
+// (tests, commands) are only tracked by username
+func lockKeys(username, ip string) []string {
+	keys := []string{userLockKey(username)}
+	if ip != "" {
+		keys = append(keys, ipLockKey(ip))
+	}
+	return keys
+}
+
+// reservation is a login counted as a failure of key before its password
+// was checked; locked is the lock that count set, if any
+type reservation struct {
+	key        string
+	locked     *time.Time
+	prevLocked *time.Time
+}
+
+// reserve counts the login as a failed one for every key, and locks what
+// goes over the policy, before the password is checked. Each key is
+// updated under its row lock, so concurrent guesses queue up behind the
+// delay and the threshold instead of all passing a check made before the
+// first failure was recorded. A login that turns out not to have failed
+// gives the count back with release. It returns a LoginLockedError when a
+// key is already locked.
+func (s *authService) reserve(ctx context.Context, now time.Time, username, ip string) ([]reservation, error) {
+	since := now.Add(-s.policy.Window)
+	var taken []reservation
+	for _, key := range lockKeys(username, ip) {
+		r := reservation{key: key}
+		err := s.attempts.UpdateLockout(ctx, key, func(l *models.LoginLockout) error {
+			if l.LockedUntil != nil && l.LockedUntil.After(now) {
+				return &LoginLockedError{Until: *l.LockedUntil}
+			}
+			if l.LastFailureAt.Before(since) {
+				l.Failures = 0
+			}
+			l.Failures++
+			l.LastFailureAt = now
+			if wait := s.penalty(key, l.Failures); wait > 0 {
+				until := now.Add(wait)
+				r.locked, r.prevLocked = &until, l.LockedUntil
+				l.LockedUntil = &until
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, errors.Join(err, s.release(ctx, taken))
+		}
+		taken = append(taken, r)
+	}
+	return taken, nil
+}
+
+// release gives back the failures counted by reserve. A lock is lifted only
+// if it is still the one the reservation set.
+func (s *authService) release(ctx context.Context, taken []reservation) error {
+	var errs []error
+	for _, r := range taken {
+		errs = append(errs, s.attempts.UpdateLockout(ctx, r.key, func(l *models.LoginLockout) error {
+			l.Failures = max(l.Failures-1, 0)
+			if r.locked != nil && l.LockedUntil != nil && l.LockedUntil.Equal(*r.locked) {
+				l.LockedUntil = r.prevLocked
+			}
+			return nil
+		}))
+	}
+	return errors.Join(errs...)
+}
+
+// penalty returns how long key is locked after its failures-th failure
+func (s *authService) penalty(key string, failures int) time.Duration {
+	p := s.policy
+	if strings.HasPrefix(key, "ip:") {
+		if p.MaxIPFailures > 0 && failures >= p.MaxIPFailures {
+			return p.Lockout
+		}
+		return 0
+	}
+	if p.MaxFailures > 0 && failures >= p.MaxFailures {
+		return p.Lockout
+	}
+	if p.Delay <= 0 {
+		return 0
+	}
+	delay := p.Delay
+	for i := 1; i < failures && delay < p.Lockout; i++ {
+		delay *= 2
+	}
+	if p.Lockout > 0 {
+		delay = min(delay, p.Lockout)
+	}
+	return delay
+}
+
+// audit records a login attempt in the audit log. The username is cut to
+// the characters the column holds, never inside a UTF-8 sequence.
+func (s *authService) audit(ctx context.Context, now time.Time, username, ip string, result models.LoginResult) error {
+	username = strings.ToValidUTF8(username, "\uFFFD")
+	if utf8.RuneCountInString(username) > maxUsernameLength {
+		username = string([]rune(username)[:maxUsernameLength])
+	}
+	return s.attempts.Record(ctx, &models.LoginAttempt{Username: username, IP: ip, Result: result, CreatedAt: now})
+}
+
+// LoginAttempts kirish urinishlari jurnalini, eng yangisidan boshlab qaytaradi
+func (s *authService) LoginAttempts(ctx context.Context, filter models.LoginAttemptFilter) ([]models.LoginAttempt, error) {
+	if filter.Limit <= 0 || filter.Limit > maxLoginAttempts {
+		filter.Limit = maxLoginAttempts
+	}
+	return s.attempts.List(ctx, filter)
+}
+
+// Lockouts hozir bloklangan foydalanuvchi nomlari va manzillarni qaytaradi
+func (s *authService) Lockouts(ctx context.Context) ([]models.LoginLockout, error) {
+	return s.attempts.ListLocked(ctx, s.now().UTC())
+}
+
+// ClearLockout foydalanuvchi nomi yoki manzil blokini olib tashlaydi va
+// muvaffaqiyatsiz urinishlar hisobini nolga tushiradi
+func (s *authService) ClearLockout(ctx context.Context, username, ip string) error {
+	var keys []string
+	if strings.TrimSpace(username) != "" {
+		keys = append(keys, userLockKey(username))
+	}
+	if ip != "" {
+		keys = append(keys, ipLockKey(ip))
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("%w: username or ip is required", ErrValidation)
+	}
+	cleared := 0
+	for _, key := range keys {
+		err := s.attempts.ClearLockout(ctx, key)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		cleared++
+	}
+	if cleared == 0 {
+		return fmt.Errorf("%w: no failed logins recorded", ErrNotFound)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"subscriptions_service_golang/internal/models"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// metricsService counts SubscriptionService calls by operation and result
+type metricsService struct {
+	next       SubscriptionService
+	operations *prometheus.CounterVec
+}
+
+// NewMetricsService next ni o‘rab, har bir metod chaqiruvini sanaydi
+func NewMetricsService(next SubscriptionService, reg prometheus.Registerer) SubscriptionService {
+	operations := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "subscription_operations_total",
+		Help: "Number of subscription service calls by operation and result.",
+	}, []string{"operation", "result"})
+	reg.MustRegister(operations)
+	return &metricsService{next: next, operations: operations}
+}
+
+// observe records one call; the result label is the error class, not the
+// message, so the number of series stays bounded
+func (s *metricsService) observe(operation string, err error) {
+	s.operations.WithLabelValues(operation, resultLabel(err)).Inc()
+}
+
+func resultLabel(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, ErrValidation):
+		return "validation"
+	case errors.Is(err, ErrNotFound):
+		return "not_found"
+	case errors.Is(err, ErrConflict):
+		return "conflict"
+	default:
+		return "error"
+	}
+}
+
+func (s *metricsService) Create(ctx context.Context, sub models.Subscription, allowDuplicate bool) (*models.Subscription, error) {
+	res, err := s.next.Create(ctx, sub, allowDuplicate)
+	s.observe("create", err)
+	return res, err
+}
+
+func (s *metricsService) GetByID(ctx context.Context, id uint) (*models.Subscription, error) {
+	res, err := s.next.GetByID(ctx, id)
+	s.observe("get", err)
+	return res, err
+}
+
+func (s *metricsService) List(ctx context.Context, filter models.SubscriptionFilter) ([]models.Subscription, error) {
+	res, err := s.next.List(ctx, filter)
+	s.observe("list", err)
+	return res, err
+}
+
+func (s *metricsService) Update(ctx context.Context, sub models.Subscription) (*models.Subscription, error) {
+	res, err := s.next.Update(ctx, sub)
+	s.observe("update", err)
+	return res, err
+}
+
+func (s *metricsService) Delete(ctx context.Context, id uint) error {
+	err := s.next.Delete(ctx, id)
+	s.observe("delete", err)
+	return err
+}
+
+func (s *metricsService) TotalPrice(ctx context.Context, userID string, serviceName string, from, to *time.Time) (int, error) {
+	res, err := s.next.TotalPrice(ctx, userID, serviceName, from, to)
+	s.observe("total_price", err)
+	return res, err
+}
+
+func (s *metricsService) Pause(ctx context.Context, id uint) (*models.Subscription, error) {
+	res, err := s.next.Pause(ctx, id)
+	s.observe("pause", err)
+	return res, err
+}
+
+func (s *metricsService) Resume(ctx context.Context, id uint) (*models.Subscription, error) {
+	res, err := s.next.Resume(ctx, id)
+	s.observe("resume", err)
+	return res, err
+}
+
+func (s *metricsService) Cancel(ctx context.Context, id uint, effectiveDate time.Time) (*models.Subscription, error) {
+	res, err := s.next.Cancel(ctx, id, effectiveDate)
+	s.observe("cancel", err)
+	return res, err
+}
+
+func (s *metricsService) ExpireDue(ctx context.Context, now time.Time) ([]models.Subscription, error) {
+	res, err := s.next.ExpireDue(ctx, now)
+	s.observe("expire", err)
+	return res, err
+}
+
+func (s *metricsService) Import(ctx context.Context, rows []ImportRow, opts ImportOptions) (*ImportReport, error) {
+	res, err := s.next.Import(ctx, rows, opts)
+	s.observe("import", err)
+	return res, err
+}
+
+func (s *metricsService) Export(ctx context.Context, filter models.SubscriptionFilter, format string, w io.Writer) error {
+	err := s.next.Export(ctx, filter, format, w)
+	s.observe("export", err)
+	return err
+}
+
+func (s *metricsService) Bulk(ctx context.Context, req BulkRequest) (*BulkReport, error) {
+	res, err := s.next.Bulk(ctx, req)
+	s.observe("bulk", err)
+	return res, err
+}
+
+func (s *metricsService) FindDuplicates(ctx context.Context, userID string) ([]DuplicateGroup, error) {
+	res, err := s.next.FindDuplicates(ctx, userID)
+	s.observe("find_duplicates", err)
+	return res, err
+}
+
+func (s *metricsService) Stats(ctx context.Context) (models.SubscriptionStats, error) {
+	res, err := s.next.Stats(ctx)
+	s.observe("stats", err)
+	return res, err
+}
+
+// statsCollector reports business gauges, queried from the service on every
+// scrape. timeout bounds the query, zero disables it like query_timeout.
+type statsCollector struct {
+	service      SubscriptionService
+	timeout      time.Duration
+	active       *prometheus.Desc
+	monthlySpend *prometheus.Desc
+}
+
+// NewStatsCollector faol subscriptionlar soni va oylik xarajatni Prometheus ga beradi
+func NewStatsCollector(service SubscriptionService, timeout time.Duration) prometheus.Collector {
+	return &statsCollector{
+		service:      service,
+		timeout:      timeout,
+		active:       prometheus.NewDesc("subscriptions_active", "Number of trial and active subscriptions that have not ended.", nil, nil),
+		monthlySpend: prometheus.NewDesc("subscriptions_monthly_spend", "Sum of monthly prices of subscriptions billed right now.", nil, nil),
+	}
+}
+
+func (c *statsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.active
+	ch <- c.monthlySpend
+}
+
+func (c *statsCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx := context.Background()
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	stats, err := c.service.Stats(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.active, err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.active, prometheus.GaugeValue, float64(stats.Active))
+	ch <- prometheus.MustNewConstMetric(c.monthlySpend, prometheus.GaugeValue, float64(stats.MonthlySpend))
+}
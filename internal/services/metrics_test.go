@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"subscriptions_service_golang/internal/models"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// statsRepo returns fixed stats and remembers the day it was asked about
+type statsRepo struct {
+	mapRepo
+	on time.Time
+}
+
+func (r *statsRepo) Stats(ctx context.Context, on time.Time) (models.SubscriptionStats, error) {
+	if err := ctx.Err(); err != nil {
+		return models.SubscriptionStats{}, err
+	}
+	r.on = on
+	return models.SubscriptionStats{Active: 3, MonthlySpend: 1500}, nil
+}
+
+func TestMetricsService(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	existing := models.Subscription{ID: 1, ServiceName: "Netflix", Price: 500, UserID: importUser, StartDate: date("2025-01-01")}
+	service := NewMetricsService(&subscriptionService{repo: newMapRepo(existing), now: time.Now}, reg)
+
+	service.GetByID(context.Background(), 1)
+	service.GetByID(context.Background(), 2)
+	service.Create(context.Background(), models.Subscription{}, false)
+
+	ops := service.(*metricsService).operations
+	assert.Equal(t, 1.0, testutil.ToFloat64(ops.WithLabelValues("get", "ok")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(ops.WithLabelValues("get", "not_found")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(ops.WithLabelValues("create", "validation")))
+}
+
+func TestStatsCollector(t *testing.T) {
+	repo := &statsRepo{mapRepo: *newMapRepo()}
+	now := time.Date(2025, 3, 15, 18, 30, 0, 0, time.UTC)
+	service := &subscriptionService{repo: repo, now: func() time.Time { return now }}
+
+	expected := `
+# HELP subscriptions_active Number of trial and active subscriptions that have not ended.
+# TYPE subscriptions_active gauge
+subscriptions_active 3
+# HELP subscriptions_monthly_spend Sum of monthly prices of subscriptions billed right now.
+# TYPE subscriptions_monthly_spend gauge
+subscriptions_monthly_spend 1500
+`
+	assert.NoError(t, testutil.CollectAndCompare(NewStatsCollector(service, time.Second), strings.NewReader(expected)))
+	assert.Equal(t, date("2025-03-15"), repo.on)
+
+	// query_timeout: 0 disables the timeout instead of expiring every scrape
+	assert.NoError(t, testutil.CollectAndCompare(NewStatsCollector(service, 0), strings.NewReader(expected)))
+}
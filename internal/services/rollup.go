@@ -0,0 +1,173 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"subscriptions_service_golang/internal/models"
+	"subscriptions_service_golang/internal/repositories"
+)
+
+// rollupMonthsAhead is how many months past the current one monthly_spend
+// is filled for, so that totals reaching into the near future are served
+// from it as well.
+const rollupMonthsAhead = 12
+
+// rollupBatchSize is how many subscriptions Rebuild handles per transaction.
+const rollupBatchSize = 500
+
+// rollupHorizon returns the last month monthly_spend is filled for at now.
+func rollupHorizon(now time.Time) time.Time {
+	return monthStart(now).AddDate(0, rollupMonthsAhead, 0)
+}
+
+// monthlySpendRows returns the monthly_spend rows of sub up to and including
+// the month of through, one per billable month.
+func monthlySpendRows(sub models.Subscription, pauses []models.SubscriptionPause, through time.Time) []models.MonthlySpend {
+	months := billableMonths(sub, pauses, time.Time{}, through)
+	rows := make([]models.MonthlySpend, 0, len(months))
+	for _, m := range months {
+		rows = append(rows, models.MonthlySpend{
+			SubscriptionID: sub.ID,
+			Month:          m,
+			UserID:         sub.UserID,
+			ServiceName:    sub.ServiceName,
+			Amount:         sub.Price,
+		})
+	}
+	return rows
+}
+
+// refreshMonthlySpend recomputes the monthly_spend rows of subs in repo.
+func refreshMonthlySpend(ctx context.Context, repo repositories.SubscriptionRepository, through time.Time, subs ...models.Subscription) error {
+	ids := make([]uint, 0, len(subs))
+	for _, sub := range subs {
+		ids = append(ids, sub.ID)
+	}
+	pauses, err := repo.ListPauses(ctx, ids)
+	if err != nil {
+		return err
+	}
+	bySub := make(map[uint][]models.SubscriptionPause)
+	for _, p := range pauses {
+		bySub[p.SubscriptionID] = append(bySub[p.SubscriptionID], p)
+	}
+	for _, sub := range subs {
+		if err := repo.ReplaceMonthlySpend(ctx, sub.ID, monthlySpendRows(sub, bySub[sub.ID], through)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// monthlySpendRepo keeps monthly_spend in step with the subscriptions: every
+// write recomputes the rows of the subscriptions it touches in the same
+// transaction. Expire is passed through, billing does not look at status.
+type monthlySpendRepo struct {
+	repositories.SubscriptionRepository
+	now func() time.Time
+}
+
+func newMonthlySpendRepo(repo repositories.SubscriptionRepository, now func() time.Time) *monthlySpendRepo {
+	return &monthlySpendRepo{SubscriptionRepository: repo, now: now}
+}
+
+func (r *monthlySpendRepo) Create(ctx context.Context, sub *models.Subscription) error {
+	return r.SubscriptionRepository.Transaction(ctx, func(repo repositories.SubscriptionRepository) error {
+		if err := repo.Create(ctx, sub); err != nil {
+			return err
+		}
+		return refreshMonthlySpend(ctx, repo, rollupHorizon(r.now()), *sub)
+	})
+}
+
+func (r *monthlySpendRepo) CreateBatch(ctx context.Context, subs []models.Subscription) error {
+	return r.SubscriptionRepository.Transaction(ctx, func(repo repositories.SubscriptionRepository) error {
+		if err := repo.CreateBatch(ctx, subs); err != nil {
+			return err
+		}
+		return refreshMonthlySpend(ctx, repo, rollupHorizon(r.now()), subs...)
+	})
+}
+
+func (r *monthlySpendRepo) Update(ctx context.Context, sub *models.Subscription) error {
+	return r.SubscriptionRepository.Transaction(ctx, func(repo repositories.SubscriptionRepository) error {
+		if err := repo.Update(ctx, sub); err != nil {
+			return err
+		}
+		return refreshMonthlySpend(ctx, repo, rollupHorizon(r.now()), *sub)
+	})
+}
+
+func (r *monthlySpendRepo) SavePause(ctx context.Context, sub *models.Subscription, pause *models.SubscriptionPause) error {
+	return r.SubscriptionRepository.Transaction(ctx, func(repo repositories.SubscriptionRepository) error {
+		if err := repo.SavePause(ctx, sub, pause); err != nil {
+			return err
+		}
+		return refreshMonthlySpend(ctx, repo, rollupHorizon(r.now()), *sub)
+	})
+}
+
+func (r *monthlySpendRepo) Delete(ctx context.Context, id uint) error {
+	return r.SubscriptionRepository.Transaction(ctx, func(repo repositories.SubscriptionRepository) error {
+		if err := repo.ReplaceMonthlySpend(ctx, id, nil); err != nil {
+			return err
+		}
+		return repo.Delete(ctx, id)
+	})
+}
+
+func (r *monthlySpendRepo) Transaction(ctx context.Context, fn func(repo repositories.SubscriptionRepository) error) error {
+	return r.SubscriptionRepository.Transaction(ctx, func(repo repositories.SubscriptionRepository) error {
+		return fn(newMonthlySpendRepo(repo, r.now))
+	})
+}
+
+// MonthlySpendRollup rebuilds the monthly_spend table from the
+// subscriptions, after it was created or once the months kept ahead run
+// short.
+type MonthlySpendRollup struct {
+	repo repositories.SubscriptionRepository
+	now  func() time.Time
+}
+
+// NewMonthlySpendRollup monthly_spend jadvalini qayta quruvchi yaratadi
+func NewMonthlySpendRollup(repo repositories.SubscriptionRepository) *MonthlySpendRollup {
+	return &MonthlySpendRollup{repo: repo, now: time.Now}
+}
+
+// Due jadval hali qurilmagan bo‘lsa yoki oldindagi oylar rollupMonthsAhead
+// dan kam qolgan bo‘lsa true qaytaradi
+func (r *MonthlySpendRollup) Due(ctx context.Context) (bool, error) {
+	through, err := r.repo.MonthlySpendThrough(ctx)
+	if err != nil {
+		return false, err
+	}
+	return through.Before(rollupHorizon(r.now())), nil
+}
+
+// Rebuild har bir subscription uchun qatorlarni qaytadan hisoblaydi va
+// nechta subscription qayta hisoblanganini qaytaradi. Jadval faqat oxirida,
+// hamma qatorlar yozilgandan keyin ishlatila boshlanadi.
+func (r *MonthlySpendRollup) Rebuild(ctx context.Context) (int, error) {
+	through := rollupHorizon(r.now())
+	// List, not Iterate: writing while a cursor is open needs a second
+	// connection, which SQLite does not have
+	subs, err := r.repo.List(ctx, models.SubscriptionFilter{})
+	if err != nil {
+		return 0, err
+	}
+	for start := 0; start < len(subs); start += rollupBatchSize {
+		batch := subs[start:min(start+rollupBatchSize, len(subs))]
+		err := r.repo.Transaction(ctx, func(repo repositories.SubscriptionRepository) error {
+			return refreshMonthlySpend(ctx, repo, through, batch...)
+		})
+		if err != nil {
+			return start, err
+		}
+	}
+	if err := r.repo.SetMonthlySpendThrough(ctx, through); err != nil {
+		return len(subs), err
+	}
+	return len(subs), nil
+}
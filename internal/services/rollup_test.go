@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"subscriptions_service_golang/internal/models"
+	"subscriptions_service_golang/internal/repositories"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMonthlySpendRollup(t *testing.T) {
+	ctx := context.Background()
+	today := date("2025-06-15")
+	now := func() time.Time { return today }
+	repo := repositories.NewMemorySubscriptionRepository()
+	service := &subscriptionService{repo: newMonthlySpendRepo(repo, now), now: now, rollup: true}
+	// replays the billing rules over the subscriptions, the reference
+	plain := &subscriptionService{repo: repo, now: now}
+	rollup := &MonthlySpendRollup{repo: repo, now: now}
+
+	// rows written before the first rebuild are kept but not read
+	netflix, err := service.Create(ctx, models.Subscription{ServiceName: "Netflix", Price: 500, UserID: importUser, StartDate: date("2025-01-01")}, false)
+	require.NoError(t, err)
+	due, err := rollup.Due(ctx)
+	require.NoError(t, err)
+	assert.True(t, due)
+
+	count, err := rollup.Rebuild(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	due, err = rollup.Due(ctx)
+	require.NoError(t, err)
+	assert.False(t, due)
+
+	trialEnd := date("2025-08-01")
+	spotify, err := service.Create(ctx, models.Subscription{ServiceName: "Spotify", Price: 300, UserID: importUser, StartDate: date("2025-06-01"), TrialEndsAt: &trialEnd}, false)
+	require.NoError(t, err)
+	other, err := service.Create(ctx, models.Subscription{ServiceName: "Netflix", Price: 700, UserID: otherUser, StartDate: date("2025-03-10")}, false)
+	require.NoError(t, err)
+
+	ranges := []struct{ from, to string }{
+		{"2025-01-01", "2025-12-31"},
+		{"2025-03-15", "2025-03-20"},
+		{"2025-06-01", "2026-06-30"},
+		// past the months kept ahead, served by replaying the rules
+		{"2025-01-01", "2027-01-31"},
+	}
+	check := func(t *testing.T) {
+		t.Helper()
+		for _, r := range ranges {
+			from, to := date(r.from), date(r.to)
+			for _, user := range []string{importUser, otherUser, ""} {
+				want, err := plain.TotalPrice(ctx, user, "", &from, &to)
+				require.NoError(t, err)
+				got, err := service.TotalPrice(ctx, user, "", &from, &to)
+				require.NoError(t, err)
+				assert.Equal(t, want, got, "%s %s..%s", user, r.from, r.to)
+			}
+		}
+	}
+	check(t)
+
+	from, to := date("2025-01-01"), date("2025-12-31")
+	total, err := service.TotalPrice(ctx, importUser, "", &from, &to)
+	require.NoError(t, err)
+	assert.Equal(t, 12*500+5*300, total)
+	spent, err := repo.SumMonthlySpend(ctx, models.SubscriptionFilter{UserID: importUser, From: &from, To: &to})
+	require.NoError(t, err)
+	assert.Equal(t, int64(total), spent)
+
+	// every kind of change keeps the rollup in step
+	netflix.Price = 600
+	_, err = service.Update(ctx, *netflix)
+	require.NoError(t, err)
+	check(t)
+
+	today = date("2025-09-10")
+	_, err = service.Pause(ctx, spotify.ID)
+	require.NoError(t, err)
+	check(t)
+	today = date("2025-11-20")
+	_, err = service.Resume(ctx, spotify.ID)
+	require.NoError(t, err)
+	check(t)
+	_, err = service.Cancel(ctx, netflix.ID, date("2025-10-31"))
+	require.NoError(t, err)
+	check(t)
+
+	total, err = service.TotalPrice(ctx, importUser, "", &from, &to)
+	require.NoError(t, err)
+	// Spotify is billed in August, September and December only
+	assert.Equal(t, 10*600+3*300, total)
+
+	// in November only the other user's Netflix is billed
+	stats, err := service.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(700), stats.MonthlySpend)
+
+	require.NoError(t, service.Delete(ctx, other.ID))
+	check(t)
+	total, err = service.TotalPrice(ctx, otherUser, "", &from, &to)
+	require.NoError(t, err)
+	assert.Zero(t, total)
+}
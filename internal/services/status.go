@@ -0,0 +1,44 @@
+package services
+
+import (
+	"time"
+
+	"subscriptions_service_golang/internal/models"
+)
+
+// transitions lists the statuses reachable from each status.
+// Cancelled and expired subscriptions are final.
+var transitions = map[models.SubscriptionStatus][]models.SubscriptionStatus{
+	models.StatusTrial:  {models.StatusActive, models.StatusCancelled, models.StatusExpired},
+	models.StatusActive: {models.StatusPaused, models.StatusCancelled, models.StatusExpired},
+	models.StatusPaused: {models.StatusActive, models.StatusCancelled, models.StatusExpired},
+}
+
+func canTransition(from, to models.SubscriptionStatus) bool {
+	for _, s := range transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// initialStatus is the status a new subscription starts in.
+func initialStatus(sub models.Subscription, now time.Time) models.SubscriptionStatus {
+	if sub.TrialEndsAt != nil && sub.TrialEndsAt.After(now) {
+		return models.StatusTrial
+	}
+	return models.StatusActive
+}
+
+// currentStatus returns the stored status, treating a trial whose
+// trial_ends_at has passed as active.
+func currentStatus(sub models.Subscription, now time.Time) models.SubscriptionStatus {
+	if sub.Status == "" {
+		return models.StatusActive
+	}
+	if sub.Status == models.StatusTrial && (sub.TrialEndsAt == nil || !sub.TrialEndsAt.After(now)) {
+		return models.StatusActive
+	}
+	return sub.Status
+}
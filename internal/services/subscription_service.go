@@ -1,75 +1,376 @@
 package services
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"subscriptions_service_golang/internal/models"
 	"subscriptions_service_golang/internal/repositories"
 	"time"
 )
 
 type SubscriptionService interface {
-	Create(sub models.Subscription) (*models.Subscription, error)
-	GetByID(id uint) (*models.Subscription, error)
-	List(userID string, serviceName string, from, to *time.Time) ([]models.Subscription, error)
-	Update(sub models.Subscription) (*models.Subscription, error)
-	Delete(id uint) error
-	TotalPrice(userID string, serviceName string, from, to *time.Time) (int, error)
+	Create(ctx context.Context, sub models.Subscription, allowDuplicate bool) (*models.Subscription, error)
+	GetByID(ctx context.Context, id uint) (*models.Subscription, error)
+	List(ctx context.Context, filter models.SubscriptionFilter) ([]models.Subscription, error)
+	Update(ctx context.Context, sub models.Subscription) (*models.Subscription, error)
+	Delete(ctx context.Context, id uint) error
+	TotalPrice(ctx context.Context, userID string, serviceName string, from, to *time.Time) (int, error)
+	Pause(ctx context.Context, id uint) (*models.Subscription, error)
+	Resume(ctx context.Context, id uint) (*models.Subscription, error)
+	Cancel(ctx context.Context, id uint, effectiveDate time.Time) (*models.Subscription, error)
+	ExpireDue(ctx context.Context, now time.Time) ([]models.Subscription, error)
+	Import(ctx context.Context, rows []ImportRow, opts ImportOptions) (*ImportReport, error)
+	Export(ctx context.Context, filter models.SubscriptionFilter, format string, w io.Writer) error
+	Bulk(ctx context.Context, req BulkRequest) (*BulkReport, error)
+	FindDuplicates(ctx context.Context, userID string) ([]DuplicateGroup, error)
+	Stats(ctx context.Context) (models.SubscriptionStats, error)
 }
 
 type subscriptionService struct {
 	repo repositories.SubscriptionRepository
+	now  func() time.Time
+	// rollup is set when repo keeps monthly_spend up to date, see
+	// monthlySpendRepo; only then totals may be read from it
+	rollup bool
 }
 
+// NewSubscriptionService servis yaratadi. Har bir o‘zgarish monthly_spend
+// jadvalida ham shu tranzaksiyada aks etadi.
 func NewSubscriptionService(repo repositories.SubscriptionRepository) SubscriptionService {
-	return &subscriptionService{repo: repo}
+	return &subscriptionService{repo: newMonthlySpendRepo(repo, time.Now), now: time.Now, rollup: true}
 }
 
-// Create yangi subscription yaratadi
-func (s *subscriptionService) Create(sub models.Subscription) (*models.Subscription, error) {
-	if err := s.repo.Create(&sub); err != nil {
+// Create yangi subscription yaratadi. Xuddi shu servisga vaqti ustma-ust
+// tushadigan subscription bo‘lsa ErrDuplicate qaytaradi, allowDuplicate
+// bo‘lsa esa saqlaydi, lekin Duplicate deb belgilaydi.
+func (s *subscriptionService) Create(ctx context.Context, sub models.Subscription, allowDuplicate bool) (*models.Subscription, error) {
+	if err := s.prepare(&sub); err != nil {
+		return nil, err
+	}
+	if err := s.checkDuplicate(ctx, &sub, allowDuplicate); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Create(ctx, &sub); err != nil {
 		return nil, err
 	}
 	return &sub, nil
 }
 
+// prepare yangi subscriptionni tekshiradi va boshlang‘ich statusini belgilaydi
+func (s *subscriptionService) prepare(sub *models.Subscription) error {
+	if err := validateSubscription(*sub); err != nil {
+		return err
+	}
+	sub.ID = 0
+	sub.Status = initialStatus(*sub, s.now())
+	sub.CancelledAt = nil
+	sub.Duplicate = false
+	return nil
+}
+
 // GetByID subscriptionni ID bo‘yicha qaytaradi
-func (s *subscriptionService) GetByID(id uint) (*models.Subscription, error) {
-	return s.repo.GetByID(id)
+func (s *subscriptionService) GetByID(ctx context.Context, id uint) (*models.Subscription, error) {
+	return s.repo.GetByID(ctx, id)
 }
 
 // List subscriptionlarni filter bilan qaytaradi
-func (s *subscriptionService) List(userID string, serviceName string, from, to *time.Time) ([]models.Subscription, error) {
-	filter := make(map[string]interface{})
-	if userID != "" {
-		filter["user_id"] = userID
-	}
-	if serviceName != "" {
-		filter["service_name"] = serviceName
-	}
-	// vaqt filterini repositoryda ishlatish mumkin
-	return s.repo.List(filter)
+func (s *subscriptionService) List(ctx context.Context, filter models.SubscriptionFilter) ([]models.Subscription, error) {
+	return s.repo.List(ctx, filter)
 }
 
-// Update subscriptionni yangilaydi
-func (s *subscriptionService) Update(sub models.Subscription) (*models.Subscription, error) {
-	if err := s.repo.Update(&sub); err != nil {
+// Update subscriptionni yangilaydi, statusni esa faqat Pause/Resume/Cancel o‘zgartiradi
+func (s *subscriptionService) Update(ctx context.Context, sub models.Subscription) (*models.Subscription, error) {
+	existing, err := s.repo.GetByID(ctx, sub.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateSubscription(sub); err != nil {
+		return nil, err
+	}
+	sub.CreatedAt = existing.CreatedAt
+	sub.Status = existing.Status
+	sub.CancelledAt = existing.CancelledAt
+	sub.Duplicate = existing.Duplicate
+	if err := s.repo.Update(ctx, &sub); err != nil {
 		return nil, err
 	}
 	return &sub, nil
 }
 
 // Delete subscriptionni o‘chiradi
-func (s *subscriptionService) Delete(id uint) error {
-	return s.repo.Delete(id)
+func (s *subscriptionService) Delete(ctx context.Context, id uint) error {
+	return s.repo.Delete(ctx, id)
 }
 
-// TotalPrice — foydalanuvchi va davr bo‘yicha umumiy narxni hisoblaydi
-func (s *subscriptionService) TotalPrice(userID string, serviceName string, from, to *time.Time) (int, error) {
-	fromStr, toStr := "", ""
+// TotalPrice — foydalanuvchi va davr bo‘yicha umumiy narxni hisoblaydi.
+// Har bir oy uchun narx olinadi, trial va pauza davridagi oylar hisoblanmaydi.
+func (s *subscriptionService) TotalPrice(ctx context.Context, userID string, serviceName string, from, to *time.Time) (int, error) {
+	rangeTo := s.now()
+	if to != nil {
+		rangeTo = *to
+	}
+	rangeTo = monthEnd(rangeTo)
+
+	filter := models.SubscriptionFilter{UserID: userID, ServiceName: serviceName, To: &rangeTo}
+	var rangeFrom time.Time
 	if from != nil {
-		fromStr = from.Format("2006-01-02")
+		rangeFrom = monthStart(*from)
+		filter.From = &rangeFrom
 	}
-	if to != nil {
-		toStr = to.Format("2006-01-02")
+
+	// hisob butun oylar bo‘yicha, oraliq ham oylarga tekislangan, shuning
+	// uchun monthly_spend yetadigan bo‘lsa yig‘indi o‘shandan olinadi
+	covered, err := s.rollupCovers(ctx, rangeTo)
+	if err != nil {
+		return 0, err
+	}
+	if covered {
+		total, err := s.repo.SumMonthlySpend(ctx, filter)
+		return int(total), err
+	}
+
+	subs, err := s.repo.List(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	ids := make([]uint, 0, len(subs))
+	for _, sub := range subs {
+		ids = append(ids, sub.ID)
+	}
+	pauses, err := s.repo.ListPauses(ctx, ids)
+	if err != nil {
+		return 0, err
+	}
+	bySub := make(map[uint][]models.SubscriptionPause)
+	for _, p := range pauses {
+		bySub[p.SubscriptionID] = append(bySub[p.SubscriptionID], p)
+	}
+
+	total := 0
+	for _, sub := range subs {
+		total += subscriptionCost(sub, bySub[sub.ID], rangeFrom, rangeTo)
+	}
+	return total, nil
+}
+
+// Pause subscriptionni vaqtincha to‘xtatadi
+func (s *subscriptionService) Pause(ctx context.Context, id uint) (*models.Subscription, error) {
+	sub, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	if err := checkTransition(*sub, models.StatusPaused, now); err != nil {
+		return nil, err
+	}
+	sub.Status = models.StatusPaused
+	pause := &models.SubscriptionPause{SubscriptionID: sub.ID, PausedAt: now}
+	if err := s.repo.SavePause(ctx, sub, pause); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// Resume to‘xtatilgan subscriptionni qayta faollashtiradi
+func (s *subscriptionService) Resume(ctx context.Context, id uint) (*models.Subscription, error) {
+	sub, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	// trial -> active is a valid transition, but only a pause can be resumed
+	if from := currentStatus(*sub, now); from != models.StatusPaused {
+		return nil, fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, from, models.StatusActive)
+	}
+	pause, err := s.repo.GetOpenPause(ctx, sub.ID)
+	if err != nil {
+		return nil, err
+	}
+	pause.ResumedAt = &now
+	sub.Status = models.StatusActive
+	if err := s.repo.SavePause(ctx, sub, pause); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// Cancel subscriptionni effectiveDate sanasidan boshlab bekor qiladi
+func (s *subscriptionService) Cancel(ctx context.Context, id uint, effectiveDate time.Time) (*models.Subscription, error) {
+	sub, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	if err := checkTransition(*sub, models.StatusCancelled, now); err != nil {
+		return nil, err
+	}
+	if effectiveDate.Before(sub.StartDate) {
+		return nil, fmt.Errorf("%w: effective date is before start date", ErrInvalidTransition)
+	}
+	wasPaused := currentStatus(*sub, now) == models.StatusPaused
+	if sub.EndDate == nil || effectiveDate.Before(*sub.EndDate) {
+		sub.EndDate = &effectiveDate
+	}
+	sub.Status = models.StatusCancelled
+	sub.CancelledAt = &now
+	if wasPaused {
+		// pauza cancel sanasida yopiladi, aks holda hisobda ochiq qolib ketadi
+		pause, err := s.repo.GetOpenPause(ctx, sub.ID)
+		if err != nil {
+			return nil, err
+		}
+		pause.ResumedAt = &effectiveDate
+		if err := s.repo.SavePause(ctx, sub, pause); err != nil {
+			return nil, err
+		}
+		return sub, nil
+	}
+	if err := s.repo.Update(ctx, sub); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// Stats bugungi faol subscriptionlar soni va oylik xarajatni qaytaradi.
+// monthly_spend joriy oyni qamrasa, oylik xarajat undan, ya'ni billing
+// qoidalari bo‘yicha olinadi.
+func (s *subscriptionService) Stats(ctx context.Context) (models.SubscriptionStats, error) {
+	now := s.now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	stats, err := s.repo.Stats(ctx, today)
+	if err != nil {
+		return stats, err
+	}
+	covered, err := s.rollupCovers(ctx, today)
+	if err != nil || !covered {
+		return stats, err
+	}
+	month := monthStart(today)
+	stats.MonthlySpend, err = s.repo.SumMonthlySpend(ctx, models.SubscriptionFilter{From: &month, To: &month})
+	return stats, err
+}
+
+// rollupCovers monthly_spend to oyigacha to‘liq bo‘lsa true qaytaradi
+func (s *subscriptionService) rollupCovers(ctx context.Context, to time.Time) (bool, error) {
+	if !s.rollup {
+		return false, nil
+	}
+	through, err := s.repo.MonthlySpendThrough(ctx)
+	if err != nil {
+		return false, err
+	}
+	return !through.IsZero() && !monthStart(to).After(through), nil
+}
+
+// ExpireDue end_date o‘tib ketgan subscriptionlarni expired statusiga o‘tkazadi
+func (s *subscriptionService) ExpireDue(ctx context.Context, now time.Time) ([]models.Subscription, error) {
+	var statuses []models.SubscriptionStatus
+	for from := range transitions {
+		if canTransition(from, models.StatusExpired) {
+			statuses = append(statuses, from)
+		}
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return s.repo.Expire(ctx, statuses, today)
+}
+
+// Import fayldan o‘qilgan qatorlarni Create qoidalari bilan tekshirib,
+// importBatchSize tadan bitta tranzaksiyada saqlaydi. Bazadagi yoki fayldagi
+// boshqa qator bilan ustma-ust tushgan qatorlar skipped bo‘ladi.
+// DryRun bo‘lsa hech narsa saqlanmaydi, faqat hisobot qaytadi.
+func (s *subscriptionService) Import(ctx context.Context, rows []ImportRow, opts ImportOptions) (*ImportReport, error) {
+	report := &ImportReport{DryRun: opts.DryRun, Rows: make([]ImportResult, len(rows))}
+	var pending []int
+
+	for i, row := range rows {
+		result := ImportResult{Line: row.Line}
+		sub := row.Subscription
+		if row.Err == nil {
+			row.Err = s.prepare(&sub)
+		}
+		if row.Err == nil {
+			row.Err = s.checkDuplicate(ctx, &sub, opts.AllowDuplicate)
+		}
+		if row.Err == nil {
+			for _, j := range pending {
+				if overlaps(sub, rows[j].Subscription) {
+					if !opts.AllowDuplicate {
+						row.Err = fmt.Errorf("%w: overlaps line %d", ErrDuplicate, rows[j].Line)
+						break
+					}
+					sub.Duplicate = true
+				}
+			}
+		}
+		switch {
+		case errors.Is(row.Err, ErrDuplicate):
+			result.Status = ImportSkipped
+			result.Reason = row.Err.Error()
+		case row.Err != nil:
+			result.Status = ImportFailed
+			result.Reason = row.Err.Error()
+		default:
+			result.Status = ImportCreated
+			rows[i].Subscription = sub
+			pending = append(pending, i)
+		}
+		report.Rows[i] = result
+	}
+
+	if !opts.DryRun {
+		for start := 0; start < len(pending); start += importBatchSize {
+			end := min(start+importBatchSize, len(pending))
+			batch := make([]models.Subscription, 0, end-start)
+			for _, i := range pending[start:end] {
+				batch = append(batch, rows[i].Subscription)
+			}
+			err := s.repo.CreateBatch(ctx, batch)
+			for j, i := range pending[start:end] {
+				if err != nil {
+					report.Rows[i].Status = ImportFailed
+					report.Rows[i].Reason = err.Error()
+					continue
+				}
+				report.Rows[i].ID = batch[j].ID
+			}
+		}
+	}
+
+	for _, r := range report.Rows {
+		switch r.Status {
+		case ImportCreated:
+			report.Created++
+		case ImportSkipped:
+			report.Skipped++
+		case ImportFailed:
+			report.Failed++
+		}
+	}
+	return report, nil
+}
+
+// Export filter bo‘yicha subscriptionlarni berilgan formatda w ga yozadi.
+// Qatorlar bazadan birma-bir o‘qiladi, hammasi xotiraga yuklanmaydi.
+func (s *subscriptionService) Export(ctx context.Context, filter models.SubscriptionFilter, format string, w io.Writer) error {
+	exp, err := newExporter(format, w)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Iterate(ctx, filter, exp.Write); err != nil {
+		// nothing buffered is flushed, so a query failing before the first
+		// rows leaves w untouched
+		exp.Abort()
+		return err
+	}
+	return exp.Close()
+}
+
+func checkTransition(sub models.Subscription, to models.SubscriptionStatus, now time.Time) error {
+	from := currentStatus(sub, now)
+	if !canTransition(from, to) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, from, to)
 	}
-	return s.repo.TotalPrice(userID, serviceName, fromStr, toStr)
+	return nil
 }
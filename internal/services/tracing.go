@@ -0,0 +1,146 @@
+package services
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"subscriptions_service_golang/internal/models"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "subscriptions_service_golang/internal/services"
+
+// tracingService wraps every SubscriptionService call in a span
+type tracingService struct {
+	next   SubscriptionService
+	tracer trace.Tracer
+}
+
+// NewTracingService next ni o‘rab, har bir metod uchun span ochadi
+func NewTracingService(next SubscriptionService, provider trace.TracerProvider) SubscriptionService {
+	return &tracingService{next: next, tracer: provider.Tracer(tracerName)}
+}
+
+func (s *tracingService) start(ctx context.Context, method string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return s.tracer.Start(ctx, "SubscriptionService."+method, trace.WithAttributes(attrs...))
+}
+
+func finish(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func idAttr(id uint) attribute.KeyValue {
+	return attribute.Int64("subscription.id", int64(id))
+}
+
+func filterAttrs(filter models.SubscriptionFilter) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("user.id", filter.UserID),
+		attribute.String("subscription.service_name", filter.ServiceName),
+		attribute.Bool("subscription.active", filter.Active),
+	}
+}
+
+func (s *tracingService) Create(ctx context.Context, sub models.Subscription, allowDuplicate bool) (_ *models.Subscription, err error) {
+	ctx, span := s.start(ctx, "Create", attribute.String("user.id", sub.UserID), attribute.Bool("allow_duplicate", allowDuplicate))
+	defer func() { finish(span, err) }()
+	return s.next.Create(ctx, sub, allowDuplicate)
+}
+
+func (s *tracingService) GetByID(ctx context.Context, id uint) (_ *models.Subscription, err error) {
+	ctx, span := s.start(ctx, "GetByID", idAttr(id))
+	defer func() { finish(span, err) }()
+	return s.next.GetByID(ctx, id)
+}
+
+func (s *tracingService) List(ctx context.Context, filter models.SubscriptionFilter) (subs []models.Subscription, err error) {
+	ctx, span := s.start(ctx, "List", filterAttrs(filter)...)
+	defer func() {
+		span.SetAttributes(attribute.Int("subscription.count", len(subs)))
+		finish(span, err)
+	}()
+	return s.next.List(ctx, filter)
+}
+
+func (s *tracingService) Update(ctx context.Context, sub models.Subscription) (_ *models.Subscription, err error) {
+	ctx, span := s.start(ctx, "Update", idAttr(sub.ID))
+	defer func() { finish(span, err) }()
+	return s.next.Update(ctx, sub)
+}
+
+func (s *tracingService) Delete(ctx context.Context, id uint) (err error) {
+	ctx, span := s.start(ctx, "Delete", idAttr(id))
+	defer func() { finish(span, err) }()
+	return s.next.Delete(ctx, id)
+}
+
+func (s *tracingService) TotalPrice(ctx context.Context, userID string, serviceName string, from, to *time.Time) (_ int, err error) {
+	ctx, span := s.start(ctx, "TotalPrice", attribute.String("user.id", userID), attribute.String("subscription.service_name", serviceName))
+	defer func() { finish(span, err) }()
+	return s.next.TotalPrice(ctx, userID, serviceName, from, to)
+}
+
+func (s *tracingService) Pause(ctx context.Context, id uint) (_ *models.Subscription, err error) {
+	ctx, span := s.start(ctx, "Pause", idAttr(id))
+	defer func() { finish(span, err) }()
+	return s.next.Pause(ctx, id)
+}
+
+func (s *tracingService) Resume(ctx context.Context, id uint) (_ *models.Subscription, err error) {
+	ctx, span := s.start(ctx, "Resume", idAttr(id))
+	defer func() { finish(span, err) }()
+	return s.next.Resume(ctx, id)
+}
+
+func (s *tracingService) Cancel(ctx context.Context, id uint, effectiveDate time.Time) (_ *models.Subscription, err error) {
+	ctx, span := s.start(ctx, "Cancel", idAttr(id))
+	defer func() { finish(span, err) }()
+	return s.next.Cancel(ctx, id, effectiveDate)
+}
+
+func (s *tracingService) ExpireDue(ctx context.Context, now time.Time) (subs []models.Subscription, err error) {
+	ctx, span := s.start(ctx, "ExpireDue")
+	defer func() {
+		span.SetAttributes(attribute.Int("subscription.count", len(subs)))
+		finish(span, err)
+	}()
+	return s.next.ExpireDue(ctx, now)
+}
+
+func (s *tracingService) Import(ctx context.Context, rows []ImportRow, opts ImportOptions) (_ *ImportReport, err error) {
+	ctx, span := s.start(ctx, "Import", attribute.Int("import.rows", len(rows)), attribute.Bool("import.dry_run", opts.DryRun))
+	defer func() { finish(span, err) }()
+	return s.next.Import(ctx, rows, opts)
+}
+
+func (s *tracingService) Export(ctx context.Context, filter models.SubscriptionFilter, format string, w io.Writer) (err error) {
+	ctx, span := s.start(ctx, "Export", append(filterAttrs(filter), attribute.String("export.format", format))...)
+	defer func() { finish(span, err) }()
+	return s.next.Export(ctx, filter, format, w)
+}
+
+func (s *tracingService) Bulk(ctx context.Context, req BulkRequest) (_ *BulkReport, err error) {
+	ctx, span := s.start(ctx, "Bulk", attribute.String("bulk.mode", req.Mode), attribute.Int("bulk.operations", len(req.Operations)))
+	defer func() { finish(span, err) }()
+	return s.next.Bulk(ctx, req)
+}
+
+func (s *tracingService) FindDuplicates(ctx context.Context, userID string) (_ []DuplicateGroup, err error) {
+	ctx, span := s.start(ctx, "FindDuplicates", attribute.String("user.id", userID))
+	defer func() { finish(span, err) }()
+	return s.next.FindDuplicates(ctx, userID)
+}
+
+func (s *tracingService) Stats(ctx context.Context) (_ models.SubscriptionStats, err error) {
+	ctx, span := s.start(ctx, "Stats")
+	defer func() { finish(span, err) }()
+	return s.next.Stats(ctx)
+}
@@ -0,0 +1,38 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"subscriptions_service_golang/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracingService(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	existing := models.Subscription{ID: 1, ServiceName: "Netflix", Price: 500, UserID: importUser, StartDate: date("2025-01-01")}
+	service := NewTracingService(&subscriptionService{repo: newMapRepo(existing), now: time.Now}, provider)
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "request")
+	_, err := service.GetByID(ctx, 1)
+	assert.NoError(t, err)
+	_, err = service.GetByID(ctx, 2)
+	assert.ErrorIs(t, err, ErrNotFound)
+	parent.End()
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 3)
+
+	ok, failed := spans[0], spans[1]
+	assert.Equal(t, "SubscriptionService.GetByID", ok.Name())
+	assert.Equal(t, parent.SpanContext().SpanID(), ok.Parent().SpanID())
+	assert.Equal(t, codes.Unset, ok.Status().Code)
+	assert.Equal(t, codes.Error, failed.Status().Code)
+	assert.Len(t, failed.Events(), 1)
+}
@@ -0,0 +1,32 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"subscriptions_service_golang/internal/models"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// validateSubscription checks the fields a client is allowed to set
+func validateSubscription(sub models.Subscription) error {
+	switch {
+	case strings.TrimSpace(sub.ServiceName) == "":
+		return fmt.Errorf("%w: service_name is required", ErrValidation)
+	case len(sub.ServiceName) > 255:
+		return fmt.Errorf("%w: service_name is longer than 255 characters", ErrValidation)
+	case sub.Price < 0:
+		return fmt.Errorf("%w: price must not be negative", ErrValidation)
+	case !uuidPattern.MatchString(sub.UserID):
+		return fmt.Errorf("%w: user_id must be a UUID", ErrValidation)
+	case sub.StartDate.IsZero():
+		return fmt.Errorf("%w: start_date is required", ErrValidation)
+	case sub.EndDate != nil && sub.EndDate.Before(sub.StartDate):
+		return fmt.Errorf("%w: end_date is before start_date", ErrValidation)
+	case sub.TrialEndsAt != nil && sub.TrialEndsAt.Before(sub.StartDate):
+		return fmt.Errorf("%w: trial_ends_at is before start_date", ErrValidation)
+	}
+	return nil
+}
@@ -0,0 +1 @@
+-- nothing to roll back, see 002_seed_data.up.sql
@@ -1,10 +0,0 @@
-INSERT INTO public.subscriptions
-(id, created_at, updated_at, service_name, price, user_id, start_date)
-VALUES
-(1, NOW(), NOW(), 'Yandex Plus', 4500, '60601fee-2bf1-4721-ae6f-7636e79a0cba', '2025-07-01'),
-(2, NOW(), NOW(), 'Netflix', 5600, '60601fee-2bf1-4721-ae6f-7636e79a0cba', '2025-07-01'),
-(3, NOW(), NOW(), 'Spotify', 3000, '60601fee-2bf1-4721-ae6f-7636e79a0cba', '2025-08-01'),
-(4, NOW(), NOW(), 'YouTube Premium', 3500, '60601fee-2bf1-4721-ae6f-7636e79a0cba', '2025-09-01'),
-(5, NOW(), NOW(), 'Apple Music', 4000, '60601fee-2bf1-4721-ae6f-7636e79a0cba', '2025-10-01'),
-(6, NOW(), NOW(), 'Google One', 2500, '60601fee-2bf1-4721-ae6f-7636e79a0cba', '2025-11-01'),
-(7, NOW(), NOW(), 'Amazon Prime', 5000, '60601fee-2bf1-4721-ae6f-7636e79a0cba', '2025-12-01');
@@ -0,0 +1,3 @@
+-- Demo data used to be inserted here with hard-coded ids. It is now loaded
+-- on demand with "subscription-service seed"; the migration is kept so the
+-- version sequence of existing databases stays intact.
@@ -0,0 +1,8 @@
+DROP TABLE public.subscription_pauses;
+
+
+
+ALTER TABLE public.subscriptions
+    DROP COLUMN status,
+    DROP COLUMN trial_ends_at,
+    DROP COLUMN cancelled_at;
@@ -0,0 +1,17 @@
+ALTER TABLE public.subscriptions
+    ADD COLUMN status character varying(16) DEFAULT 'active' NOT NULL,
+    ADD COLUMN trial_ends_at timestamp with time zone,
+    ADD COLUMN cancelled_at timestamp with time zone;
+
+
+
+CREATE TABLE public.subscription_pauses (
+    id bigserial PRIMARY KEY,
+    subscription_id bigint NOT NULL REFERENCES public.subscriptions(id),
+    paused_at timestamp with time zone NOT NULL,
+    resumed_at timestamp with time zone
+);
+
+
+
+CREATE INDEX idx_subscription_pauses_subscription_id ON public.subscription_pauses USING btree (subscription_id);
@@ -0,0 +1,13 @@
+CREATE TABLE public.idempotency_keys (
+    key character varying(255) PRIMARY KEY,
+    request_hash character(64) NOT NULL,
+    completed boolean DEFAULT false NOT NULL,
+    status_code bigint,
+    content_type character varying(255),
+    response bytea,
+    created_at timestamp with time zone
+);
+
+
+
+CREATE INDEX idx_idempotency_keys_created_at ON public.idempotency_keys USING btree (created_at);
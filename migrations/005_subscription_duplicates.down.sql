@@ -0,0 +1,6 @@
+DROP INDEX public.idx_subscriptions_user_service;
+
+
+
+ALTER TABLE public.subscriptions
+    DROP COLUMN duplicate;
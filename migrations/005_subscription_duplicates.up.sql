@@ -0,0 +1,6 @@
+ALTER TABLE public.subscriptions
+    ADD COLUMN duplicate boolean DEFAULT false NOT NULL;
+
+
+
+CREATE INDEX idx_subscriptions_user_service ON public.subscriptions USING btree (user_id, lower((service_name)::text));
@@ -0,0 +1,5 @@
+DROP TABLE public.api_keys;
+
+
+
+DROP TABLE public.users;
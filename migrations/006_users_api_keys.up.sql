@@ -0,0 +1,29 @@
+CREATE TABLE public.users (
+    id uuid PRIMARY KEY,
+    username character varying(255) NOT NULL,
+    password_hash character varying(255) NOT NULL,
+    role character varying(16) DEFAULT 'user' NOT NULL,
+    created_at timestamp with time zone
+);
+
+
+
+CREATE UNIQUE INDEX idx_users_username ON public.users USING btree (lower((username)::text));
+
+
+
+CREATE TABLE public.api_keys (
+    id bigserial PRIMARY KEY,
+    user_id uuid NOT NULL REFERENCES public.users (id) ON DELETE CASCADE,
+    name character varying(255) NOT NULL,
+    prefix character varying(16) NOT NULL,
+    key_hash character(64) NOT NULL,
+    created_at timestamp with time zone,
+    expires_at timestamp with time zone,
+    last_used_at timestamp with time zone
+);
+
+
+
+CREATE UNIQUE INDEX idx_api_keys_key_hash ON public.api_keys USING btree (key_hash);
+CREATE INDEX idx_api_keys_user_id ON public.api_keys USING btree (user_id);
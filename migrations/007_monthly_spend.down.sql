@@ -0,0 +1,5 @@
+DROP TABLE public.monthly_spend_state;
+
+
+
+DROP TABLE public.monthly_spend;
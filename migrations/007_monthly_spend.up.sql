@@ -0,0 +1,20 @@
+CREATE TABLE public.monthly_spend (
+    subscription_id bigint NOT NULL REFERENCES public.subscriptions (id) ON DELETE CASCADE,
+    month timestamp with time zone NOT NULL,
+    user_id uuid NOT NULL,
+    service_name character varying(255) NOT NULL,
+    amount integer NOT NULL,
+    PRIMARY KEY (subscription_id, month)
+);
+
+
+
+CREATE INDEX idx_monthly_spend_user_month ON public.monthly_spend USING btree (user_id, month);
+
+
+
+-- the rollup is used only up to this month; the service fills it in
+CREATE TABLE public.monthly_spend_state (
+    id integer PRIMARY KEY CHECK (id = 1),
+    through timestamp with time zone NOT NULL
+);
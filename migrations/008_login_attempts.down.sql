@@ -0,0 +1,5 @@
+DROP TABLE public.login_lockouts;
+
+
+
+DROP TABLE public.login_attempts;
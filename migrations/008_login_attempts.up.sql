@@ -0,0 +1,22 @@
+CREATE TABLE public.login_attempts (
+    id bigserial PRIMARY KEY,
+    username character varying(255) NOT NULL,
+    ip character varying(64) NOT NULL,
+    result character varying(16) NOT NULL,
+    created_at timestamp with time zone NOT NULL
+);
+
+
+
+CREATE INDEX idx_login_attempts_created_at ON public.login_attempts USING btree (created_at);
+CREATE INDEX idx_login_attempts_username ON public.login_attempts USING btree (lower((username)::text), created_at);
+CREATE INDEX idx_login_attempts_ip ON public.login_attempts USING btree (ip, created_at);
+
+
+
+CREATE TABLE public.login_lockouts (
+    key character varying(320) PRIMARY KEY,
+    failures integer NOT NULL,
+    last_failure_at timestamp with time zone NOT NULL,
+    locked_until timestamp with time zone
+);
@@ -0,0 +1,4 @@
+ALTER TABLE public.subscription_pauses
+    DROP CONSTRAINT subscription_pauses_subscription_id_fkey,
+    ADD CONSTRAINT subscription_pauses_subscription_id_fkey
+        FOREIGN KEY (subscription_id) REFERENCES public.subscriptions (id);
@@ -0,0 +1,5 @@
+-- subscriptions are deleted for good, their pauses go with them
+ALTER TABLE public.subscription_pauses
+    DROP CONSTRAINT subscription_pauses_subscription_id_fkey,
+    ADD CONSTRAINT subscription_pauses_subscription_id_fkey
+        FOREIGN KEY (subscription_id) REFERENCES public.subscriptions (id) ON DELETE CASCADE;
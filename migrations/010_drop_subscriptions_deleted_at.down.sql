@@ -0,0 +1,5 @@
+ALTER TABLE public.subscriptions ADD COLUMN deleted_at timestamp with time zone;
+
+
+
+CREATE INDEX idx_subscriptions_deleted_at ON public.subscriptions USING btree (deleted_at);
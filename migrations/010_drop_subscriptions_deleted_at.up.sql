@@ -0,0 +1,7 @@
+-- deletes are hard (subscriptions and their pauses are removed), the
+-- soft delete column was never written
+DROP INDEX public.idx_subscriptions_deleted_at;
+
+
+
+ALTER TABLE public.subscriptions DROP COLUMN deleted_at;
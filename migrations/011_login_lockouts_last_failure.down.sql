@@ -0,0 +1 @@
+DROP INDEX public.idx_login_lockouts_last_failure_at;
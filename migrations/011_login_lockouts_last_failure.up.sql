@@ -0,0 +1,2 @@
+-- the cleanup job deletes lockouts by their last failure
+CREATE INDEX idx_login_lockouts_last_failure_at ON public.login_lockouts USING btree (last_failure_at);
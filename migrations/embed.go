@@ -0,0 +1,8 @@
+// Package migrations embeds the versioned SQL migrations of the service.
+// Each version has a NNN_name.up.sql and a NNN_name.down.sql file.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
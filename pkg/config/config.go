@@ -0,0 +1,453 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"subscriptions_service_golang/internal/ratelimit"
+	"subscriptions_service_golang/pkg"
+	"subscriptions_service_golang/pkg/server"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the complete service configuration.
+//
+// Every setting has a default and can be overridden, in increasing order of
+// precedence, by the YAML file given with -config (or CONFIG_FILE), by the
+// environment variable in its env tag and by the command line flag in its
+// flag tag. Settings tagged secret are masked when the config is printed.
+type Config struct {
+	Database  DatabaseConfig  `yaml:"database"`
+	HTTP      HTTPConfig      `yaml:"http"`
+	Log       LogConfig       `yaml:"log"`
+	Tracing   TracingConfig   `yaml:"tracing"`
+	Jobs      JobsConfig      `yaml:"jobs"`
+	Swagger   SwaggerConfig   `yaml:"swagger"`
+	Auth      AuthConfig      `yaml:"auth"`
+	Cache     CacheConfig     `yaml:"cache"`
+	Redis     RedisConfig     `yaml:"redis"`
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+}
+
+type DatabaseConfig struct {
+	Driver         string        `yaml:"driver" env:"DB_DRIVER" flag:"db-driver" usage:"postgres, sqlite (local development) or memory (no persistence)"`
+	DSN            string        `yaml:"dsn" env:"DB_DSN" flag:"db-dsn" secret:"true" usage:"PostgreSQL connection string or SQLite file"`
+	Replicas       []string      `yaml:"replicas" env:"DB_REPLICAS" flag:"db-replicas" secret:"true" usage:"comma separated PostgreSQL connection strings of read replicas"`
+	ReadYourWrites time.Duration `yaml:"read_your_writes" env:"DB_READ_YOUR_WRITES" flag:"db-read-your-writes" usage:"how long reads of a caller stay on the primary after it wrote, 0 disables it"`
+	QueryTimeout   time.Duration `yaml:"query_timeout" env:"QUERY_TIMEOUT" flag:"query-timeout" usage:"timeout of a single database query, 0 disables it"`
+	Migrations     string        `yaml:"migrations" env:"DB_MIGRATIONS" flag:"db-migrations" usage:"on startup: up applies pending migrations, check refuses to start while any are pending, skip does nothing"`
+
+	MaxOpenConns     int           `yaml:"max_open_conns" env:"DB_MAX_OPEN_CONNS" flag:"db-max-open-conns" usage:"maximum open connections per database, 0 is unlimited"`
+	MaxIdleConns     int           `yaml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS" flag:"db-max-idle-conns" usage:"idle connections kept per database"`
+	ConnMaxLifetime  time.Duration `yaml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME" flag:"db-conn-max-lifetime" usage:"connections older than this are closed, 0 keeps them"`
+	StatementTimeout time.Duration `yaml:"statement_timeout" env:"DB_STATEMENT_TIMEOUT" flag:"db-statement-timeout" usage:"Postgres cancels statements running longer, including exports; 0 disables it"`
+	ConnectTimeout   time.Duration `yaml:"connect_timeout" env:"DB_CONNECT_TIMEOUT" flag:"db-connect-timeout" usage:"how long to retry connecting on startup"`
+	BreakerFailures  int           `yaml:"breaker_failures" env:"DB_BREAKER_FAILURES" flag:"db-breaker-failures" usage:"consecutive connection failures that open the circuit breaker, 0 disables it"`
+	BreakerCooldown  time.Duration `yaml:"breaker_cooldown" env:"DB_BREAKER_COOLDOWN" flag:"db-breaker-cooldown" usage:"how long an open circuit breaker rejects queries before it lets one through"`
+}
+
+type HTTPConfig struct {
+	Addr              string        `yaml:"addr" env:"HTTP_ADDR" flag:"http-addr" usage:"address the HTTP server listens on"`
+	ReadTimeout       time.Duration `yaml:"read_timeout" env:"HTTP_READ_TIMEOUT" flag:"http-read-timeout" usage:"time to read the whole request"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" env:"HTTP_READ_HEADER_TIMEOUT" flag:"http-read-header-timeout" usage:"time to read request headers"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" env:"HTTP_WRITE_TIMEOUT" flag:"http-write-timeout" usage:"time to write the response"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" env:"HTTP_IDLE_TIMEOUT" flag:"http-idle-timeout" usage:"keep-alive connection idle time"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout" env:"HTTP_SHUTDOWN_TIMEOUT" flag:"http-shutdown-timeout" usage:"time to drain requests on shutdown"`
+	MaxHeaderBytes    int           `yaml:"max_header_bytes" env:"HTTP_MAX_HEADER_BYTES" flag:"http-max-header-bytes" usage:"maximum size of request headers"`
+	TLSCertFile       string        `yaml:"tls_cert_file" env:"TLS_CERT_FILE" flag:"tls-cert-file" usage:"TLS certificate, enables HTTPS together with the key"`
+	TLSKeyFile        string        `yaml:"tls_key_file" env:"TLS_KEY_FILE" flag:"tls-key-file" usage:"TLS private key"`
+	TrustedProxies    []string      `yaml:"trusted_proxies" env:"HTTP_TRUSTED_PROXIES" flag:"http-trusted-proxies" usage:"comma separated addresses or CIDRs of proxies whose X-Forwarded-For is believed; empty trusts none"`
+}
+
+type LogConfig struct {
+	Level  string `yaml:"level" env:"LOG_LEVEL" flag:"log-level" usage:"debug, info, warn or error"`
+	Format string `yaml:"format" env:"LOG_FORMAT" flag:"log-format" usage:"json or console"`
+}
+
+type TracingConfig struct {
+	Exporter string `yaml:"exporter" env:"OTEL_TRACES_EXPORTER" flag:"traces-exporter" usage:"otlp, stdout or none"`
+}
+
+type JobsConfig struct {
+	ExpiryInterval time.Duration `yaml:"expiry_interval" env:"EXPIRY_INTERVAL" flag:"expiry-interval" usage:"how often due subscriptions are expired"`
+}
+
+type SwaggerConfig struct {
+	Host string `yaml:"host" env:"SWAGGER_HOST" flag:"swagger-host" usage:"host shown in the Swagger documentation"`
+}
+
+type AuthConfig struct {
+	StaticCredentials bool          `yaml:"static_credentials" env:"AUTH_STATIC_CREDENTIALS" flag:"auth-static-credentials" usage:"accept the built-in admin/password login and test-token, for local development"`
+	SessionTTL        time.Duration `yaml:"session_ttl" env:"AUTH_SESSION_TTL" flag:"auth-session-ttl" usage:"lifetime of the token returned by /login"`
+	MaxFailures       int           `yaml:"max_failures" env:"AUTH_MAX_FAILURES" flag:"auth-max-failures" usage:"failed logins of a username that lock it, 0 disables the lockout"`
+	MaxIPFailures     int           `yaml:"max_ip_failures" env:"AUTH_MAX_IP_FAILURES" flag:"auth-max-ip-failures" usage:"failed logins from one address, for any usernames, that lock it, 0 disables the lockout"`
+	FailureDelay      time.Duration `yaml:"failure_delay" env:"AUTH_FAILURE_DELAY" flag:"auth-failure-delay" usage:"wait before the next login of a username after a failure, doubled with each failure, 0 disables it"`
+	Lockout           time.Duration `yaml:"lockout" env:"AUTH_LOCKOUT" flag:"auth-lockout" usage:"how long a locked username or address cannot log in"`
+	FailureWindow     time.Duration `yaml:"failure_window" env:"AUTH_FAILURE_WINDOW" flag:"auth-failure-window" usage:"failed logins older than this are forgotten"`
+	AttemptRetention  time.Duration `yaml:"attempt_retention" env:"AUTH_ATTEMPT_RETENTION" flag:"auth-attempt-retention" usage:"how long the login audit log is kept, 0 keeps it forever"`
+}
+
+type CacheConfig struct {
+	Backend string        `yaml:"backend" env:"CACHE_BACKEND" flag:"cache-backend" usage:"cache of totals and stats: memory (per instance), redis (shared) or none"`
+	TTL     time.Duration `yaml:"ttl" env:"CACHE_TTL" flag:"cache-ttl" usage:"how long a cached total is served"`
+	Size    int           `yaml:"size" env:"CACHE_SIZE" flag:"cache-size" usage:"maximum entries of the memory cache"`
+}
+
+type RedisConfig struct {
+	URL string `yaml:"url" env:"REDIS_URL" flag:"redis-url" secret:"true" usage:"Redis connection URL, e.g. redis://localhost:6379/0"`
+}
+
+type RateLimitConfig struct {
+	Backend string   `yaml:"backend" env:"RATE_LIMIT_BACKEND" flag:"rate-limit-backend" usage:"token buckets: memory (per instance), redis (shared) or none"`
+	Default string   `yaml:"default" env:"RATE_LIMIT_DEFAULT" flag:"rate-limit-default" usage:"requests/period of one client on every route not listed in routes, e.g. 300/1m; empty for no limit"`
+	Routes  []string `yaml:"routes" env:"RATE_LIMIT_ROUTES" flag:"rate-limit-routes" usage:"comma separated METHOD /path=requests/period, an empty limit exempts the route"`
+}
+
+// Default returns the configuration used when nothing is overridden
+func Default() Config {
+	srv := server.DefaultConfig()
+	return Config{
+		Database: DatabaseConfig{
+			Driver:          "postgres",
+			QueryTimeout:    5 * time.Second,
+			Migrations:      "check",
+			MaxOpenConns:    25,
+			MaxIdleConns:    10,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnectTimeout:  time.Minute,
+			BreakerFailures: 5,
+			BreakerCooldown: 10 * time.Second,
+		},
+		HTTP: HTTPConfig{
+			Addr:              srv.Addr,
+			ReadTimeout:       srv.ReadTimeout,
+			ReadHeaderTimeout: srv.ReadHeaderTimeout,
+			WriteTimeout:      srv.WriteTimeout,
+			IdleTimeout:       srv.IdleTimeout,
+			ShutdownTimeout:   srv.ShutdownTimeout,
+			MaxHeaderBytes:    srv.MaxHeaderBytes,
+		},
+		Log:     LogConfig{Level: "info", Format: "json"},
+		Tracing: TracingConfig{Exporter: "none"},
+		Jobs:    JobsConfig{ExpiryInterval: time.Minute},
+		Swagger: SwaggerConfig{Host: "localhost:8080"},
+		Auth: AuthConfig{
+			StaticCredentials: false,
+			SessionTTL:        24 * time.Hour,
+			MaxFailures:       5,
+			MaxIPFailures:     50,
+			FailureDelay:      time.Second,
+			Lockout:           15 * time.Minute,
+			FailureWindow:     time.Hour,
+			AttemptRetention:  90 * 24 * time.Hour,
+		},
+		Cache: CacheConfig{Backend: "memory", TTL: time.Minute, Size: 10000},
+		RateLimit: RateLimitConfig{
+			Backend: "memory",
+			Default: "300/1m",
+			Routes:  []string{"POST /login=10/1m", "GET /subscriptions/total=60/1m"},
+		},
+	}
+}
+
+// Options converts the Postgres settings to pkg.Options
+func (c DatabaseConfig) Options() pkg.Options {
+	return pkg.Options{
+		Replicas:         c.Replicas,
+		MaxOpenConns:     c.MaxOpenConns,
+		MaxIdleConns:     c.MaxIdleConns,
+		ConnMaxLifetime:  c.ConnMaxLifetime,
+		StatementTimeout: c.StatementTimeout,
+		ConnectTimeout:   c.ConnectTimeout,
+	}
+}
+
+// Rules parses the limits. Routes are written as registered with the
+// router, path parameters included: "PUT /subscriptions/:id=30/1m".
+func (c RateLimitConfig) Rules() (ratelimit.Rules, error) {
+	var errs []error
+	def, err := ratelimit.ParseLimit(c.Default)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("rate_limit.default: %w", err))
+	}
+	rules := ratelimit.Rules{Default: def, Routes: make(map[string]ratelimit.Limit)}
+	for _, entry := range c.Routes {
+		route, limit, ok := strings.Cut(entry, "=")
+		method, path, _ := strings.Cut(strings.TrimSpace(route), " ")
+		if !ok || method == "" || !strings.HasPrefix(strings.TrimSpace(path), "/") {
+			errs = append(errs, fmt.Errorf("rate_limit.routes: %q is not METHOD /path=requests/period", entry))
+			continue
+		}
+		l, err := ratelimit.ParseLimit(limit)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rate_limit.routes: %w", err))
+			continue
+		}
+		rules.Routes[strings.ToUpper(method)+" "+strings.TrimSpace(path)] = l
+	}
+	return rules, errors.Join(errs...)
+}
+
+// Server converts the HTTP settings to a server.Config
+func (c HTTPConfig) Server() server.Config {
+	return server.Config{
+		Addr:              c.Addr,
+		ReadTimeout:       c.ReadTimeout,
+		ReadHeaderTimeout: c.ReadHeaderTimeout,
+		WriteTimeout:      c.WriteTimeout,
+		IdleTimeout:       c.IdleTimeout,
+		ShutdownTimeout:   c.ShutdownTimeout,
+		MaxHeaderBytes:    c.MaxHeaderBytes,
+		TLSCertFile:       c.TLSCertFile,
+		TLSKeyFile:        c.TLSKeyFile,
+	}
+}
+
+// Load registers a flag for every setting plus -config on fs, parses args
+// and builds the configuration from defaults, the file, the environment
+// and the flags. The result is validated.
+func Load(fs *flag.FlagSet, args []string) (Config, error) {
+	cfg := Default()
+
+	file := fs.String("config", os.Getenv("CONFIG_FILE"), "YAML configuration file")
+	flags := make(map[string]*string)
+	walk(&cfg, func(f field) {
+		if name := f.tag.Get("flag"); name != "" {
+			usage := f.tag.Get("usage")
+			if env := f.tag.Get("env"); env != "" {
+				usage += " (env " + env + ")"
+			}
+			flags[name] = fs.String(name, f.String(), usage)
+		}
+	})
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	if *file != "" {
+		if err := loadFile(&cfg, *file); err != nil {
+			return cfg, err
+		}
+	}
+
+	var errs []error
+	walk(&cfg, func(f field) {
+		if env := f.tag.Get("env"); env != "" {
+			if v, ok := os.LookupEnv(env); ok && v != "" {
+				if err := f.Set(v); err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", env, err))
+				}
+			}
+		}
+	})
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	walk(&cfg, func(f field) {
+		if name := f.tag.Get("flag"); set[name] {
+			if err := f.Set(*flags[name]); err != nil {
+				errs = append(errs, fmt.Errorf("-%s: %w", name, err))
+			}
+		}
+	})
+	if len(errs) > 0 {
+		return cfg, errors.Join(errs...)
+	}
+	return cfg, cfg.Validate()
+}
+
+func loadFile(cfg *Config, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate checks every setting and reports all problems at once
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(oneOf(c.Database.Driver, "postgres", "sqlite", "memory"), "database.driver %q must be postgres, sqlite or memory", c.Database.Driver)
+	check(c.Database.DSN != "" || c.Database.Driver == "memory", "database.dsn is required (DB_DSN)")
+	check(len(c.Database.Replicas) == 0 || c.Database.Driver == "postgres", "database.replicas need the postgres driver")
+	check(c.Database.ReadYourWrites >= 0, "database.read_your_writes must not be negative")
+	check(c.Database.QueryTimeout >= 0, "database.query_timeout must not be negative")
+	check(c.Database.MaxOpenConns >= 0 && c.Database.MaxIdleConns >= 0, "database pool sizes must not be negative")
+	check(c.Database.MaxOpenConns == 0 || c.Database.MaxIdleConns <= c.Database.MaxOpenConns, "database.max_idle_conns must not exceed database.max_open_conns")
+	check(c.Database.ConnMaxLifetime >= 0, "database.conn_max_lifetime must not be negative")
+	check(c.Database.StatementTimeout >= 0, "database.statement_timeout must not be negative")
+	check(c.Database.ConnectTimeout >= 0, "database.connect_timeout must not be negative")
+	check(c.Database.BreakerFailures >= 0, "database.breaker_failures must not be negative")
+	check(c.Database.BreakerFailures == 0 || c.Database.BreakerCooldown > 0, "database.breaker_cooldown must be positive")
+	check(oneOf(c.Database.Migrations, "up", "check", "skip"), "database.migrations %q must be up, check or skip", c.Database.Migrations)
+
+	_, _, err := net.SplitHostPort(c.HTTP.Addr)
+	check(err == nil, "http.addr %q is not host:port", c.HTTP.Addr)
+	for name, d := range map[string]time.Duration{
+		"read_timeout":        c.HTTP.ReadTimeout,
+		"read_header_timeout": c.HTTP.ReadHeaderTimeout,
+		"write_timeout":       c.HTTP.WriteTimeout,
+		"idle_timeout":        c.HTTP.IdleTimeout,
+		"shutdown_timeout":    c.HTTP.ShutdownTimeout,
+	} {
+		check(d >= 0, "http.%s must not be negative", name)
+	}
+	check(c.HTTP.MaxHeaderBytes > 0, "http.max_header_bytes must be positive")
+	check((c.HTTP.TLSCertFile == "") == (c.HTTP.TLSKeyFile == ""), "http.tls_cert_file and http.tls_key_file must be set together")
+	for _, proxy := range c.HTTP.TrustedProxies {
+		_, _, cidrErr := net.ParseCIDR(proxy)
+		check(cidrErr == nil || net.ParseIP(proxy) != nil, "http.trusted_proxies: %q is not an address or CIDR", proxy)
+	}
+
+	check(oneOf(c.Log.Level, "debug", "info", "warn", "error"), "log.level %q must be debug, info, warn or error", c.Log.Level)
+	check(oneOf(c.Log.Format, "json", "console"), "log.format %q must be json or console", c.Log.Format)
+	check(oneOf(c.Tracing.Exporter, "otlp", "stdout", "none"), "tracing.exporter %q must be otlp, stdout or none", c.Tracing.Exporter)
+	check(c.Jobs.ExpiryInterval > 0, "jobs.expiry_interval must be positive")
+	check(c.Swagger.Host != "", "swagger.host is required")
+	check(c.Auth.SessionTTL > 0, "auth.session_ttl must be positive")
+	check(c.Auth.MaxFailures >= 0, "auth.max_failures must not be negative")
+	check(c.Auth.MaxIPFailures >= 0, "auth.max_ip_failures must not be negative")
+	check(c.Auth.FailureDelay >= 0, "auth.failure_delay must not be negative")
+	check(c.Auth.Lockout > 0, "auth.lockout must be positive")
+	check(c.Auth.FailureWindow > 0, "auth.failure_window must be positive")
+	check(c.Auth.AttemptRetention >= 0, "auth.attempt_retention must not be negative")
+	check(oneOf(c.Cache.Backend, "memory", "redis", "none"), "cache.backend %q must be memory, redis or none", c.Cache.Backend)
+	check(c.Cache.Backend == "none" || c.Cache.TTL > 0, "cache.ttl must be positive")
+	check(c.Cache.Backend != "memory" || c.Cache.Size > 0, "cache.size must be positive")
+	check(c.Cache.Backend != "redis" || c.Redis.URL != "", "redis.url is required for the redis cache (REDIS_URL)")
+	check(oneOf(c.RateLimit.Backend, "memory", "redis", "none"), "rate_limit.backend %q must be memory, redis or none", c.RateLimit.Backend)
+	check(c.RateLimit.Backend != "redis" || c.Redis.URL != "", "redis.url is required for the redis rate limiter (REDIS_URL)")
+	if _, err := c.RateLimit.Rules(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+func oneOf(v string, allowed ...string) bool {
+	for _, a := range allowed {
+		if v == a {
+			return true
+		}
+	}
+	return false
+}
+
+// Redacted returns the effective configuration as YAML with secrets masked
+func (c Config) Redacted() string {
+	out := make(map[string]map[string]interface{})
+	walk(&c, func(f field) {
+		var value interface{} = f.String()
+		switch {
+		case f.tag.Get("secret") == "true" && f.String() != "":
+			value = "[REDACTED]"
+		case f.value.Kind() == reflect.Int:
+			value = f.value.Int()
+		case f.value.Kind() == reflect.Bool:
+			value = f.value.Bool()
+		}
+		if out[f.section] == nil {
+			out[f.section] = make(map[string]interface{})
+		}
+		out[f.section][f.name] = value
+	})
+	b, _ := yaml.Marshal(out)
+	return string(b)
+}
+
+// field is one leaf setting found by walk
+type field struct {
+	section string
+	name    string
+	tag     reflect.StructTag
+	value   reflect.Value
+}
+
+// walk calls fn for every setting of cfg, section by section
+func walk(cfg *Config, fn func(field)) {
+	root := reflect.ValueOf(cfg).Elem()
+	for i := 0; i < root.NumField(); i++ {
+		section := root.Type().Field(i)
+		sv := root.Field(i)
+		for j := 0; j < sv.NumField(); j++ {
+			sf := sv.Type().Field(j)
+			fn(field{
+				section: section.Tag.Get("yaml"),
+				name:    sf.Tag.Get("yaml"),
+				tag:     sf.Tag,
+				value:   sv.Field(j),
+			})
+		}
+	}
+}
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	stringsType  = reflect.TypeOf([]string(nil))
+)
+
+func (f field) String() string {
+	switch f.value.Type() {
+	case durationType:
+		return time.Duration(f.value.Int()).String()
+	case stringsType:
+		return strings.Join(f.value.Interface().([]string), ",")
+	}
+	return fmt.Sprint(f.value.Interface())
+}
+
+func (f field) Set(s string) error {
+	switch {
+	case f.value.Type() == durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		f.value.SetInt(int64(d))
+	case f.value.Kind() == reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", s)
+		}
+		f.value.SetInt(int64(n))
+	case f.value.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", s)
+		}
+		f.value.SetBool(b)
+	case f.value.Kind() == reflect.String:
+		f.value.SetString(strings.TrimSpace(s))
+	case f.value.Type() == stringsType:
+		var items []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		f.value.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported setting type %s", f.value.Type())
+	}
+	return nil
+}
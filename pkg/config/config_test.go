@@ -0,0 +1,128 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func load(t *testing.T, args ...string) (Config, error) {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	return Load(fs, args)
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(file, []byte(`
+database:
+  dsn: host=file
+  query_timeout: 3s
+http:
+  addr: ":9000"
+log:
+  level: debug
+`), 0o600)
+	t.Setenv("CONFIG_FILE", file)
+	t.Setenv("HTTP_ADDR", ":9100")
+	t.Setenv("LOG_LEVEL", "warn")
+	t.Setenv("AUTH_STATIC_CREDENTIALS", "false")
+
+	cfg, err := load(t, "-log-level", "error")
+	assert.NoError(t, err)
+	assert.Equal(t, "host=file", cfg.Database.DSN) // file over default
+	assert.Equal(t, 3*time.Second, cfg.Database.QueryTimeout)
+	assert.Equal(t, ":9100", cfg.HTTP.Addr)               // env over file
+	assert.Equal(t, "error", cfg.Log.Level)               // flag over env
+	assert.Equal(t, "json", cfg.Log.Format)               // default
+	assert.Equal(t, 2*time.Minute, cfg.HTTP.WriteTimeout) // default
+	assert.False(t, cfg.Auth.StaticCredentials)
+}
+
+func TestLoadErrors(t *testing.T) {
+	t.Run("validation lists every problem", func(t *testing.T) {
+		t.Setenv("LOG_FORMAT", "xml")
+		_, err := load(t, "-http-addr", "8080")
+		assert.ErrorContains(t, err, "database.dsn is required")
+		assert.ErrorContains(t, err, `http.addr "8080"`)
+		assert.ErrorContains(t, err, `log.format "xml"`)
+	})
+
+	t.Run("pool settings are checked together", func(t *testing.T) {
+		t.Setenv("DB_DSN", "host=db")
+		_, err := load(t, "-db-max-open-conns", "5", "-db-max-idle-conns", "10", "-db-breaker-cooldown", "0s")
+		assert.ErrorContains(t, err, "database.max_idle_conns must not exceed database.max_open_conns")
+		assert.ErrorContains(t, err, "database.breaker_cooldown must be positive")
+	})
+
+	t.Run("trusted proxies are addresses", func(t *testing.T) {
+		t.Setenv("DB_DSN", "host=db")
+		_, err := load(t, "-http-trusted-proxies", "10.0.0.0/8,proxy.local")
+		assert.ErrorContains(t, err, `http.trusted_proxies: "proxy.local"`)
+
+		cfg, err := load(t, "-http-trusted-proxies", "10.0.0.0/8, 192.168.1.1")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"10.0.0.0/8", "192.168.1.1"}, cfg.HTTP.TrustedProxies)
+		assert.Empty(t, Default().HTTP.TrustedProxies, "no proxy is trusted by default")
+	})
+
+	t.Run("login lockout settings are checked", func(t *testing.T) {
+		t.Setenv("DB_DSN", "host=db")
+		t.Setenv("AUTH_MAX_FAILURES", "-1")
+		_, err := load(t, "-auth-lockout", "0s", "-auth-failure-delay", "-1s", "-auth-attempt-retention", "-1h")
+		assert.ErrorContains(t, err, "auth.max_failures must not be negative")
+		assert.ErrorContains(t, err, "auth.attempt_retention must not be negative")
+		assert.ErrorContains(t, err, "auth.lockout must be positive")
+		assert.ErrorContains(t, err, "auth.failure_delay must not be negative")
+	})
+
+	t.Run("rate limits are parsed", func(t *testing.T) {
+		t.Setenv("DB_DSN", "host=db")
+		t.Setenv("RATE_LIMIT_ROUTES", "post /login=5/m, GET /healthz=, /subscriptions=1/m, GET /subscriptions/total=often")
+		_, err := load(t, "-rate-limit-default", "300")
+		assert.ErrorContains(t, err, `rate_limit.default: limit "300"`)
+		assert.ErrorContains(t, err, `"/subscriptions=1/m" is not METHOD /path`)
+		assert.ErrorContains(t, err, `limit "often"`)
+
+		t.Setenv("RATE_LIMIT_ROUTES", "post /login=5/m, GET /healthz=")
+		cfg, err := load(t)
+		assert.NoError(t, err)
+		rules, _ := cfg.RateLimit.Rules()
+		assert.Equal(t, 5, rules.For("POST /login").Requests)
+		assert.True(t, rules.For("GET /healthz").Unlimited())
+		assert.Equal(t, 300, rules.For("GET /subscriptions").Requests)
+	})
+
+	t.Run("unparsable values name their source", func(t *testing.T) {
+		t.Setenv("DB_DSN", "host=db")
+		t.Setenv("QUERY_TIMEOUT", "soon")
+		_, err := load(t, "-http-max-header-bytes", "big")
+		assert.ErrorContains(t, err, "QUERY_TIMEOUT")
+		assert.ErrorContains(t, err, "-http-max-header-bytes")
+	})
+
+	t.Run("unknown file keys are rejected", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "config.yaml")
+		os.WriteFile(file, []byte("database:\n  dns: typo\n"), 0o600)
+		_, err := load(t, "-config", file)
+		assert.ErrorContains(t, err, "dns")
+	})
+}
+
+func TestRedacted(t *testing.T) {
+	t.Setenv("DB_DSN", "host=db password=hunter2")
+	t.Setenv("DB_REPLICAS", "host=r1 password=swordfish, host=r2 password=swordfish")
+	cfg, err := load(t)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"host=r1 password=swordfish", "host=r2 password=swordfish"}, cfg.Database.Replicas)
+
+	out := cfg.Redacted()
+	assert.NotContains(t, out, "hunter2")
+	assert.NotContains(t, out, "swordfish")
+	assert.Contains(t, out, "dsn: '[REDACTED]'")
+	assert.Contains(t, out, "query_timeout: 5s")
+}
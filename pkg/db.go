@@ -1,20 +1,179 @@
 package pkg
 
 import (
+	"context"
+	"database/sql"
+	"fmt"
 	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
 	"subscriptions_service_golang/internal/models"
+	"subscriptions_service_golang/pkg/logger"
 
+	"github.com/glebarez/sqlite"
+	"go.uber.org/zap"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/plugin/opentelemetry/tracing"
+)
+
+// Options tune the Postgres connection. Zero values keep the database/sql
+// defaults and disable the timeouts.
+type Options struct {
+	// Replicas are the DSNs of read replicas, see useReplicas
+	Replicas []string
+	// MaxOpenConns and MaxIdleConns limit the pool of each database
+	MaxOpenConns int
+	MaxIdleConns int
+	// ConnMaxLifetime closes connections older than this, so that they are
+	// spread again after a failover or a load balancer change
+	ConnMaxLifetime time.Duration
+	// StatementTimeout makes Postgres cancel statements running longer
+	StatementTimeout time.Duration
+	// ConnectTimeout is how long Open keeps retrying while the primary
+	// does not accept connections yet
+	ConnectTimeout time.Duration
+}
+
+// Connection retries start after connectBackoff and double up to
+// maxConnectBackoff
+const (
+	connectBackoff    = 500 * time.Millisecond
+	maxConnectBackoff = 10 * time.Second
 )
 
-func Init(dsn string) *gorm.DB {
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+// Open connects to the Postgres primary at dsn. While the database is not
+// reachable it retries with exponential backoff for up to
+// opts.ConnectTimeout, so the service survives starting before Postgres
+// does. Reads are spread over opts.Replicas when any are given.
+func Open(ctx context.Context, dsn string, opts Options) (*gorm.DB, error) {
+	// waitForDB pings instead of gorm. Replicas are opened with the same
+	// settings, so one that is down at startup only fails its health check.
+	db, err := gorm.Open(postgres.Open(withStatementTimeout(dsn, opts.StatementTimeout)), &gorm.Config{DisableAutomaticPing: true})
+	if err != nil {
+		return nil, fmt.Errorf("db connect error: %w", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("db connect error: %w", err)
+	}
+	if err := waitForDB(ctx, sqlDB, opts.ConnectTimeout); err != nil {
+		sqlDB.Close()
+		return nil, fmt.Errorf("db connect error: %w", err)
+	}
+	// query spans carry the SQL with placeholders only, never the values
+	if err := db.Use(tracing.NewPlugin(tracing.WithoutQueryVariables(), tracing.WithoutMetrics())); err != nil {
+		sqlDB.Close()
+		return nil, fmt.Errorf("db tracing error: %w", err)
+	}
+	if len(opts.Replicas) > 0 {
+		replicas := make([]string, len(opts.Replicas))
+		for i, replica := range opts.Replicas {
+			replicas[i] = withStatementTimeout(replica, opts.StatementTimeout)
+		}
+		if err := useReplicas(db, replicas, opts); err != nil {
+			sqlDB.Close()
+			return nil, fmt.Errorf("db replicas error: %w", err)
+		}
+	}
+	configurePool(sqlDB, opts)
+	return db, nil
+}
+
+// waitForDB pings until the database answers, ctx is done or timeout has
+// passed since the first attempt
+func waitForDB(ctx context.Context, sqlDB *sql.DB, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	backoff := connectBackoff
+	for attempt := 1; ; attempt++ {
+		pingCtx, cancel := context.WithTimeout(ctx, maxConnectBackoff)
+		err := sqlDB.PingContext(pingCtx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil || time.Now().Add(backoff).After(deadline) {
+			return err
+		}
+		logger.Log.Warn("Database is not available yet, retrying",
+			zap.Int("attempt", attempt), zap.Duration("backoff", backoff), zap.Error(err))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, maxConnectBackoff)
+	}
+}
+
+// configurePool applies the pool limits of opts to the primary's pool
+func configurePool(sqlDB *sql.DB, opts Options) {
+	if opts.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(opts.MaxOpenConns)
+	}
+	if opts.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(opts.MaxIdleConns)
+	}
+	if opts.ConnMaxLifetime > 0 {
+		sqlDB.SetConnMaxLifetime(opts.ConnMaxLifetime)
+	}
+}
+
+// withStatementTimeout adds the statement_timeout run-time parameter to
+// dsn, which pgx sends to the server when it connects. Both URL and
+// keyword/value connection strings are accepted.
+func withStatementTimeout(dsn string, timeout time.Duration) string {
+	if timeout <= 0 {
+		return dsn
+	}
+	ms := strconv.FormatInt(timeout.Milliseconds(), 10)
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		if u, err := url.Parse(dsn); err == nil {
+			q := u.Query()
+			q.Set("statement_timeout", ms)
+			u.RawQuery = q.Encode()
+			return u.String()
+		}
+	}
+	return strings.TrimSpace(dsn) + " statement_timeout=" + ms
+}
+
+// sqliteIndexes are the indexes of the Postgres migrations that the models
+// cannot express
+var sqliteIndexes = []string{
+	"CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username ON users (lower(username))",
+	"CREATE UNIQUE INDEX IF NOT EXISTS idx_api_keys_key_hash ON api_keys (key_hash)",
+	"CREATE INDEX IF NOT EXISTS idx_subscriptions_user_service ON subscriptions (user_id, lower(service_name))",
+}
+
+// InitSQLite opens an SQLite database for local development and tests.
+// The versioned migrations are written for Postgres, so the schema is
+// created from the models instead. dsn is a file path or, for example,
+// "file::memory:?cache=shared".
+func InitSQLite(dsn string) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{TranslateError: true})
+	if err != nil {
+		log.Fatalf("db connect error: %v", err)
+	}
+	// SQLite allows a single writer, more connections only produce "database is locked"
+	sqlDB, err := db.DB()
 	if err != nil {
 		log.Fatalf("db connect error: %v", err)
 	}
-	if err := db.AutoMigrate(&models.Subscription{}); err != nil {
-		log.Fatalf("migration error: %v", err)
+	sqlDB.SetMaxOpenConns(1)
+
+	err = db.AutoMigrate(&models.Subscription{}, &models.SubscriptionPause{}, &models.IdempotencyKey{}, &models.User{}, &models.APIKey{},
+		&models.MonthlySpend{}, &models.MonthlySpendState{}, &models.LoginAttempt{}, &models.LoginLockout{})
+	if err != nil {
+		log.Fatalf("db schema error: %v", err)
+	}
+	for _, index := range sqliteIndexes {
+		if err := db.Exec(index).Error; err != nil {
+			log.Fatalf("db schema error: %v", err)
+		}
 	}
 	return db
 }
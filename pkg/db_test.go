@@ -0,0 +1,45 @@
+package pkg
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithStatementTimeout(t *testing.T) {
+	tests := []struct {
+		dsn     string
+		timeout time.Duration
+		want    string
+	}{
+		{"host=db dbname=subscriptions", 0, "host=db dbname=subscriptions"},
+		{"host=db dbname=subscriptions ", 5 * time.Second, "host=db dbname=subscriptions statement_timeout=5000"},
+		{"postgres://u:p@db/subscriptions?sslmode=disable", 1500 * time.Millisecond, "postgres://u:p@db/subscriptions?sslmode=disable&statement_timeout=1500"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, withStatementTimeout(tt.dsn, tt.timeout))
+	}
+}
+
+func TestOpenRetriesUntilTimeout(t *testing.T) {
+	// a port nothing listens on
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := l.Addr().(*net.TCPAddr)
+	l.Close()
+	dsn := "host=127.0.0.1 port=" + strconv.Itoa(addr.Port) + " user=postgres dbname=subscriptions sslmode=disable"
+
+	start := time.Now()
+	_, err = Open(context.Background(), dsn, Options{ConnectTimeout: 2 * connectBackoff})
+	assert.Error(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), connectBackoff, "it retried before giving up")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = Open(ctx, dsn, Options{ConnectTimeout: time.Minute})
+	assert.Error(t, err, "a cancelled context stops the retries")
+}
@@ -1,15 +1,60 @@
 package logger
 
 import (
+    "context"
+    "fmt"
+
     "go.uber.org/zap"
+    "go.uber.org/zap/zapcore"
 )
 
-var Log *zap.Logger
+// Log is the process wide logger. Code that serves a request should prefer
+// FromContext, which carries the request ID and other request fields.
+var Log = zap.NewNop()
 
-func Init() {
-    var err error
-    Log, err = zap.NewProduction()
+// Init replaces Log with a logger of the given level (debug, info, warn,
+// error) and format (json or console)
+func Init(level, format string) error {
+    l, err := New(level, format)
     if err != nil {
-        panic(err)
+        return err
+    }
+    Log = l
+    return nil
+}
+
+// New returns a production logger with the given level and encoding
+func New(level, format string) (*zap.Logger, error) {
+    lvl, err := zap.ParseAtomicLevel(level)
+    if err != nil {
+        return nil, fmt.Errorf("invalid log level %q: %w", level, err)
+    }
+
+    cfg := zap.NewProductionConfig()
+    cfg.Level = lvl
+    switch format {
+    case "", "json":
+    case "console":
+        cfg.Encoding = "console"
+        cfg.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+        cfg.EncoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+    default:
+        return nil, fmt.Errorf("invalid log format %q", format)
+    }
+    return cfg.Build()
+}
+
+type ctxKey struct{}
+
+// WithContext returns a copy of ctx that carries l
+func WithContext(ctx context.Context, l *zap.Logger) context.Context {
+    return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// FromContext returns the logger stored in ctx, or Log if there is none
+func FromContext(ctx context.Context) *zap.Logger {
+    if l, ok := ctx.Value(ctxKey{}).(*zap.Logger); ok {
+        return l
     }
+    return Log
 }
@@ -0,0 +1,14 @@
+package migrate
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// isUndefinedTable reports the Postgres error for a missing table, which
+// means no migration has been applied yet
+func isUndefinedTable(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "42P01"
+}
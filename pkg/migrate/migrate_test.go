@@ -0,0 +1,55 @@
+package migrate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"subscriptions_service_golang/migrations"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	fsys := fstest.MapFS{
+		"002_add_index.up.sql":   {Data: []byte("CREATE INDEX")},
+		"002_add_index.down.sql": {Data: []byte("DROP INDEX")},
+		"001_init.up.sql":        {Data: []byte("CREATE TABLE")},
+		"001_init.down.sql":      {Data: []byte("DROP TABLE")},
+		"embed.go":               {Data: []byte("package migrations")},
+	}
+	got, err := Parse(fsys)
+	assert.NoError(t, err)
+	assert.Equal(t, []Migration{
+		{Version: 1, Name: "init", Up: "CREATE TABLE", Down: "DROP TABLE"},
+		{Version: 2, Name: "add_index", Up: "CREATE INDEX", Down: "DROP INDEX"},
+	}, got)
+
+	delete(fsys, "002_add_index.down.sql")
+	_, err = Parse(fsys)
+	assert.ErrorContains(t, err, "002_add_index")
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	got, err := Parse(migrations.FS)
+	assert.NoError(t, err)
+	for i, m := range got {
+		assert.Equal(t, int64(i+1), m.Version, "versions must have no gaps")
+	}
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "007_old.up.sql"), nil, 0o644)
+	os.WriteFile(filepath.Join(dir, "007_old.down.sql"), nil, 0o644)
+
+	up, down, err := Create(dir, "add_users")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "008_add_users.up.sql"), up)
+	assert.Equal(t, filepath.Join(dir, "008_add_users.down.sql"), down)
+	assert.FileExists(t, up)
+
+	_, _, err = Create(dir, "bad name")
+	assert.Error(t, err)
+}
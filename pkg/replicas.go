@@ -0,0 +1,129 @@
+package pkg
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"subscriptions_service_golang/pkg/logger"
+
+	"go.uber.org/zap"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+)
+
+// replicaCheckInterval is how often a replica's health is re-checked
+const replicaCheckInterval = 5 * time.Second
+
+// useReplicas routes reads of db to replicas. Writes, transactions and
+// reads marked with dbresolver.Write stay on the primary.
+//
+// The primary's own pool is appended as the last replica. dbresolver only
+// consults the policy when it has more than one replica, and this way the
+// policy can fall back to the primary when no replica is healthy.
+func useReplicas(db *gorm.DB, replicas []string, opts Options) error {
+	primary, err := db.DB()
+	if err != nil {
+		return err
+	}
+	dialectors := make([]gorm.Dialector, 0, len(replicas)+1)
+	for _, dsn := range replicas {
+		dialectors = append(dialectors, postgres.Open(dsn))
+	}
+	dialectors = append(dialectors, postgres.New(postgres.Config{Conn: primary}))
+	resolver := dbresolver.Register(dbresolver.Config{
+		Replicas: dialectors,
+		Policy:   newReplicaPolicy(replicaCheckInterval),
+	})
+	if err := db.Use(resolver); err != nil {
+		return err
+	}
+	// each replica gets a pool of its own with the primary's limits; the
+	// pools exist once the resolver is initialised
+	if opts.MaxOpenConns > 0 {
+		resolver.SetMaxOpenConns(opts.MaxOpenConns)
+	}
+	if opts.MaxIdleConns > 0 {
+		resolver.SetMaxIdleConns(opts.MaxIdleConns)
+	}
+	if opts.ConnMaxLifetime > 0 {
+		resolver.SetConnMaxLifetime(opts.ConnMaxLifetime)
+	}
+	return nil
+}
+
+// replicaPolicy spreads reads round-robin over the replicas that passed
+// their last health check and returns the primary, the last pool, when
+// none did. Checks run in the background at most once per interval for
+// each replica, so a request never waits for one.
+type replicaPolicy struct {
+	interval time.Duration
+	next     atomic.Uint64
+
+	mu     sync.Mutex
+	health map[gorm.ConnPool]*replicaHealth
+}
+
+type replicaHealth struct {
+	index    int
+	healthy  bool
+	checked  time.Time
+	checking bool
+}
+
+func newReplicaPolicy(interval time.Duration) *replicaPolicy {
+	return &replicaPolicy{interval: interval, health: make(map[gorm.ConnPool]*replicaHealth)}
+}
+
+func (p *replicaPolicy) Resolve(pools []gorm.ConnPool) gorm.ConnPool {
+	replicas, primary := pools[:len(pools)-1], pools[len(pools)-1]
+	start := p.next.Add(1)
+	for i := range replicas {
+		n := int((start + uint64(i)) % uint64(len(replicas)))
+		if p.healthy(n, replicas[n]) {
+			return replicas[n]
+		}
+	}
+	return primary
+}
+
+// healthy reports the result of the last check of pool, starting a new
+// one when it is due. Replicas are assumed healthy until checked.
+func (p *replicaPolicy) healthy(index int, pool gorm.ConnPool) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	h, ok := p.health[pool]
+	if !ok {
+		h = &replicaHealth{index: index, healthy: true}
+		p.health[pool] = h
+	}
+	if !h.checking && time.Since(h.checked) >= p.interval {
+		h.checking = true
+		go p.check(pool, h)
+	}
+	return h.healthy
+}
+
+func (p *replicaPolicy) check(pool gorm.ConnPool, h *replicaHealth) {
+	var err error
+	if pinger, ok := pool.(interface{ PingContext(context.Context) error }); ok {
+		ctx, cancel := context.WithTimeout(context.Background(), p.interval)
+		err = pinger.PingContext(ctx)
+		cancel()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if h.healthy != (err == nil) {
+		if err != nil {
+			logger.Log.Warn("Database replica is unhealthy, reads go elsewhere", zap.Int("replica", h.index), zap.Error(err))
+		} else {
+			logger.Log.Info("Database replica recovered", zap.Int("replica", h.index))
+		}
+	}
+	h.healthy = err == nil
+	h.checked = time.Now()
+	h.checking = false
+}
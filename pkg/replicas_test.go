@@ -0,0 +1,64 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// fakePool is a connection pool whose health is set by the test
+type fakePool struct {
+	gorm.ConnPool
+	name string
+	down atomic.Bool
+}
+
+func (p *fakePool) PingContext(context.Context) error {
+	if p.down.Load() {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func TestReplicaPolicy(t *testing.T) {
+	a, b, primary := &fakePool{name: "a"}, &fakePool{name: "b"}, &fakePool{name: "primary"}
+	pools := []gorm.ConnPool{a, b, primary}
+	policy := newReplicaPolicy(time.Millisecond)
+
+	resolve := func() string { return policy.Resolve(pools).(*fakePool).name }
+	// waitChecks lets the background checks observe the current health
+	waitChecks := func() {
+		for range 2 {
+			time.Sleep(5 * time.Millisecond)
+			for range len(pools) {
+				resolve()
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	seen := map[string]int{}
+	for range 10 {
+		seen[resolve()]++
+	}
+	assert.Equal(t, map[string]int{"a": 5, "b": 5}, seen, "reads alternate between replicas")
+
+	a.down.Store(true)
+	waitChecks()
+	for range 4 {
+		assert.Equal(t, "b", resolve(), "an unhealthy replica is skipped")
+	}
+
+	b.down.Store(true)
+	waitChecks()
+	assert.Equal(t, "primary", resolve(), "without healthy replicas reads go to the primary")
+
+	a.down.Store(false)
+	waitChecks()
+	assert.Equal(t, "a", resolve(), "a recovered replica is used again")
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	cfg := DefaultConfig()
+	assert.NoError(t, cfg.Validate())
+	assert.False(t, cfg.TLS())
+
+	cfg.TLSCertFile = "cert.pem"
+	assert.Error(t, cfg.Validate())
+
+	cfg = DefaultConfig()
+	cfg.Addr = "8080"
+	assert.Error(t, cfg.Validate())
+}
+
+func TestRunDrainsRequests(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := l.Addr().String()
+	l.Close()
+
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		io.WriteString(w, "done")
+	})
+	cfg := DefaultConfig()
+	cfg.Addr = addr
+	cfg.ShutdownTimeout = time.Second
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() { result <- Run(ctx, New(cfg, handler), cfg) }()
+
+	body := make(chan string, 1)
+	go func() {
+		for {
+			resp, err := http.Get("http://" + addr)
+			if err != nil {
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+			b, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			body <- string(b)
+			return
+		}
+	}()
+
+	<-started
+	cancel()
+	assert.Equal(t, "done", <-body)
+	assert.NoError(t, <-result)
+}